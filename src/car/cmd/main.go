@@ -1,19 +1,27 @@
 package main
 
 import (
-	"log"
+	"log/slog"
+	"os"
 
+	config "github.com/SwanPoi/bmstu_rsoi_lab2/src/car/config"
 	handler "github.com/SwanPoi/bmstu_rsoi_lab2/src/car/handler"
 	models "github.com/SwanPoi/bmstu_rsoi_lab2/src/car/models"
-	config "github.com/SwanPoi/bmstu_rsoi_lab2/src/car/config"
 	repo "github.com/SwanPoi/bmstu_rsoi_lab2/src/car/repositories"
 	server "github.com/SwanPoi/bmstu_rsoi_lab2/src/car/server"
 	services "github.com/SwanPoi/bmstu_rsoi_lab2/src/car/services"
+	logging "github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+	metrics "github.com/SwanPoi/bmstu_rsoi_lab2/src/common/metrics"
+	tracing "github.com/SwanPoi/bmstu_rsoi_lab2/src/common/tracing"
 )
 
 func main() {
 	cfg := config.Load()
 
+	logging.Init("car", cfg.LogLevel)
+	tracing.Init("car", cfg.TraceExporter, cfg.OTLPEndpoint)
+	metrics.Init("car")
+
 	connString := repo.GetConnectionString(&repo.DatabaseConfig{
 		Host: cfg.DBHost,
 		Port: cfg.DBPort,
@@ -25,21 +33,22 @@ func main() {
 	db, err := repo.InitDb(connString)
 
 	if err != nil {
-		log.Fatal("Fail during db connection", err)
-		return
+		slog.Error("fail during db connection", "error", err)
+		os.Exit(1)
 	}
 
-	log.Print("Successfully connect to database")
-	db.AutoMigrate(&models.Car{})
+	slog.Info("successfully connected to database")
+	db.AutoMigrate(&models.Car{}, &models.Reservation{})
 
 	repos := repo.NewRepository(db)
-	service := services.NewServices(repos)
-	handler := handler.NewHandler(service)
+	service := services.NewServices(repos, cfg.ReservationHoldTTL, cfg.AllowPastReservations)
+	services.StartHoldSweeper(service, cfg.ReservationSweepInterval)
+	handler := handler.NewHandler(service, cfg.AdminToken)
 
 	srv := new(server.CommonServer)
 
 	if err := srv.Run(cfg.Addr(), handler.SetupRoutes()); err != nil {
-		log.Fatal("Fail during car server start", err)
-		return
+		slog.Error("fail during car server start", "error", err)
+		os.Exit(1)
 	}
 }
\ No newline at end of file
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 )
 
 type Config struct {
@@ -13,6 +14,19 @@ type Config struct {
 	DBUser			string
 	DBPassword		string
 	DBName			string
+	// none, stdout (OTLP JSON построчно) или otlp (OTLP/HTTP коллектор)
+	TraceExporter	string
+	OTLPEndpoint	string
+	// debug, info, warn или error
+	LogLevel		string
+	// Сколько живёт неподтверждённая бронь
+	ReservationHoldTTL			time.Duration
+	// Как часто снимаются просроченные брони
+	ReservationSweepInterval	time.Duration
+	// Разрешить брони с датой начала в прошлом (тестовые сценарии на фиксированные даты)
+	AllowPastReservations		bool
+	// Токен администратора для управления автопарком, пустой - управление отключено
+	AdminToken					string
 }
 
 func Load() Config {
@@ -24,6 +38,13 @@ func Load() Config {
 		DBPassword:		getenv("DB_PASSWORD", "postgres"),
 		DBUser: 		getenv("DB_USER", "postgres"),
 		DBName: 		getenv("DB_NAME", "cars"),
+		TraceExporter:	getenv("TRACE_EXPORTER", "none"),
+		OTLPEndpoint:	getenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318"),
+		LogLevel:		getenv("LOG_LEVEL", "info"),
+		ReservationHoldTTL:			getenvDuration("RESERVATION_HOLD_TTL", 5 * time.Minute),
+		ReservationSweepInterval:	getenvDuration("RESERVATION_SWEEP_INTERVAL", time.Minute),
+		AllowPastReservations:		getenv("ALLOW_PAST_RESERVATIONS", "false") == "true",
+		AdminToken:					getenv("ADMIN_TOKEN", ""),
 	}
 }
 
@@ -36,4 +57,13 @@ func getenv(key, def string) string {
 		return v
 	}
 	return def
-}
\ No newline at end of file
+}
+
+func getenvDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
@@ -11,7 +11,7 @@ func CarResponseFromCar(car models.Car) models.CarResponse {
         Power:            car.Power,
         Type:             car.Type,
         Price:            car.Price,
-        Available:        car.Availability,
+        Available:        car.Availability && car.RetiredAt == nil && !car.Booked,
     }
 }
 
@@ -21,4 +21,15 @@ func CarResponsesFromCars(cars []models.Car) []models.CarResponse {
         responses[i] = CarResponseFromCar(car)
     }
     return responses
-}
\ No newline at end of file
+}
+
+func CarFromRequest(req models.CarRequest) models.Car {
+    return models.Car{
+        Brand:            req.Brand,
+        Model:            req.Model,
+        RegistrationNumber: req.RegistrationNumber,
+        Power:            req.Power,
+        Price:            req.Price,
+        Type:             req.Type,
+    }
+}
@@ -0,0 +1,19 @@
+package converters
+
+import "github.com/SwanPoi/bmstu_rsoi_lab2/src/car/models"
+
+func ReservationResponseFromReservation(reservation models.Reservation) models.ReservationResponse {
+	response := models.ReservationResponse{
+		ReservationUID: reservation.ReservationUID,
+		CarUID: reservation.CarUID,
+		DateFrom: reservation.DateFrom.Format(models.DateLayout),
+		DateTo: reservation.DateTo.Format(models.DateLayout),
+		ExpiresAt: reservation.ExpiresAt,
+	}
+
+	if reservation.RentalUID != nil {
+		response.RentalUID = *reservation.RentalUID
+	}
+
+	return response
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFleetRouter(token string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	return NewHandler(nil, token).SetupRoutes()
+}
+
+// Тест: управление автопарком без ADMIN_TOKEN отключено
+func TestFleet_AdminDisabled(t *testing.T) {
+	router := newTestFleetRouter("")
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/api/v1/cars", strings.NewReader(`{}`)),
+		httptest.NewRequest(http.MethodPut, "/api/v1/cars/109b42f3-198d-4c89-9276-a7520a7120ab", strings.NewReader(`{}`)),
+		httptest.NewRequest(http.MethodDelete, "/api/v1/cars/109b42f3-198d-4c89-9276-a7520a7120ab", nil),
+	}
+
+	for _, req := range requests {
+		req.Header.Set("Authorization", "Bearer secret")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusForbidden, recorder.Code, req.Method)
+	}
+}
+
+// Тест: без верного токена администратора автомобиль не создаётся и не списывается
+func TestFleet_RequiresAdminCredential(t *testing.T) {
+	router := newTestFleetRouter("secret")
+
+	for _, header := range []string{"", "Bearer wrong"} {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/cars/109b42f3-198d-4c89-9276-a7520a7120ab", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code, header)
+	}
+
+	// С верным токеном запрос доходит до обработчика
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cars", strings.NewReader(`not json`))
+	req.Header.Set("Authorization", "Bearer secret")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
@@ -2,8 +2,12 @@ package handler
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -11,6 +15,11 @@ import (
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/car/models"
 )
 
+const (
+	calendarDays = 30
+	maxCalendarDays = 366
+)
+
 /*
 * Получение всех машин по фильтрам
  */
@@ -41,7 +50,14 @@ func (h *CarHandler) GetCars(ctx *gin.Context) {
 		return
 	}
 
-	carsResponse, err := h.services.GetCars(page, size, showAll)
+	filter, err := parseCarFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	filter.ShowAll = showAll
+
+	carsResponse, err := h.services.GetCars(ctx.Request.Context(), page, size, filter)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: err.Error()})
 		return
@@ -50,6 +66,111 @@ func (h *CarHandler) GetCars(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, carsResponse)
 }
 
+/*
+* Фильтры и сортировка каталога из параметров запроса
+ */
+func parseCarFilter(ctx *gin.Context) (models.CarFilter, error) {
+	filter := models.CarFilter{
+		Brand:		strings.TrimSpace(ctx.Query("brand")),
+		Model:		strings.TrimSpace(ctx.Query("model")),
+		SortBy:		ctx.Query("sortBy"),
+		SortOrder:	strings.ToLower(ctx.DefaultQuery("sortOrder", models.SortAsc)),
+	}
+
+	// type можно передать несколько раз или через запятую
+	for _, value := range ctx.QueryArray("type") {
+		for _, carType := range strings.Split(value, ",") {
+			carType = strings.ToUpper(strings.TrimSpace(carType))
+			if !slices.Contains(models.CarTypes, carType) {
+				return filter, fmt.Errorf("Type must be one of %s", strings.Join(models.CarTypes, ", "))
+			}
+			filter.Types = append(filter.Types, carType)
+		}
+	}
+
+	bounds := []struct {
+		name	string
+		value	**int
+	}{
+		{"minPrice", &filter.MinPrice},
+		{"maxPrice", &filter.MaxPrice},
+		{"minPower", &filter.MinPower},
+		{"maxPower", &filter.MaxPower},
+	}
+
+	for _, bound := range bounds {
+		raw := ctx.Query(bound.name)
+		if raw == "" {
+			continue
+		}
+
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return filter, fmt.Errorf("%s must be a non-negative integer", bound.name)
+		}
+		*bound.value = &value
+	}
+
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return filter, errors.New("minPrice must not be greater than maxPrice")
+	}
+
+	if filter.MinPower != nil && filter.MaxPower != nil && *filter.MinPower > *filter.MaxPower {
+		return filter, errors.New("minPower must not be greater than maxPower")
+	}
+
+	if filter.SortBy != "" && !slices.Contains(models.CarSortFields, filter.SortBy) {
+		return filter, fmt.Errorf("sortBy must be one of %s", strings.Join(models.CarSortFields, ", "))
+	}
+
+	if filter.SortOrder != models.SortAsc && filter.SortOrder != models.SortDesc {
+		return filter, errors.New("sortOrder must be asc or desc")
+	}
+
+	// Без окна доступность считается на сегодня
+	availableFrom, availableTo := ctx.Query("availableFrom"), ctx.Query("availableTo")
+	if availableFrom == "" && availableTo == "" {
+		filter.AvailableFrom = today()
+		filter.AvailableTo = filter.AvailableFrom.AddDate(0, 0, 1)
+		return filter, nil
+	}
+
+	if availableFrom == "" || availableTo == "" {
+		return filter, errors.New("availableFrom and availableTo must be set together")
+	}
+
+	from, to, err := parseInterval(availableFrom, availableTo)
+	if err != nil {
+		return filter, err
+	}
+	filter.AvailableFrom, filter.AvailableTo = from, to
+
+	return filter, nil
+}
+
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
+
+// Интервал дат [from, to) в формате 2006-01-02
+func parseInterval(fromStr, toStr string) (time.Time, time.Time, error) {
+	from, err := time.Parse(models.DateLayout, fromStr)
+	if err != nil {
+		return from, from, fmt.Errorf("date %q must be in format %s", fromStr, models.DateLayout)
+	}
+
+	to, err := time.Parse(models.DateLayout, toStr)
+	if err != nil {
+		return from, to, fmt.Errorf("date %q must be in format %s", toStr, models.DateLayout)
+	}
+
+	if !from.Before(to) {
+		return from, to, errors.New("start date must be before end date")
+	}
+
+	return from, to, nil
+}
+
 /*
 * Получение машин по идентификаторам
  */
@@ -61,7 +182,7 @@ func (h *CarHandler) GetCarsBatch(ctx *gin.Context) {
 		return
 	}
 
-	cars, err := h.services.GetCarsByUids(req.UIDs)
+	cars, err := h.services.GetCarsByUids(ctx.Request.Context(), req.UIDs)
 
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, err)
@@ -82,7 +203,7 @@ func (h *CarHandler) GetCarById(ctx *gin.Context) {
 		return
 	}
 
-	car, err := h.services.GetCarByUid(carUid)
+	car, err := h.services.GetCarByUid(ctx.Request.Context(), carUid)
 
 	if err != nil {
 		if errors.Is(err, models.ErrorNotFound) {
@@ -112,11 +233,14 @@ func (h *CarHandler) UpdateCar(ctx *gin.Context) {
 		return
 	}
 
-	updatedCar, err := h.services.UpdateCar(req, carUid)
+	updatedCar, err := h.services.UpdateCar(ctx.Request.Context(), req, carUid)
 	if err != nil {
 		if err == models.ErrorNotFound {
 			message := "Car with uid = " + carUid + " is not found"
 			ctx.JSON(http.StatusNotFound, models.ErrorResponse{Message: message})
+		} else if err == models.ErrorRetired {
+			message := "Car with uid = " + carUid + " is retired"
+			ctx.JSON(http.StatusConflict, models.ErrorResponse{Message: message})
 		} else {
 			ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: err.Error()})
 		}
@@ -126,3 +250,307 @@ func (h *CarHandler) UpdateCar(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, updatedCar)
 }
+
+
+/*
+* Добавление автомобиля в парк
+ */
+func (h *CarHandler) CreateCar(ctx *gin.Context) {
+	var req models.CarRequest
+
+	if err := ctx.BindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Bad Car Request body"})
+		return
+	}
+
+	if validationErr, ok := validateCarRequest(&req); !ok {
+		ctx.JSON(http.StatusBadRequest, validationErr)
+		return
+	}
+
+	car, err := h.services.CreateCar(ctx.Request.Context(), req)
+	if err != nil {
+		writeFleetError(ctx, err, "")
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, car)
+}
+
+/*
+* Полная замена данных автомобиля
+ */
+func (h *CarHandler) ReplaceCar(ctx *gin.Context) {
+	carUid := ctx.Param("uid")
+
+	if _, err := uuid.Parse(carUid); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Car Uid must be valid"})
+		return
+	}
+
+	var req models.CarRequest
+
+	if err := ctx.BindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Bad Car Request body"})
+		return
+	}
+
+	if validationErr, ok := validateCarRequest(&req); !ok {
+		ctx.JSON(http.StatusBadRequest, validationErr)
+		return
+	}
+
+	car, err := h.services.ReplaceCar(ctx.Request.Context(), req, carUid)
+	if err != nil {
+		writeFleetError(ctx, err, carUid)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, car)
+}
+
+/*
+* Списание автомобиля
+ */
+func (h *CarHandler) RetireCar(ctx *gin.Context) {
+	carUid := ctx.Param("uid")
+
+	if _, err := uuid.Parse(carUid); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Car Uid must be valid"})
+		return
+	}
+
+	if err := h.services.RetireCar(ctx.Request.Context(), carUid); err != nil {
+		writeFleetError(ctx, err, carUid)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+/*
+* Проверка данных автомобиля. Строки обрезаются, тип приводится к верхнему регистру
+ */
+func validateCarRequest(req *models.CarRequest) (models.ValidationErrorResponse, bool) {
+	validationErr := models.ValidationErrorResponse{
+		Message: "Validation Error",
+		Errors: make(map[string]string),
+	}
+
+	req.Brand = strings.TrimSpace(req.Brand)
+	req.Model = strings.TrimSpace(req.Model)
+	req.RegistrationNumber = strings.TrimSpace(req.RegistrationNumber)
+	req.Type = strings.ToUpper(strings.TrimSpace(req.Type))
+
+	if req.Brand == "" || len([]rune(req.Brand)) > 80 {
+		validationErr.Errors["brand"] = "Brand must be from 1 to 80 characters"
+	}
+
+	if req.Model == "" || len([]rune(req.Model)) > 80 {
+		validationErr.Errors["model"] = "Model must be from 1 to 80 characters"
+	}
+
+	if req.RegistrationNumber == "" || len([]rune(req.RegistrationNumber)) > 20 {
+		validationErr.Errors["registrationNumber"] = "Registration number must be from 1 to 20 characters"
+	}
+
+	if req.Power <= 0 {
+		validationErr.Errors["power"] = "Power must be positive"
+	}
+
+	if req.Price <= 0 {
+		validationErr.Errors["price"] = "Price must be positive"
+	}
+
+	if !slices.Contains(models.CarTypes, req.Type) {
+		validationErr.Errors["type"] = "Type must be one of " + strings.Join(models.CarTypes, ", ")
+	}
+
+	return validationErr, len(validationErr.Errors) == 0
+}
+
+/*
+* Удержание автомобиля на интервал дат до подтверждения арендой
+ */
+func (h *CarHandler) ReserveCar(ctx *gin.Context) {
+	carUid := ctx.Param("uid")
+
+	if _, err := uuid.Parse(carUid); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Car Uid must be valid"})
+		return
+	}
+
+	var req models.ReservationRequest
+
+	if err := ctx.BindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Bad Reservation body"})
+		return
+	}
+
+	from, to, err := parseInterval(req.DateFrom, req.DateTo)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	reservation, err := h.services.ReserveCar(ctx.Request.Context(), carUid, from, to)
+	if err != nil {
+		writeFleetError(ctx, err, carUid)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, reservation)
+}
+
+/*
+* Подтверждение удержания арендой
+ */
+func (h *CarHandler) ConfirmReservation(ctx *gin.Context) {
+	carUid := ctx.Param("uid")
+	reservationUid := ctx.Param("reservationUid")
+
+	if _, err := uuid.Parse(carUid); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Car Uid must be valid"})
+		return
+	}
+
+	if _, err := uuid.Parse(reservationUid); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Reservation Uid must be valid"})
+		return
+	}
+
+	var req models.ReservationConfirmRequest
+
+	if err := ctx.BindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Bad Reservation Confirm body"})
+		return
+	}
+
+	if _, err := uuid.Parse(req.RentalUID); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Rental Uid must be valid"})
+		return
+	}
+
+	reservation, err := h.services.ConfirmReservation(ctx.Request.Context(), carUid, reservationUid, req.RentalUID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrorNotFound):
+			ctx.JSON(http.StatusNotFound, models.ErrorResponse{Message: "Reservation with uid = " + reservationUid + " is not found"})
+		case errors.Is(err, models.ErrorHoldExpired):
+			ctx.JSON(http.StatusConflict, models.ErrorResponse{Message: "Reservation with uid = " + reservationUid + " is expired"})
+		case errors.Is(err, models.ErrorReserved):
+			ctx.JSON(http.StatusConflict, models.ErrorResponse{Message: "Reservation with uid = " + reservationUid + " is confirmed by another rental"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, reservation)
+}
+
+/*
+* Снятие брони по её идентификатору
+ */
+func (h *CarHandler) ReleaseReservation(ctx *gin.Context) {
+	carUid := ctx.Param("uid")
+	reservationUid := ctx.Param("reservationUid")
+
+	if _, err := uuid.Parse(carUid); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Car Uid must be valid"})
+		return
+	}
+
+	if _, err := uuid.Parse(reservationUid); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Reservation Uid must be valid"})
+		return
+	}
+
+	if err := h.services.ReleaseReservation(ctx.Request.Context(), carUid, reservationUid); err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+/*
+* Снятие брони завершённой или отменённой аренды
+ */
+func (h *CarHandler) ReleaseRentalReservation(ctx *gin.Context) {
+	carUid := ctx.Param("uid")
+	rentalUid := ctx.Query("rentalUid")
+
+	if _, err := uuid.Parse(carUid); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Car Uid must be valid"})
+		return
+	}
+
+	if _, err := uuid.Parse(rentalUid); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Rental Uid must be valid"})
+		return
+	}
+
+	if err := h.services.ReleaseRentalReservation(ctx.Request.Context(), carUid, rentalUid); err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+/*
+* Календарь занятости автомобиля. По умолчанию - 30 дней начиная с сегодня
+ */
+func (h *CarHandler) GetCalendar(ctx *gin.Context) {
+	carUid := ctx.Param("uid")
+
+	if _, err := uuid.Parse(carUid); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Car Uid must be valid"})
+		return
+	}
+
+	from := today()
+	fromStr := ctx.DefaultQuery("from", from.Format(models.DateLayout))
+	toStr := ctx.DefaultQuery("to", from.AddDate(0, 0, calendarDays).Format(models.DateLayout))
+
+	from, to, err := parseInterval(fromStr, toStr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	if to.Sub(from) > maxCalendarDays * 24 * time.Hour {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: fmt.Sprintf("Calendar range must not exceed %d days", maxCalendarDays)})
+		return
+	}
+
+	calendar, err := h.services.GetCalendar(ctx.Request.Context(), carUid, from, to)
+	if err != nil {
+		writeFleetError(ctx, err, carUid)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, calendar)
+}
+
+func writeFleetError(ctx *gin.Context, err error, carUid string) {
+	switch {
+	case errors.Is(err, models.ErrorNotFound):
+		ctx.JSON(http.StatusNotFound, models.ErrorResponse{Message: "Car with uid = " + carUid + " is not found"})
+	case errors.Is(err, models.ErrorAlreadyExists):
+		ctx.JSON(http.StatusConflict, models.ErrorResponse{Message: "Car with this registration number already exists"})
+	case errors.Is(err, models.ErrorRetired):
+		ctx.JSON(http.StatusConflict, models.ErrorResponse{Message: "Car with uid = " + carUid + " is retired"})
+	case errors.Is(err, models.ErrorCarRented):
+		ctx.JSON(http.StatusConflict, models.ErrorResponse{Message: "Car with uid = " + carUid + " is rented"})
+	case errors.Is(err, models.ErrorOutOfService):
+		ctx.JSON(http.StatusConflict, models.ErrorResponse{Message: "Car with uid = " + carUid + " is out of service"})
+	case errors.Is(err, models.ErrorReserved):
+		ctx.JSON(http.StatusConflict, models.ErrorResponse{Message: "Car with uid = " + carUid + " is already reserved for these dates"})
+	case errors.Is(err, models.ErrorPastDate):
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Reservation can't start before today"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: err.Error()})
+	}
+}
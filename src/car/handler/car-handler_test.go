@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/car/models"
+)
+
+func parseTestFilter(query string) (models.CarFilter, error) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/api/v1/cars?"+query, nil)
+
+	return parseCarFilter(ctx)
+}
+
+// Тест: нулевая граница - заданный фильтр, а не его отсутствие
+func TestParseCarFilter_ZeroBoundIsSet(t *testing.T) {
+	filter, err := parseTestFilter("maxPrice=0&minPower=0")
+
+	require.NoError(t, err)
+	require.NotNil(t, filter.MaxPrice)
+	assert.Equal(t, 0, *filter.MaxPrice)
+	require.NotNil(t, filter.MinPower)
+	assert.Equal(t, 0, *filter.MinPower)
+	assert.Nil(t, filter.MinPrice)
+	assert.Nil(t, filter.MaxPower)
+}
+
+// Тест: нижняя граница больше верхней отклоняется, в том числе при верхней границе 0
+func TestParseCarFilter_MinGreaterThanMax(t *testing.T) {
+	for _, query := range []string{
+		"minPrice=100&maxPrice=0",
+		"minPrice=5000&maxPrice=1000",
+		"minPower=1&maxPower=0",
+	} {
+		_, err := parseTestFilter(query)
+		assert.Error(t, err, query)
+	}
+}
+
+// Тест: равные границы и одна из границ допустимы
+func TestParseCarFilter_ValidBounds(t *testing.T) {
+	for _, query := range []string{
+		"minPrice=0&maxPrice=0",
+		"minPrice=3500&maxPrice=3500",
+		"minPrice=100",
+		"maxPower=300",
+	} {
+		_, err := parseTestFilter(query)
+		assert.NoError(t, err, query)
+	}
+}
+
+// Тест: отрицательная или нечисловая граница отклоняется
+func TestParseCarFilter_InvalidBound(t *testing.T) {
+	for _, query := range []string{"minPrice=-1", "maxPower=abc"} {
+		_, err := parseTestFilter(query)
+		assert.Error(t, err, query)
+	}
+}
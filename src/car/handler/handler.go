@@ -6,22 +6,30 @@ import (
 	"github.com/gin-gonic/gin"
 
 	services "github.com/SwanPoi/bmstu_rsoi_lab2/src/car/services"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/auth"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/deadline"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/metrics"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/tracing"
 )
 
 type CarHandler struct {
-	services *services.Services
+	services	*services.Services
+	adminAuth	gin.HandlerFunc
 }
 
-func NewHandler(services *services.Services) *CarHandler {
-	return &CarHandler{services: services}
+func NewHandler(services *services.Services, adminToken string) *CarHandler {
+	return &CarHandler{services: services, adminAuth: auth.Admin(adminToken, "Fleet management is disabled")}
 }
 
 func (h *CarHandler) SetupRoutes() *gin.Engine {
 	router := gin.New()
+	router.Use(tracing.Middleware(), logging.Middleware(), metrics.Middleware(), deadline.Middleware(deadline.Budgets{}))
 
 	router.GET("/manage/health", func (c *gin.Context) {
 		c.Status(http.StatusOK)
 	})
+	router.GET("/manage/metrics", metrics.Handler())
 
 	api := router.Group("/api/v1") 
 	{
@@ -32,6 +40,14 @@ func (h *CarHandler) SetupRoutes() *gin.Engine {
 			cars.GET("/:uid", h.GetCarById)
 			cars.POST("/query", h.GetCarsBatch)
 			cars.PATCH("/:uid", h.UpdateCar)
+			cars.POST("", h.adminAuth, h.CreateCar)
+			cars.PUT("/:uid", h.adminAuth, h.ReplaceCar)
+			cars.DELETE("/:uid", h.adminAuth, h.RetireCar)
+			cars.GET("/:uid/calendar", h.GetCalendar)
+			cars.POST("/:uid/reservations", h.ReserveCar)
+			cars.DELETE("/:uid/reservations", h.ReleaseRentalReservation)
+			cars.POST("/:uid/reservations/:reservationUid/confirm", h.ConfirmReservation)
+			cars.DELETE("/:uid/reservations/:reservationUid", h.ReleaseReservation)
 		}
 	}
 
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Допустимые типы автомобилей
+var CarTypes = []string{"SEDAN", "SUV", "MINIVAN", "ROADSTER"}
+
+// Поля, по которым можно сортировать каталог
+var CarSortFields = []string{"price", "power", "brand", "model"}
+
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// Фильтры каталога. Пустые значения не ограничивают выборку,
+// границы цены и мощности заданы, если не nil (в том числе 0)
+type CarFilter struct {
+    ShowAll     bool
+    // Марка без учёта регистра
+    Brand       string
+    // Часть названия модели без учёта регистра
+    Model       string
+    Types       []string
+    MinPrice    *int
+    MaxPrice    *int
+    MinPower    *int
+    MaxPower    *int
+    SortBy      string
+    SortOrder   string
+    // Окно [AvailableFrom, AvailableTo), для которого считается доступность
+    AvailableFrom   time.Time
+    AvailableTo     time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+// Данные автомобиля для создания и полной замены.
+// Доступностью управляют аренды через PATCH, поэтому её здесь нет
+type CarRequest struct {
+	Brand              string `json:"brand"`
+	Model              string `json:"model"`
+	RegistrationNumber string `json:"registrationNumber"`
+	Power              int    `json:"power"`
+	Price              int    `json:"price"`
+	Type               string `json:"type"`
+}
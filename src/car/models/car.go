@@ -1,13 +1,20 @@
 package models
 
+import "time"
+
 type Car struct {
     ID                uint      `json:"id" gorm:"primaryKey;autoIncrement"`
     CarUID            string    `json:"car_uid" gorm:"type:uuid;uniqueIndex;not null"`
     Brand             string    `json:"brand" gorm:"type:varchar(80);not null"`
     Model             string    `json:"model" gorm:"type:varchar(80);not null"`
-    RegistrationNumber string   `json:"registration_number" gorm:"type:varchar(20);not null"`
+    RegistrationNumber string   `json:"registration_number" gorm:"type:varchar(20);uniqueIndex;not null"`
     Power             int       `json:"power" gorm:"type:integer"`
     Price             int       `json:"price" gorm:"type:integer;not null"`
     Type              string    `json:"type" gorm:"type:varchar(20);check:type IN ('SEDAN', 'SUV', 'MINIVAN', 'ROADSTER')"`
+    // Автомобиль в строю. Занятость по датам хранится в Reservation
     Availability      bool      `json:"availability" gorm:"not null"`
+    // Списанный автомобиль не попадает в каталог, но остаётся доступен по car_uid для старых аренд
+    RetiredAt         *time.Time `json:"retired_at" gorm:"index"`
+    // Есть бронь в окне фильтра каталога, вычисляется запросом
+    Booked            bool      `json:"-" gorm:"->;-:migration"`
 }
\ No newline at end of file
@@ -5,4 +5,10 @@ import "errors"
 var (
 	ErrorNotFound error = errors.New("not found")
 	ErrorAlreadyExists error = errors.New("already exists")
+	ErrorRetired error = errors.New("car is retired")
+	ErrorCarRented error = errors.New("car is rented")
+	ErrorOutOfService error = errors.New("car is out of service")
+	ErrorReserved error = errors.New("car is already reserved for these dates")
+	ErrorHoldExpired error = errors.New("reservation hold is expired")
+	ErrorPastDate error = errors.New("reservation starts before today")
 )
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Формат дат аренды
+const DateLayout = "2006-01-02"
+
+// Забронированный интервал [DateFrom, DateTo): день DateTo уже свободен.
+// Новая бронь - временное удержание до ExpiresAt, подтверждение привязывает
+// её к аренде и делает постоянной
+type Reservation struct {
+    ID                uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+    ReservationUID    string    `json:"reservation_uid" gorm:"type:uuid;uniqueIndex;not null"`
+    CarUID            string    `json:"car_uid" gorm:"type:uuid;index;not null"`
+    DateFrom          time.Time `json:"date_from" gorm:"type:date;not null"`
+    DateTo            time.Time `json:"date_to" gorm:"type:date;not null"`
+    RentalUID         *string   `json:"rental_uid" gorm:"type:uuid;index"`
+    ExpiresAt         *time.Time `json:"expires_at" gorm:"index"`
+    CreatedAt         time.Time `json:"created_at"`
+}
+
+type ReservationRequest struct {
+	DateFrom string `json:"dateFrom"`
+	DateTo   string `json:"dateTo"`
+}
+
+// ReservationUID служит токеном удержания. ExpiresAt пуст у подтверждённой брони
+type ReservationResponse struct {
+	ReservationUID string     `json:"reservationUid"`
+	CarUID         string     `json:"carUid"`
+	DateFrom       string     `json:"dateFrom"`
+	DateTo         string     `json:"dateTo"`
+	RentalUID      string     `json:"rentalUid,omitempty"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+}
+
+type ReservationConfirmRequest struct {
+	RentalUID string `json:"rentalUid"`
+}
+
+// Занятые интервалы автомобиля в запрошенном окне
+type CarCalendar struct {
+	CarUID       string                `json:"carUid"`
+	From         string                `json:"from"`
+	To           string                `json:"to"`
+	Reservations []ReservationResponse `json:"reservations"`
+}
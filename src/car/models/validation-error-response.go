@@ -0,0 +1,6 @@
+package models
+
+type ValidationErrorResponse struct {
+	Message string `json:"message"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
\ No newline at end of file
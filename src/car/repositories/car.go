@@ -1,10 +1,14 @@
 package repositories
 
 import (
+	"context"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/car/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CarPostgres struct {
@@ -15,20 +19,26 @@ func NewCarPostgres(db *gorm.DB) *CarPostgres {
 	return &CarPostgres{DB: db}
 }
 
-func (r *CarPostgres) GetCars(offset int, limit int, showAll bool) ([]models.Car, int, error) {
+func (r *CarPostgres) GetCars(ctx context.Context, offset int, limit int, filter models.CarFilter) ([]models.Car, int, error) {
 	var total int64
 	var cars []models.Car
 
-	query := r.DB.Model(&models.Car{})
-
-	if !showAll {
-		query = query.Where("availability = ?", true)
-	}
+	query := applyCarFilter(r.DB.WithContext(ctx).Model(&models.Car{}), filter)
 
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
+	if !filter.AvailableFrom.IsZero() {
+		query = query.Select("cars.*, EXISTS (?) AS booked", overlappingReservations(r.DB, filter.AvailableFrom, filter.AvailableTo))
+	}
+
+	query = query.Order(carOrder(filter))
+	if filter.SortBy != "" {
+		// Одинаковые значения поля не должны менять порядок между страницами
+		query = query.Order("id")
+	}
+
 	if err := query.Offset(offset).Limit(limit).Find(&cars).Error; err != nil {
 		return nil, 0, err
 	}
@@ -36,10 +46,68 @@ func (r *CarPostgres) GetCars(offset int, limit int, showAll bool) ([]models.Car
 	return cars, int(total), nil
 }
 
-func (r *CarPostgres) GetCarByUid(uid string) (*models.Car, error) {
+func applyCarFilter(query *gorm.DB, filter models.CarFilter) *gorm.DB {
+	query = query.Where("retired_at IS NULL")
+
+	if !filter.ShowAll {
+		query = query.Where("availability = ?", true)
+
+		if !filter.AvailableFrom.IsZero() {
+			query = query.Where("NOT EXISTS (?)", overlappingReservations(query.Session(&gorm.Session{NewDB: true}), filter.AvailableFrom, filter.AvailableTo))
+		}
+	}
+
+	if filter.Brand != "" {
+		query = query.Where("LOWER(brand) = LOWER(?)", filter.Brand)
+	}
+
+	if filter.Model != "" {
+		query = query.Where("model ILIKE ?", "%" + escapeLike(filter.Model) + "%")
+	}
+
+	if len(filter.Types) > 0 {
+		query = query.Where("type IN ?", filter.Types)
+	}
+
+	if filter.MinPrice != nil {
+		query = query.Where("price >= ?", *filter.MinPrice)
+	}
+
+	if filter.MaxPrice != nil {
+		query = query.Where("price <= ?", *filter.MaxPrice)
+	}
+
+	if filter.MinPower != nil {
+		query = query.Where("power >= ?", *filter.MinPower)
+	}
+
+	if filter.MaxPower != nil {
+		query = query.Where("power <= ?", *filter.MaxPower)
+	}
+
+	return query
+}
+
+// Поле сортировки проверено в handler, поэтому его можно подставить в запрос
+func carOrder(filter models.CarFilter) clause.OrderByColumn {
+	column := "id"
+	for _, field := range models.CarSortFields {
+		if field == filter.SortBy {
+			column = field
+		}
+	}
+
+	return clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: filter.SortOrder == models.SortDesc}
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(value)
+}
+
+func (r *CarPostgres) GetCarByUid(ctx context.Context, uid string) (*models.Car, error) {
 	var car models.Car
 
-	if err := r.DB.Where("car_uid = ?", uid).First(&car).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Where("car_uid = ?", uid).First(&car).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, models.ErrorNotFound
 		}
@@ -50,32 +118,36 @@ func (r *CarPostgres) GetCarByUid(uid string) (*models.Car, error) {
 	return &car, nil
 }
 
-func (r *CarPostgres) GetCarsByUids(uids []string) ([]models.Car, error) {
+func (r *CarPostgres) GetCarsByUids(ctx context.Context, uids []string) ([]models.Car, error) {
 	var cars []models.Car
 
-	if err := r.DB.Where("car_uid IN ?", uids).Find(&cars).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Where("car_uid IN ?", uids).Find(&cars).Error; err != nil {
 		return nil, err
 	}
 
 	return cars, nil
 }
 
-func (r *CarPostgres) UpdateCar(car models.CarUpsert, uid string) (*models.Car, error) {
-	result := r.DB.Model(&models.Car{}).
-				Where("car_uid = ?", uid).
-				Update("availability", car.Availability)
+func (r *CarPostgres) UpdateCar(ctx context.Context, car models.CarUpsert, uid string) (*models.Car, error) {
+	query := r.DB.WithContext(ctx).Model(&models.Car{}).Where("car_uid = ?", uid)
+	if car.Availability {
+		// Списанный автомобиль нельзя вернуть в прокат
+		query = query.Where("retired_at IS NULL")
+	}
+
+	result := query.Update("availability", car.Availability)
 	
 	if result.Error != nil {
 		return nil, result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		return nil, models.ErrorNotFound
+		return nil, r.missingCarError(ctx, uid)
 	}
 
 	var updatedCar models.Car
 
-	if err := r.DB.Where("car_uid = ?", uid).First(&updatedCar).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Where("car_uid = ?", uid).First(&updatedCar).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, models.ErrorNotFound
 		}
@@ -84,4 +156,102 @@ func (r *CarPostgres) UpdateCar(car models.CarUpsert, uid string) (*models.Car,
 	}
 
 	return &updatedCar, nil
+}
+
+func (r *CarPostgres) CreateCar(ctx context.Context, car models.Car) (*models.Car, error) {
+	if err := r.DB.WithContext(ctx).Create(&car).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, models.ErrorAlreadyExists
+		}
+
+		return nil, err
+	}
+
+	return &car, nil
+}
+
+func (r *CarPostgres) ReplaceCar(ctx context.Context, car models.CarRequest, uid string) (*models.Car, error) {
+	result := r.DB.WithContext(ctx).Model(&models.Car{}).
+				Where("car_uid = ? AND retired_at IS NULL", uid).
+				Updates(map[string]interface{}{
+					"brand":				car.Brand,
+					"model":				car.Model,
+					"registration_number":	car.RegistrationNumber,
+					"power":				car.Power,
+					"price":				car.Price,
+					"type":					car.Type,
+				})
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return nil, models.ErrorAlreadyExists
+		}
+
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, r.missingCarError(ctx, uid)
+	}
+
+	return r.GetCarByUid(ctx, uid)
+}
+
+/*
+* Мягкое удаление: автомобиль пропадает из каталога и больше не сдаётся.
+* Автомобиль с действующими бронями списать нельзя, повторное списание ничего не меняет
+ */
+func (r *CarPostgres) RetireCar(ctx context.Context, uid string) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var car models.Car
+
+		// Та же блокировка строки автомобиля, что и в ReserveCar: бронь не может
+		// появиться между проверкой и списанием
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+					Where("car_uid = ?", uid).First(&car).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return models.ErrorNotFound
+			}
+
+			return err
+		}
+
+		if car.RetiredAt != nil {
+			return nil
+		}
+
+		var upcoming int64
+
+		if err := tx.Model(&models.Reservation{}).
+					Where("car_uid = ? AND date_to > ?", uid, today()).
+					Where(activeReservation, time.Now()).
+					Count(&upcoming).Error; err != nil {
+			return err
+		}
+
+		if upcoming > 0 {
+			return models.ErrorCarRented
+		}
+
+		return tx.Model(&models.Car{}).
+				Where("car_uid = ?", uid).
+				Updates(map[string]interface{}{
+					"retired_at":	time.Now(),
+					"availability":	false,
+				}).Error
+	})
+}
+
+// Причина, по которой условное обновление не затронуло ни одной строки
+func (r *CarPostgres) missingCarError(ctx context.Context, uid string) error {
+	car, err := r.GetCarByUid(ctx, uid)
+	if err != nil {
+		return err
+	}
+
+	if car.RetiredAt != nil {
+		return models.ErrorRetired
+	}
+
+	return models.ErrorNotFound
 }
\ No newline at end of file
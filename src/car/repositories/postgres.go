@@ -6,6 +6,8 @@ import (
 
 	"gorm.io/gorm"
 	"gorm.io/driver/postgres"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/gormhooks"
 )
 
 
@@ -33,7 +35,18 @@ func GetConnectionString(cfg *DatabaseConfig) (connStr string) {
 }
 
 func InitDb(url string) (db *gorm.DB, err error) {
-	db, err = gorm.Open(postgres.Open(url), &gorm.Config{})
+	db, err = gorm.Open(postgres.Open(url), &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := gormhooks.Metrics(db); err != nil {
+		return nil, err
+	}
+
+	if err := gormhooks.Tracing(db); err != nil {
+		return nil, err
+	}
 
-	return db, err
+	return db, nil
 }
\ No newline at end of file
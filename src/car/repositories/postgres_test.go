@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/car/models"
+)
+
+// Тесты блокировок нужны настоящей БД с блокировками строк.
+// Без TEST_DATABASE_URL (postgres://...) они пропускаются
+func newTestRepo(t *testing.T) *CarPostgres {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := InitDb(url)
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Car{}, &models.Reservation{}))
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return NewCarPostgres(db)
+}
+
+func createTestCar(t *testing.T, db *gorm.DB) string {
+	car := models.Car{
+		CarUID:				uuid.New().String(),
+		Brand:				"Mercedes Benz",
+		Model:				"GLA 250",
+		RegistrationNumber:	uuid.New().String()[:20],
+		Power:				249,
+		Price:				3500,
+		Type:				"SEDAN",
+		Availability:		true,
+	}
+	require.NoError(t, db.Create(&car).Error)
+
+	t.Cleanup(func() {
+		db.Where("car_uid = ?", car.CarUID).Delete(&models.Reservation{})
+		db.Where("car_uid = ?", car.CarUID).Delete(&models.Car{})
+	})
+
+	return car.CarUID
+}
+
+func testReservation(carUid string, from, to time.Time) models.Reservation {
+	expiresAt := time.Now().Add(time.Minute)
+	return models.Reservation{
+		ReservationUID:	uuid.New().String(),
+		CarUID:			carUid,
+		DateFrom:		from,
+		DateTo:			to,
+		ExpiresAt:		&expiresAt,
+	}
+}
+
+// Тест: параллельные бронь и списание одного автомобиля не проходят оба
+func TestRetireCar_ConcurrentReserve_NotBoth(t *testing.T) {
+	repo := newTestRepo(t)
+	from := today().AddDate(0, 0, 1)
+
+	for range 20 {
+		carUid := createTestCar(t, repo.DB)
+
+		var reserveErr, retireErr error
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, reserveErr = repo.ReserveCar(context.Background(), testReservation(carUid, from, from.AddDate(0, 0, 3)))
+		}()
+		go func() {
+			defer wg.Done()
+			<-start
+			retireErr = repo.RetireCar(context.Background(), carUid)
+		}()
+
+		close(start)
+		wg.Wait()
+
+		if reserveErr == nil {
+			require.ErrorIs(t, retireErr, models.ErrorCarRented)
+		} else {
+			require.ErrorIs(t, reserveErr, models.ErrorRetired)
+			require.NoError(t, retireErr)
+		}
+	}
+}
+
+// Тест: из параллельных броней пересекающихся интервалов одного автомобиля
+// проходит только одна, остальные получают ErrorReserved
+func TestReserveCar_ConcurrentOverlapping_OnlyOneWins(t *testing.T) {
+	repo := newTestRepo(t)
+	carUid := createTestCar(t, repo.DB)
+	from := today().AddDate(0, 0, 1)
+
+	const attempts = 10
+	errs := make([]error, attempts)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			// Интервалы сдвинуты на день, но каждый пересекается со всеми остальными
+			reservationFrom := from.AddDate(0, 0, i%3)
+			_, errs[i] = repo.ReserveCar(context.Background(), testReservation(carUid, reservationFrom, reservationFrom.AddDate(0, 0, 3)))
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	won := 0
+	for _, err := range errs {
+		if err == nil {
+			won++
+			continue
+		}
+		require.ErrorIs(t, err, models.ErrorReserved)
+	}
+	require.Equal(t, 1, won)
+
+	var reservations int64
+	require.NoError(t, repo.DB.Model(&models.Reservation{}).Where("car_uid = ?", carUid).Count(&reservations).Error)
+	require.EqualValues(t, 1, reservations)
+}
+
+// Тест: параллельные брони непересекающихся интервалов проходят все
+func TestReserveCar_ConcurrentDisjoint_AllWin(t *testing.T) {
+	repo := newTestRepo(t)
+	carUid := createTestCar(t, repo.DB)
+	from := today().AddDate(0, 0, 1)
+
+	const attempts = 5
+	errs := make([]error, attempts)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			// [from+2i, from+2i+2) - соседние интервалы только соприкасаются
+			reservationFrom := from.AddDate(0, 0, 2*i)
+			_, errs[i] = repo.ReserveCar(context.Background(), testReservation(carUid, reservationFrom, reservationFrom.AddDate(0, 0, 2)))
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+}
@@ -1,15 +1,27 @@
 package repositories
 
 import (
+	"context"
+	"time"
+
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/car/models"
 	"gorm.io/gorm"
 )
 
 type ICarRepo interface {
-	GetCars(int, int, bool) ([]models.Car, int, error)
-	GetCarByUid(string) (*models.Car, error)
-	GetCarsByUids([]string) ([]models.Car, error)
-	UpdateCar(models.CarUpsert, string) (*models.Car, error)
+	GetCars(context.Context, int, int, models.CarFilter) ([]models.Car, int, error)
+	GetCarByUid(context.Context, string) (*models.Car, error)
+	GetCarsByUids(context.Context, []string) ([]models.Car, error)
+	UpdateCar(context.Context, models.CarUpsert, string) (*models.Car, error)
+	CreateCar(context.Context, models.Car) (*models.Car, error)
+	ReplaceCar(context.Context, models.CarRequest, string) (*models.Car, error)
+	RetireCar(context.Context, string) error
+	ReserveCar(context.Context, models.Reservation) (*models.Reservation, error)
+	ConfirmReservation(ctx context.Context, carUid, reservationUid, rentalUid string) (*models.Reservation, error)
+	ReleaseReservation(ctx context.Context, carUid, reservationUid string) error
+	ReleaseRentalReservation(ctx context.Context, carUid, rentalUid string) error
+	DeleteExpiredHolds(ctx context.Context, now time.Time) (int64, error)
+	GetReservations(ctx context.Context, carUid string, from, to time.Time) ([]models.Reservation, error)
 }
 
 type Repository struct {
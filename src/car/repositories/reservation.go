@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/car/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Просроченное удержание уже не занимает автомобиль, даже если его ещё не снял sweeper
+const activeReservation = "(reservations.expires_at IS NULL OR reservations.expires_at > ?)"
+
+// Брони автомобиля, пересекающиеся с интервалом [from, to)
+func overlappingReservations(db *gorm.DB, from, to time.Time) *gorm.DB {
+	return db.Model(&models.Reservation{}).Select("1").
+		Where("reservations.car_uid = cars.car_uid AND reservations.date_from < ? AND reservations.date_to > ?", to, from).
+		Where(activeReservation, time.Now())
+}
+
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
+
+/*
+* Бронь интервала как compare-and-set: создаётся, только если интервал свободен.
+* Списанный или выведенный из строя автомобиль не бронируется,
+* пересечение с существующей бронью - ErrorReserved
+ */
+func (r *CarPostgres) ReserveCar(ctx context.Context, reservation models.Reservation) (*models.Reservation, error) {
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var car models.Car
+
+		// Строка автомобиля блокируется до конца транзакции, поэтому параллельные брони
+		// одного автомобиля проверяют пересечения по очереди и не могут занять один интервал
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+					Where("car_uid = ?", reservation.CarUID).First(&car).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return models.ErrorNotFound
+			}
+
+			return err
+		}
+
+		if car.RetiredAt != nil {
+			return models.ErrorRetired
+		}
+
+		if !car.Availability {
+			return models.ErrorOutOfService
+		}
+
+		var overlaps int64
+
+		if err := tx.Model(&models.Reservation{}).
+					Where("car_uid = ? AND date_from < ? AND date_to > ?", reservation.CarUID, reservation.DateTo, reservation.DateFrom).
+					Where(activeReservation, time.Now()).
+					Count(&overlaps).Error; err != nil {
+			return err
+		}
+
+		if overlaps > 0 {
+			return models.ErrorReserved
+		}
+
+		return tx.Create(&reservation).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &reservation, nil
+}
+
+/*
+* Подтверждение удержания арендой: бронь становится постоянной.
+* Повторное подтверждение той же арендой ничего не меняет
+ */
+func (r *CarPostgres) ConfirmReservation(ctx context.Context, carUid, reservationUid, rentalUid string) (*models.Reservation, error) {
+	result := r.DB.WithContext(ctx).Model(&models.Reservation{}).
+				Where("car_uid = ? AND reservation_uid = ?", carUid, reservationUid).
+				Where(activeReservation, time.Now()).
+				Where("rental_uid IS NULL OR rental_uid = ?", rentalUid).
+				Updates(map[string]interface{}{
+					"rental_uid":	rentalUid,
+					"expires_at":	nil,
+				})
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var reservation models.Reservation
+
+	if err := r.DB.WithContext(ctx).
+				Where("car_uid = ? AND reservation_uid = ?", carUid, reservationUid).
+				First(&reservation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrorNotFound
+		}
+
+		return nil, err
+	}
+
+	if result.RowsAffected > 0 {
+		return &reservation, nil
+	}
+
+	if reservation.ExpiresAt != nil {
+		return nil, models.ErrorHoldExpired
+	}
+
+	return nil, models.ErrorReserved
+}
+
+// Снятие брони идемпотентно: отсутствие брони не считается ошибкой
+func (r *CarPostgres) ReleaseReservation(ctx context.Context, carUid, reservationUid string) error {
+	return r.DB.WithContext(ctx).
+			Where("car_uid = ? AND reservation_uid = ?", carUid, reservationUid).
+			Delete(&models.Reservation{}).Error
+}
+
+func (r *CarPostgres) ReleaseRentalReservation(ctx context.Context, carUid, rentalUid string) error {
+	return r.DB.WithContext(ctx).
+			Where("car_uid = ? AND rental_uid = ?", carUid, rentalUid).
+			Delete(&models.Reservation{}).Error
+}
+
+// Снимает удержания, истёкшие к моменту now
+func (r *CarPostgres) DeleteExpiredHolds(ctx context.Context, now time.Time) (int64, error) {
+	result := r.DB.WithContext(ctx).
+				Where("expires_at <= ?", now).
+				Delete(&models.Reservation{})
+
+	return result.RowsAffected, result.Error
+}
+
+func (r *CarPostgres) GetReservations(ctx context.Context, carUid string, from, to time.Time) ([]models.Reservation, error) {
+	var reservations []models.Reservation
+
+	if err := r.DB.WithContext(ctx).
+				Where("car_uid = ? AND date_from < ? AND date_to > ?", carUid, to, from).
+				Where(activeReservation, time.Now()).
+				Order("date_from").
+				Find(&reservations).Error; err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
@@ -1,6 +1,11 @@
 package services
 
 import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/car/converters"
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/car/models"
 	repo "github.com/SwanPoi/bmstu_rsoi_lab2/src/car/repositories"
@@ -8,16 +13,20 @@ import (
 
 type CarService struct {
 	repo repo.ICarRepo
+	// Сколько живёт неподтверждённая бронь
+	holdTTL time.Duration
+	// Разрешены брони с датой начала в прошлом
+	allowPast bool
 }
 
-func NewCarService(repo repo.ICarRepo) *CarService {
-	return &CarService{repo: repo}
+func NewCarService(repo repo.ICarRepo, holdTTL time.Duration, allowPast bool) *CarService {
+	return &CarService{repo: repo, holdTTL: holdTTL, allowPast: allowPast}
 }
 
-func (s *CarService) GetCars(page int, size int, showAll bool) (*models.PaginationResponse, error) {
+func (s *CarService) GetCars(ctx context.Context, page int, size int, filter models.CarFilter) (*models.PaginationResponse, error) {
 	offset := (page - 1) * size
 
-	cars, total, err := s.repo.GetCars(offset, size, showAll)
+	cars, total, err := s.repo.GetCars(ctx, offset, size, filter)
 
 	if err != nil {
 		return nil, err
@@ -35,8 +44,8 @@ func (s *CarService) GetCars(page int, size int, showAll bool) (*models.Paginati
 	return paginationResponse, nil
 }
 
-func (s *CarService) GetCarByUid(uid string) (*models.ShortCar, error) {
-	car, err := s.repo.GetCarByUid(uid)
+func (s *CarService) GetCarByUid(ctx context.Context, uid string) (*models.ShortCar, error) {
+	car, err := s.repo.GetCarByUid(ctx, uid)
 
 	if err != nil {
 		return nil, err
@@ -47,8 +56,8 @@ func (s *CarService) GetCarByUid(uid string) (*models.ShortCar, error) {
 	return &shortCar, nil
 }
 
-func (s *CarService) GetCarsByUids(uids []string) ([]models.ShortCar, error) {
-	cars, err := s.repo.GetCarsByUids(uids)
+func (s *CarService) GetCarsByUids(ctx context.Context, uids []string) ([]models.ShortCar, error) {
+	cars, err := s.repo.GetCarsByUids(ctx, uids)
 
 	if err != nil {
 		return nil, err
@@ -63,8 +72,8 @@ func (s *CarService) GetCarsByUids(uids []string) ([]models.ShortCar, error) {
 	return shortCars, nil
 }
 
-func (s *CarService) UpdateCar(car models.CarUpsert, uid string) (*models.ShortCar, error) {
-	updatedCar, err := s.repo.UpdateCar(car, uid); 
+func (s *CarService) UpdateCar(ctx context.Context, car models.CarUpsert, uid string) (*models.ShortCar, error) {
+	updatedCar, err := s.repo.UpdateCar(ctx, car, uid); 
 	if err != nil {
 		return nil, err
 	}
@@ -72,4 +81,105 @@ func (s *CarService) UpdateCar(car models.CarUpsert, uid string) (*models.ShortC
 	shortCar := converters.CarToShortCar(*updatedCar)
 
 	return &shortCar, nil
+}
+
+func (s *CarService) CreateCar(ctx context.Context, req models.CarRequest) (*models.CarResponse, error) {
+	car := converters.CarFromRequest(req)
+	car.CarUID = uuid.New().String()
+	car.Availability = true
+
+	createdCar, err := s.repo.CreateCar(ctx, car)
+	if err != nil {
+		return nil, err
+	}
+
+	response := converters.CarResponseFromCar(*createdCar)
+
+	return &response, nil
+}
+
+func (s *CarService) ReplaceCar(ctx context.Context, req models.CarRequest, uid string) (*models.CarResponse, error) {
+	updatedCar, err := s.repo.ReplaceCar(ctx, req, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	response := converters.CarResponseFromCar(*updatedCar)
+
+	return &response, nil
+}
+
+func (s *CarService) RetireCar(ctx context.Context, uid string) error {
+	return s.repo.RetireCar(ctx, uid)
+}
+
+func (s *CarService) ReserveCar(ctx context.Context, uid string, from, to time.Time) (*models.ReservationResponse, error) {
+	if !s.allowPast && from.Before(time.Now().UTC().Truncate(24 * time.Hour)) {
+		return nil, models.ErrorPastDate
+	}
+
+	expiresAt := time.Now().Add(s.holdTTL)
+	reservation := models.Reservation{
+		ReservationUID: uuid.New().String(),
+		CarUID: uid,
+		DateFrom: from,
+		DateTo: to,
+		ExpiresAt: &expiresAt,
+	}
+
+	created, err := s.repo.ReserveCar(ctx, reservation)
+	if err != nil {
+		return nil, err
+	}
+
+	response := converters.ReservationResponseFromReservation(*created)
+
+	return &response, nil
+}
+
+func (s *CarService) ConfirmReservation(ctx context.Context, uid, reservationUid, rentalUid string) (*models.ReservationResponse, error) {
+	confirmed, err := s.repo.ConfirmReservation(ctx, uid, reservationUid, rentalUid)
+	if err != nil {
+		return nil, err
+	}
+
+	response := converters.ReservationResponseFromReservation(*confirmed)
+
+	return &response, nil
+}
+
+func (s *CarService) ReleaseReservation(ctx context.Context, uid, reservationUid string) error {
+	return s.repo.ReleaseReservation(ctx, uid, reservationUid)
+}
+
+func (s *CarService) ReleaseRentalReservation(ctx context.Context, uid, rentalUid string) error {
+	return s.repo.ReleaseRentalReservation(ctx, uid, rentalUid)
+}
+
+func (s *CarService) SweepExpiredHolds(ctx context.Context) (int64, error) {
+	return s.repo.DeleteExpiredHolds(ctx, time.Now())
+}
+
+func (s *CarService) GetCalendar(ctx context.Context, uid string, from, to time.Time) (*models.CarCalendar, error) {
+	if _, err := s.repo.GetCarByUid(ctx, uid); err != nil {
+		return nil, err
+	}
+
+	reservations, err := s.repo.GetReservations(ctx, uid, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := &models.CarCalendar{
+		CarUID: uid,
+		From: from.Format(models.DateLayout),
+		To: to.Format(models.DateLayout),
+		Reservations: make([]models.ReservationResponse, len(reservations)),
+	}
+
+	for i, reservation := range reservations {
+		calendar.Reservations[i] = converters.ReservationResponseFromReservation(reservation)
+	}
+
+	return calendar, nil
 }
\ No newline at end of file
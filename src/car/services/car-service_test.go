@@ -1,8 +1,10 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -10,16 +12,18 @@ import (
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/car/models"
 )
 
+const testHoldTTL = 5 * time.Minute
+
 type MockCarRepository struct {
 	mock.Mock
 }
 
-func (m *MockCarRepository) GetCars(offset int, size int, showAll bool) ([]models.Car, int, error) {
-    args := m.Called(offset, size, showAll)
+func (m *MockCarRepository) GetCars(_ context.Context, offset int, size int, filter models.CarFilter) ([]models.Car, int, error) {
+    args := m.Called(offset, size, filter)
     return args.Get(0).([]models.Car), args.Get(1).(int), args.Error(2)
 }
 
-func (m *MockCarRepository) GetCarByUid(uid string) (*models.Car, error) {
+func (m *MockCarRepository) GetCarByUid(_ context.Context, uid string) (*models.Car, error) {
 	args := m.Called(uid)
 	if car := args.Get(0); car != nil {
 		return car.(*models.Car), args.Error(1)
@@ -27,12 +31,12 @@ func (m *MockCarRepository) GetCarByUid(uid string) (*models.Car, error) {
 	return nil, args.Error(1)
 }
 
-func (m *MockCarRepository) GetCarsByUids(uids []string) ([]models.Car, error) {
+func (m *MockCarRepository) GetCarsByUids(_ context.Context, uids []string) ([]models.Car, error) {
 	args := m.Called(uids)
 	return args.Get(0).([]models.Car), args.Error(1)
 }
 
-func (m *MockCarRepository) UpdateCar(car models.CarUpsert, uid string) (*models.Car, error) {
+func (m *MockCarRepository) UpdateCar(_ context.Context, car models.CarUpsert, uid string) (*models.Car, error) {
 	args := m.Called(car, uid)
 	if updatedCar := args.Get(0); updatedCar != nil {
 		return updatedCar.(*models.Car), args.Error(1)
@@ -40,10 +44,67 @@ func (m *MockCarRepository) UpdateCar(car models.CarUpsert, uid string) (*models
 	return nil, args.Error(1)
 }
 
+func (m *MockCarRepository) CreateCar(_ context.Context, car models.Car) (*models.Car, error) {
+	args := m.Called(car)
+	if createdCar := args.Get(0); createdCar != nil {
+		return createdCar.(*models.Car), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCarRepository) ReplaceCar(_ context.Context, car models.CarRequest, uid string) (*models.Car, error) {
+	args := m.Called(car, uid)
+	if updatedCar := args.Get(0); updatedCar != nil {
+		return updatedCar.(*models.Car), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCarRepository) RetireCar(_ context.Context, uid string) error {
+	args := m.Called(uid)
+	return args.Error(0)
+}
+
+func (m *MockCarRepository) ReserveCar(_ context.Context, reservation models.Reservation) (*models.Reservation, error) {
+	args := m.Called(reservation)
+	if created := args.Get(0); created != nil {
+		return created.(*models.Reservation), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCarRepository) ConfirmReservation(_ context.Context, carUid, reservationUid, rentalUid string) (*models.Reservation, error) {
+	args := m.Called(carUid, reservationUid, rentalUid)
+	if confirmed := args.Get(0); confirmed != nil {
+		return confirmed.(*models.Reservation), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCarRepository) ReleaseReservation(_ context.Context, carUid, reservationUid string) error {
+	args := m.Called(carUid, reservationUid)
+	return args.Error(0)
+}
+
+func (m *MockCarRepository) ReleaseRentalReservation(_ context.Context, carUid, rentalUid string) error {
+	args := m.Called(carUid, rentalUid)
+	return args.Error(0)
+}
+
+func (m *MockCarRepository) DeleteExpiredHolds(_ context.Context, now time.Time) (int64, error) {
+	args := m.Called(now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCarRepository) GetReservations(_ context.Context, carUid string, from, to time.Time) ([]models.Reservation, error) {
+	args := m.Called(carUid, from, to)
+	return args.Get(0).([]models.Reservation), args.Error(1)
+}
+
 // Тест: GetCars успешно возвращает пагинированный список автомобилей (showAll = false)
 func TestCarService_GetCars_Success_ShowAllFalse(t *testing.T) {
 	mockRepo := new(MockCarRepository)
-	service := NewCarService(mockRepo)
+	service := NewCarService(mockRepo, testHoldTTL, false)
 
 	page := 1
 	size := 10
@@ -57,9 +118,9 @@ func TestCarService_GetCars_Success_ShowAllFalse(t *testing.T) {
 	total := 2
 	carsResponse := converters.CarResponsesFromCars(cars)
 
-	mockRepo.On("GetCars", offset, size, showAll).Return(cars, total, nil)
+	mockRepo.On("GetCars", offset, size, models.CarFilter{ShowAll: showAll}).Return(cars, total, nil)
 
-	result, err := service.GetCars(page, size, showAll)
+	result, err := service.GetCars(context.Background(), page, size, models.CarFilter{ShowAll: showAll})
 
 	assert.Nil(t, err)
 	assert.Equal(t, page, result.Page)
@@ -72,7 +133,7 @@ func TestCarService_GetCars_Success_ShowAllFalse(t *testing.T) {
 // Тест: GetCars успешно возвращает пагинированный список автомобилей (showAll = true)
 func TestCarService_GetCars_Success_ShowAllTrue(t *testing.T) {
 	mockRepo := new(MockCarRepository)
-	service := NewCarService(mockRepo)
+	service := NewCarService(mockRepo, testHoldTTL, false)
 
 	page := 2
 	size := 5
@@ -86,9 +147,9 @@ func TestCarService_GetCars_Success_ShowAllTrue(t *testing.T) {
 	total := 15
 	carsResponse := converters.CarResponsesFromCars(cars)
 
-	mockRepo.On("GetCars", offset, size, showAll).Return(cars, total, nil)
+	mockRepo.On("GetCars", offset, size, models.CarFilter{ShowAll: showAll}).Return(cars, total, nil)
 
-	result, err := service.GetCars(page, size, showAll)
+	result, err := service.GetCars(context.Background(), page, size, models.CarFilter{ShowAll: showAll})
 
 	assert.Nil(t, err)
 	assert.Equal(t, page, result.Page)
@@ -98,10 +159,37 @@ func TestCarService_GetCars_Success_ShowAllTrue(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+// Тест: GetCars передаёт фильтры и сортировку в репозиторий без изменений
+func TestCarService_GetCars_WithFilter(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	maxPrice, minPower := 5000, 200
+	filter := models.CarFilter{
+		Types:		[]string{"SUV"},
+		MaxPrice:	&maxPrice,
+		MinPower:	&minPower,
+		SortBy:		"price",
+		SortOrder:	models.SortDesc,
+	}
+	cars := []models.Car{
+		{CarUID: "uid5", Brand: "BMW", Model: "X5", Type: "SUV", Power: 250, Price: 4500, Availability: true},
+	}
+
+	mockRepo.On("GetCars", 0, 10, filter).Return(cars, 1, nil)
+
+	result, err := service.GetCars(context.Background(), 1, 10, filter)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result.TotalElements)
+	assert.Equal(t, converters.CarResponsesFromCars(cars), result.Items)
+	mockRepo.AssertExpectations(t)
+}
+
 // Тест: GetCarByUid успешно возвращает автомобиль
 func TestCarService_GetCarByUid_Success(t *testing.T) {
 	mockRepo := new(MockCarRepository)
-	service := NewCarService(mockRepo)
+	service := NewCarService(mockRepo, testHoldTTL, false)
 
 	uid := "test-uid"
 	car := &models.Car{
@@ -114,7 +202,7 @@ func TestCarService_GetCarByUid_Success(t *testing.T) {
 
 	mockRepo.On("GetCarByUid", uid).Return(car, nil)
 
-	result, err := service.GetCarByUid(uid)
+	result, err := service.GetCarByUid(context.Background(), uid)
 
 	assert.Nil(t, err)
 	assert.Equal(t, expectedShortCar, *result)
@@ -124,14 +212,14 @@ func TestCarService_GetCarByUid_Success(t *testing.T) {
 // Тест: GetCarByUid возвращает ошибку из репозитория
 func TestCarService_GetCarByUid_RepoError(t *testing.T) {
 	mockRepo := new(MockCarRepository)
-	service := NewCarService(mockRepo)
+	service := NewCarService(mockRepo, testHoldTTL, false)
 
 	uid := "test-uid"
 	expectedError := errors.New("car not found")
 
 	mockRepo.On("GetCarByUid", uid).Return((*models.Car)(nil), expectedError)
 
-	_, err := service.GetCarByUid(uid)
+	_, err := service.GetCarByUid(context.Background(), uid)
 
 	assert.True(t, errors.Is(err, expectedError))
 	mockRepo.AssertExpectations(t)
@@ -140,7 +228,7 @@ func TestCarService_GetCarByUid_RepoError(t *testing.T) {
 // Тест: GetCarsByUids успешно возвращает список автомобилей
 func TestCarService_GetCarsByUids_Success(t *testing.T) {
 	mockRepo := new(MockCarRepository)
-	service := NewCarService(mockRepo)
+	service := NewCarService(mockRepo, testHoldTTL, false)
 
 	uids := []string{"uid1", "uid2", "uid3"}
 	cars := []models.Car{
@@ -156,7 +244,7 @@ func TestCarService_GetCarsByUids_Success(t *testing.T) {
 
 	mockRepo.On("GetCarsByUids", uids).Return(cars, nil)
 
-	result, err := service.GetCarsByUids(uids)
+	result, err := service.GetCarsByUids(context.Background(), uids)
 
 	assert.Nil(t, err)
 	assert.Equal(t, expectedShortCars, result)
@@ -166,14 +254,14 @@ func TestCarService_GetCarsByUids_Success(t *testing.T) {
 // Тест: GetCarsByUids возвращает ошибку из репозитория
 func TestCarService_GetCarsByUids_RepoError(t *testing.T) {
 	mockRepo := new(MockCarRepository)
-	service := NewCarService(mockRepo)
+	service := NewCarService(mockRepo, testHoldTTL, false)
 
 	uids := []string{"uid1", "uid2"}
 	expectedError := errors.New("database error")
 
 	mockRepo.On("GetCarsByUids", uids).Return([]models.Car{}, expectedError)
 
-	_, err := service.GetCarsByUids(uids)
+	_, err := service.GetCarsByUids(context.Background(), uids)
 
 	assert.True(t, errors.Is(err, expectedError))
 	mockRepo.AssertExpectations(t)
@@ -182,7 +270,7 @@ func TestCarService_GetCarsByUids_RepoError(t *testing.T) {
 // Тест: UpdateCar успешно обновляет автомобиль
 func TestCarService_UpdateCar_Success(t *testing.T) {
 	mockRepo := new(MockCarRepository)
-	service := NewCarService(mockRepo)
+	service := NewCarService(mockRepo, testHoldTTL, false)
 
 	uid := "test-uid"
 	carUpsert := models.CarUpsert{
@@ -198,7 +286,7 @@ func TestCarService_UpdateCar_Success(t *testing.T) {
 
 	mockRepo.On("UpdateCar", carUpsert, uid).Return(updatedCar, nil)
 
-	result, err := service.UpdateCar(carUpsert, uid)
+	result, err := service.UpdateCar(context.Background(), carUpsert, uid)
 
 	assert.Nil(t, err)
 	assert.Equal(t, expectedShortCar, *result)
@@ -208,7 +296,7 @@ func TestCarService_UpdateCar_Success(t *testing.T) {
 // Тест: UpdateCar возвращает ошибку из репозитория
 func TestCarService_UpdateCar_RepoError(t *testing.T) {
 	mockRepo := new(MockCarRepository)
-	service := NewCarService(mockRepo)
+	service := NewCarService(mockRepo, testHoldTTL, false)
 
 	uid := "test-uid"
 	carUpsert := models.CarUpsert{
@@ -218,8 +306,290 @@ func TestCarService_UpdateCar_RepoError(t *testing.T) {
 
 	mockRepo.On("UpdateCar", carUpsert, uid).Return((*models.Car)(nil), expectedError)
 
-	_, err := service.UpdateCar(carUpsert, uid)
+	_, err := service.UpdateCar(context.Background(), carUpsert, uid)
 
 	assert.True(t, errors.Is(err, expectedError))
 	mockRepo.AssertExpectations(t)
-}
\ No newline at end of file
+}
+
+// Тест: CreateCar выдаёт новый car_uid и добавляет автомобиль доступным
+func TestCarService_CreateCar_Success(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	req := models.CarRequest{
+		Brand: "Toyota",
+		Model: "Camry",
+		RegistrationNumber: "А123ВС77",
+		Power: 181,
+		Price: 3000,
+		Type: "SEDAN",
+	}
+
+	var created models.Car
+	mockRepo.On("CreateCar", mock.MatchedBy(func(car models.Car) bool {
+		created = car
+		return car.CarUID != "" && car.Availability && car.RetiredAt == nil && car.Brand == req.Brand
+	})).Return(&created, nil)
+
+	result, err := service.CreateCar(context.Background(), req)
+
+	assert.Nil(t, err)
+	assert.Equal(t, converters.CarResponseFromCar(created), *result)
+	assert.True(t, result.Available)
+	mockRepo.AssertExpectations(t)
+}
+
+// Тест: CreateCar возвращает ошибку о занятом регистрационном номере
+func TestCarService_CreateCar_DuplicateRegistration(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	mockRepo.On("CreateCar", mock.AnythingOfType("models.Car")).Return((*models.Car)(nil), models.ErrorAlreadyExists)
+
+	_, err := service.CreateCar(context.Background(), models.CarRequest{RegistrationNumber: "А123ВС77"})
+
+	assert.True(t, errors.Is(err, models.ErrorAlreadyExists))
+	mockRepo.AssertExpectations(t)
+}
+
+// Тест: ReplaceCar возвращает обновлённый автомобиль
+func TestCarService_ReplaceCar_Success(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	uid := "test-uid"
+	req := models.CarRequest{Brand: "BMW", Model: "X5", RegistrationNumber: "В456ОР77", Power: 340, Price: 6000, Type: "SUV"}
+	updatedCar := &models.Car{CarUID: uid, Brand: "BMW", Model: "X5", RegistrationNumber: "В456ОР77", Power: 340, Price: 6000, Type: "SUV", Availability: true}
+
+	mockRepo.On("ReplaceCar", req, uid).Return(updatedCar, nil)
+
+	result, err := service.ReplaceCar(context.Background(), req, uid)
+
+	assert.Nil(t, err)
+	assert.Equal(t, converters.CarResponseFromCar(*updatedCar), *result)
+	mockRepo.AssertExpectations(t)
+}
+
+// Тест: ReplaceCar не меняет списанный автомобиль
+func TestCarService_ReplaceCar_Retired(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	req := models.CarRequest{Brand: "BMW", Model: "X5", RegistrationNumber: "В456ОР77", Power: 340, Price: 6000, Type: "SUV"}
+
+	mockRepo.On("ReplaceCar", req, "test-uid").Return((*models.Car)(nil), models.ErrorRetired)
+
+	result, err := service.ReplaceCar(context.Background(), req, "test-uid")
+
+	assert.Nil(t, result)
+	assert.True(t, errors.Is(err, models.ErrorRetired))
+	mockRepo.AssertExpectations(t)
+}
+
+// Тест: RetireCar передаёт отказ для арендованного автомобиля
+func TestCarService_RetireCar_Rented(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	mockRepo.On("RetireCar", "test-uid").Return(models.ErrorCarRented)
+
+	err := service.RetireCar(context.Background(), "test-uid")
+
+	assert.True(t, errors.Is(err, models.ErrorCarRented))
+	mockRepo.AssertExpectations(t)
+}
+
+// Тест: GetCars помечает недоступными автомобили, занятые в окне фильтра
+func TestCarService_GetCars_BookedInWindow(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	from := time.Date(2021, 10, 8, 0, 0, 0, 0, time.UTC)
+	filter := models.CarFilter{ShowAll: true, AvailableFrom: from, AvailableTo: from.AddDate(0, 0, 3)}
+	cars := []models.Car{
+		{CarUID: "uid1", Availability: true, Booked: true},
+		{CarUID: "uid2", Availability: true},
+	}
+
+	mockRepo.On("GetCars", 0, 10, filter).Return(cars, 2, nil)
+
+	result, err := service.GetCars(context.Background(), 1, 10, filter)
+
+	assert.Nil(t, err)
+	assert.False(t, result.Items[0].Available)
+	assert.True(t, result.Items[1].Available)
+	mockRepo.AssertExpectations(t)
+}
+
+// Тест: ReserveCar создаёт удержание на запрошенный интервал со сроком holdTTL
+func TestCarService_ReserveCar_Success(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	from := time.Now().UTC().Truncate(24 * time.Hour).AddDate(0, 0, 7)
+	to := from.AddDate(0, 0, 3)
+	before := time.Now()
+
+	var created models.Reservation
+	mockRepo.On("ReserveCar", mock.MatchedBy(func(reservation models.Reservation) bool {
+		created = reservation
+		return reservation.ReservationUID != "" && reservation.CarUID == "test-uid" &&
+			reservation.DateFrom.Equal(from) && reservation.DateTo.Equal(to) &&
+			reservation.ExpiresAt != nil && reservation.RentalUID == nil
+	})).Return(&created, nil)
+
+	result, err := service.ReserveCar(context.Background(), "test-uid", from, to)
+
+	assert.Nil(t, err)
+	assert.Equal(t, models.ReservationResponse{
+		ReservationUID: created.ReservationUID,
+		CarUID: "test-uid",
+		DateFrom: from.Format(models.DateLayout),
+		DateTo: to.Format(models.DateLayout),
+		ExpiresAt: created.ExpiresAt,
+	}, *result)
+	assert.WithinDuration(t, before.Add(testHoldTTL), *result.ExpiresAt, time.Second)
+	mockRepo.AssertExpectations(t)
+}
+
+// Тест: бронь с датой начала раньше сегодняшней отклоняется без обращения к БД
+func TestCarService_ReserveCar_PastDate(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	from := time.Now().UTC().Truncate(24 * time.Hour).AddDate(0, 0, -1)
+
+	result, err := service.ReserveCar(context.Background(), "test-uid", from, from.AddDate(0, 0, 3))
+
+	assert.Nil(t, result)
+	assert.True(t, errors.Is(err, models.ErrorPastDate))
+	mockRepo.AssertNotCalled(t, "ReserveCar", mock.Anything)
+}
+
+// Тест: с allowPast бронь в прошлом доходит до репозитория
+func TestCarService_ReserveCar_PastDateAllowed(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, true)
+
+	from := time.Date(2021, 10, 8, 0, 0, 0, 0, time.UTC)
+
+	mockRepo.On("ReserveCar", mock.AnythingOfType("models.Reservation")).Return((*models.Reservation)(nil), models.ErrorReserved)
+
+	_, err := service.ReserveCar(context.Background(), "test-uid", from, from.AddDate(0, 0, 3))
+
+	assert.True(t, errors.Is(err, models.ErrorReserved))
+	mockRepo.AssertExpectations(t)
+}
+
+// Тест: ReserveCar возвращает ошибку при пересечении с существующей бронью
+func TestCarService_ReserveCar_Overlap(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	from := time.Now().UTC().Truncate(24 * time.Hour).AddDate(0, 0, 7)
+
+	mockRepo.On("ReserveCar", mock.AnythingOfType("models.Reservation")).Return((*models.Reservation)(nil), models.ErrorReserved)
+
+	result, err := service.ReserveCar(context.Background(), "test-uid", from, from.AddDate(0, 0, 3))
+
+	assert.Nil(t, result)
+	assert.True(t, errors.Is(err, models.ErrorReserved))
+	mockRepo.AssertExpectations(t)
+}
+
+// Тест: GetCalendar возвращает брони автомобиля в запрошенном окне
+func TestCarService_GetCalendar_Success(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	from := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 30)
+	reservations := []models.Reservation{
+		{ReservationUID: "r-1", CarUID: "test-uid", DateFrom: from.AddDate(0, 0, 7), DateTo: from.AddDate(0, 0, 10)},
+		{ReservationUID: "r-2", CarUID: "test-uid", DateFrom: from.AddDate(0, 0, 12), DateTo: from.AddDate(0, 0, 14)},
+	}
+
+	mockRepo.On("GetCarByUid", "test-uid").Return(&models.Car{CarUID: "test-uid"}, nil)
+	mockRepo.On("GetReservations", "test-uid", from, to).Return(reservations, nil)
+
+	result, err := service.GetCalendar(context.Background(), "test-uid", from, to)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "2021-10-01", result.From)
+	assert.Equal(t, "2021-10-31", result.To)
+	assert.Len(t, result.Reservations, 2)
+	assert.Equal(t, "2021-10-08", result.Reservations[0].DateFrom)
+	assert.Equal(t, "2021-10-11", result.Reservations[0].DateTo)
+	mockRepo.AssertExpectations(t)
+}
+
+// Тест: GetCalendar не ищет брони несуществующего автомобиля
+func TestCarService_GetCalendar_CarNotFound(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	from := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	mockRepo.On("GetCarByUid", "test-uid").Return(nil, models.ErrorNotFound)
+
+	_, err := service.GetCalendar(context.Background(), "test-uid", from, from.AddDate(0, 0, 30))
+
+	assert.True(t, errors.Is(err, models.ErrorNotFound))
+	mockRepo.AssertNotCalled(t, "GetReservations", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Тест: подтверждённая бронь привязана к аренде и больше не истекает
+func TestCarService_ConfirmReservation_Success(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	rentalUid := "rental-uid"
+	confirmed := &models.Reservation{
+		ReservationUID: "res-uid",
+		CarUID: "test-uid",
+		DateFrom: time.Date(2021, 10, 8, 0, 0, 0, 0, time.UTC),
+		DateTo: time.Date(2021, 10, 11, 0, 0, 0, 0, time.UTC),
+		RentalUID: &rentalUid,
+	}
+
+	mockRepo.On("ConfirmReservation", "test-uid", "res-uid", rentalUid).Return(confirmed, nil)
+
+	result, err := service.ConfirmReservation(context.Background(), "test-uid", "res-uid", rentalUid)
+
+	assert.Nil(t, err)
+	assert.Equal(t, rentalUid, result.RentalUID)
+	assert.Nil(t, result.ExpiresAt)
+	mockRepo.AssertExpectations(t)
+}
+
+// Тест: истёкшее удержание нельзя подтвердить
+func TestCarService_ConfirmReservation_Expired(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	mockRepo.On("ConfirmReservation", "test-uid", "res-uid", "rental-uid").Return(nil, models.ErrorHoldExpired)
+
+	result, err := service.ConfirmReservation(context.Background(), "test-uid", "res-uid", "rental-uid")
+
+	assert.Nil(t, result)
+	assert.True(t, errors.Is(err, models.ErrorHoldExpired))
+	mockRepo.AssertExpectations(t)
+}
+
+// Тест: sweeper снимает удержания, истёкшие к текущему моменту
+func TestCarService_SweepExpiredHolds(t *testing.T) {
+	mockRepo := new(MockCarRepository)
+	service := NewCarService(mockRepo, testHoldTTL, false)
+
+	before := time.Now()
+	mockRepo.On("DeleteExpiredHolds", mock.MatchedBy(func(now time.Time) bool {
+		return !now.Before(before) && now.Before(before.Add(time.Second))
+	})).Return(int64(2), nil)
+
+	swept, err := service.SweepExpiredHolds(context.Background())
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, swept)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+)
+
+// Периодически снимает просроченные удержания, чтобы бронь, брошенная
+// упавшим gateway, не занимала автомобиль
+func StartHoldSweeper(service ICarService, interval time.Duration) {
+	go func() {
+		for {
+			swept, err := service.SweepExpiredHolds(context.Background())
+			if err != nil {
+				slog.Error("reservation sweep error", logging.Err(err))
+			} else if swept > 0 {
+				slog.Info("expired reservation holds released", "count", swept)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
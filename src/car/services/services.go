@@ -1,23 +1,35 @@
 package services
 
 import (
+	"context"
+	"time"
+
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/car/models"
 	repo "github.com/SwanPoi/bmstu_rsoi_lab2/src/car/repositories"
 )
 
 type ICarService interface {
-	GetCars(page int, size int, showAll bool) (*models.PaginationResponse, error)
-	GetCarByUid(uuid string) (*models.ShortCar, error)
-	GetCarsByUids([]string) ([]models.ShortCar, error)
-	UpdateCar(models.CarUpsert, string) (*models.ShortCar, error)
+	GetCars(ctx context.Context, page int, size int, filter models.CarFilter) (*models.PaginationResponse, error)
+	GetCarByUid(ctx context.Context, uuid string) (*models.ShortCar, error)
+	GetCarsByUids(context.Context, []string) ([]models.ShortCar, error)
+	UpdateCar(context.Context, models.CarUpsert, string) (*models.ShortCar, error)
+	CreateCar(context.Context, models.CarRequest) (*models.CarResponse, error)
+	ReplaceCar(context.Context, models.CarRequest, string) (*models.CarResponse, error)
+	RetireCar(context.Context, string) error
+	ReserveCar(ctx context.Context, uid string, from, to time.Time) (*models.ReservationResponse, error)
+	ConfirmReservation(ctx context.Context, uid, reservationUid, rentalUid string) (*models.ReservationResponse, error)
+	ReleaseReservation(ctx context.Context, uid, reservationUid string) error
+	ReleaseRentalReservation(ctx context.Context, uid, rentalUid string) error
+	SweepExpiredHolds(ctx context.Context) (int64, error)
+	GetCalendar(ctx context.Context, uid string, from, to time.Time) (*models.CarCalendar, error)
 }
 
 type Services struct {
 	ICarService
 }
 
-func NewServices(repo repo.ICarRepo) *Services {
+func NewServices(repo repo.ICarRepo, holdTTL time.Duration, allowPast bool) *Services {
 	return &Services{
-		ICarService: NewCarService(repo),
+		ICarService: NewCarService(repo, holdTTL, allowPast),
 	}
 }
\ No newline at end of file
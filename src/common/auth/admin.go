@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Тело ответа совпадает с ErrorResponse сервисов
+type errorResponse struct {
+	Message string `json:"message"`
+}
+
+// Проверка токена администратора из заголовка Authorization: Bearer <token>.
+// Без токена защищённые маршруты отключены и отвечают 403 с disabledMessage
+func Admin(token, disabledMessage string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if token == "" {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse{Message: disabledMessage})
+			return
+		}
+
+		provided := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse{Message: "Admin credential is invalid"})
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func request(token, header string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/manage", Admin(token, "Admin API is disabled"), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/manage", nil)
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// Тест: без настроенного токена маршрут отключён
+func TestAdmin_Disabled(t *testing.T) {
+	recorder := request("", "Bearer ")
+
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.JSONEq(t, `{"message": "Admin API is disabled"}`, recorder.Body.String())
+}
+
+// Тест: запрос проходит только с верным токеном
+func TestAdmin_Token(t *testing.T) {
+	assert.Equal(t, http.StatusUnauthorized, request("secret", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request("secret", "Bearer wrong").Code)
+	assert.Equal(t, http.StatusOK, request("secret", "Bearer secret").Code)
+}
@@ -0,0 +1,80 @@
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Оставшееся время на обработку запроса в миллисекундах. Передаётся
+// относительным значением, чтобы не зависеть от расхождения часов
+const HeaderTimeout = "X-Request-Timeout-Ms"
+
+// Бюджет времени на маршрут: "METHOD /route" -> длительность, для
+// остальных маршрутов используется Default
+type Budgets struct {
+	Default	time.Duration
+	Routes	map[string]time.Duration
+}
+
+func (b Budgets) For(method, route string) time.Duration {
+	if budget, ok := b.Routes[method+" "+route]; ok {
+		return budget
+	}
+	return b.Default
+}
+
+// Ограничивает контекст запроса бюджетом маршрута, а если вызывающий
+// передал меньший остаток в заголовке - этим остатком. Исходящие вызовы,
+// созданные от контекста запроса, прерываются вместе с ним, в том числе
+// когда клиент закрыл соединение. В сервисах бюджетов нет, и остаток
+// из заголовка доходит через WithContext до запросов gorm
+func Middleware(budgets Budgets) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		timeout := budgets.For(ctx.Request.Method, ctx.FullPath())
+		if remaining, ok := FromHeader(ctx.Request.Header); ok && (timeout <= 0 || remaining < timeout) {
+			timeout = remaining
+		}
+
+		if timeout <= 0 {
+			ctx.Next()
+			return
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+		defer cancel()
+
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+		ctx.Next()
+	}
+}
+
+// Остаток времени из заголовка запроса
+func FromHeader(header http.Header) (time.Duration, bool) {
+	value := header.Get(HeaderTimeout)
+	if value == "" {
+		return 0, false
+	}
+
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// Передаёт оставшееся до дедлайна контекста время в исходящий запрос
+func Inject(ctx context.Context, header http.Header) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+
+	// Меньше миллисекунды округляется вверх, иначе заголовок был бы отброшен
+	ms := max(time.Until(deadline).Milliseconds(), 1)
+	header.Set(HeaderTimeout, strconv.FormatInt(ms, 10))
+}
@@ -0,0 +1,88 @@
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func remainingFor(t *testing.T, budgets Budgets, method, path string, incoming string) (time.Duration, bool) {
+	var remaining time.Duration
+	var hasDeadline bool
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware(budgets))
+	handler := func(ctx *gin.Context) {
+		var deadline time.Time
+		deadline, hasDeadline = ctx.Request.Context().Deadline()
+		remaining = time.Until(deadline)
+		ctx.Status(http.StatusOK)
+	}
+	router.GET("/api/v1/cars", handler)
+	router.POST("/api/v1/rental", handler)
+
+	req := httptest.NewRequest(method, path, nil)
+	if incoming != "" {
+		req.Header.Set(HeaderTimeout, incoming)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	return remaining, hasDeadline
+}
+
+// Тест: маршрут получает свой бюджет, остальные - бюджет по умолчанию
+func TestMiddleware_RouteBudgets(t *testing.T) {
+	budgets := Budgets{Default: time.Second, Routes: map[string]time.Duration{"POST /api/v1/rental": 5 * time.Second}}
+
+	remaining, ok := remainingFor(t, budgets, http.MethodGet, "/api/v1/cars", "")
+	require.True(t, ok)
+	assert.InDelta(t, time.Second, remaining, float64(100*time.Millisecond))
+
+	remaining, ok = remainingFor(t, budgets, http.MethodPost, "/api/v1/rental", "")
+	require.True(t, ok)
+	assert.InDelta(t, 5*time.Second, remaining, float64(100*time.Millisecond))
+}
+
+// Тест: меньший остаток из заголовка вызывающего сокращает бюджет, больший - нет
+func TestMiddleware_IncomingHeader(t *testing.T) {
+	budgets := Budgets{Default: time.Second}
+
+	remaining, ok := remainingFor(t, budgets, http.MethodGet, "/api/v1/cars", "200")
+	require.True(t, ok)
+	assert.InDelta(t, 200*time.Millisecond, remaining, float64(50*time.Millisecond))
+
+	remaining, _ = remainingFor(t, budgets, http.MethodGet, "/api/v1/cars", "60000")
+	assert.InDelta(t, time.Second, remaining, float64(100*time.Millisecond))
+
+	_, ok = remainingFor(t, Budgets{}, http.MethodGet, "/api/v1/cars", "")
+	assert.False(t, ok)
+}
+
+// Тест: в исходящий запрос передаётся оставшееся до дедлайна время
+func TestInject(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	header := http.Header{}
+
+	Inject(req.Context(), header)
+	assert.Empty(t, header.Get(HeaderTimeout))
+
+	ctx, cancel := context.WithTimeout(req.Context(), 1500*time.Millisecond)
+	defer cancel()
+
+	Inject(ctx, header)
+	ms, err := strconv.Atoi(header.Get(HeaderTimeout))
+	require.NoError(t, err)
+	assert.InDelta(t, 1500, ms, 50)
+
+	remaining, ok := FromHeader(header)
+	require.True(t, ok)
+	assert.Equal(t, time.Duration(ms)*time.Millisecond, remaining)
+}
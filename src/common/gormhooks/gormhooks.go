@@ -0,0 +1,42 @@
+package gormhooks
+
+import (
+	"gorm.io/gorm"
+)
+
+// Регистрирует пару callback'ов вокруг каждой операции gorm.
+// Имена callback'ов - "<name>:before_<operation>" и "<name>:after_<operation>"
+func register(db *gorm.DB, name string, before func(operation string) func(*gorm.DB), after func(operation string) func(*gorm.DB)) error {
+	callback := db.Callback()
+
+	operations := []struct {
+		name	string
+		before	func(string, func(*gorm.DB)) error
+		after	func(string, func(*gorm.DB)) error
+	}{
+		{"create", callback.Create().Before("gorm:create").Register, callback.Create().After("gorm:create").Register},
+		{"query", callback.Query().Before("gorm:query").Register, callback.Query().After("gorm:query").Register},
+		{"update", callback.Update().Before("gorm:update").Register, callback.Update().After("gorm:update").Register},
+		{"delete", callback.Delete().Before("gorm:delete").Register, callback.Delete().After("gorm:delete").Register},
+		{"row", callback.Row().Before("gorm:row").Register, callback.Row().After("gorm:row").Register},
+		{"raw", callback.Raw().Before("gorm:raw").Register, callback.Raw().After("gorm:raw").Register},
+	}
+
+	for _, op := range operations {
+		if err := op.before(name+":before_"+op.name, before(op.name)); err != nil {
+			return err
+		}
+		if err := op.after(name+":after_"+op.name, after(op.name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func tableOf(db *gorm.DB) string {
+	if db.Statement.Table == "" {
+		return "unknown"
+	}
+	return db.Statement.Table
+}
@@ -0,0 +1,36 @@
+package gormhooks
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/metrics"
+)
+
+const startedAtKey = "metrics:started_at"
+
+// Замеряет длительность запросов к БД
+func Metrics(db *gorm.DB) error {
+	return register(db, "metrics", func(string) func(*gorm.DB) { return startTimer }, observeQuery)
+}
+
+func startTimer(db *gorm.DB) {
+	db.InstanceSet(startedAtKey, time.Now())
+}
+
+func observeQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(startedAtKey)
+		if !ok {
+			return
+		}
+
+		startedAt, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+
+		metrics.ObserveQuery(operation, tableOf(db), time.Since(startedAt))
+	}
+}
@@ -0,0 +1,49 @@
+package gormhooks
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/tracing"
+)
+
+const spanKey = "tracing:span"
+
+// Создаёт дочерний span на каждый запрос к БД, если в контексте
+// запроса (db.WithContext) есть трасса
+func Tracing(db *gorm.DB) error {
+	return register(db, "tracing", startSpan, func(string) func(*gorm.DB) { return endSpan })
+}
+
+func startSpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil || !tracing.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+
+		_, span := tracing.Start(ctx, "gorm."+operation, tracing.KindClient)
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func endSpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+
+	span, ok := value.(*tracing.Span)
+	if !ok {
+		return
+	}
+
+	span.SetAttribute("db.system", "postgresql")
+	span.SetAttribute("db.sql.table", tableOf(db))
+	// Отсутствие записи - штатный ответ, а не ошибка БД
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.SetError(db.Error)
+	}
+	span.End()
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const HeaderRequestID = "X-Request-ID"
+
+// Общие имена полей, по которым строки ищутся во всех сервисах
+const (
+	KeyService		= "service"
+	KeyRequestID	= "request_id"
+	KeyTraceID		= "trace_id"
+	KeyUser			= "user"
+	KeyRentalUID	= "rental_uid"
+	KeyCarUID		= "car_uid"
+	KeyPaymentUID	= "payment_uid"
+	KeyDownstream	= "downstream"
+	KeyError		= "error"
+)
+
+type loggerKey struct{}
+
+// Настраивает JSON-логгер по умолчанию; стандартный log тоже пишет через него
+func Init(service, level string) {
+	slog.SetDefault(New(os.Stdout, service, level))
+}
+
+func New(out io.Writer, service, level string) *slog.Logger {
+	handler := slog.NewJSONHandler(out, &slog.HandlerOptions{Level: ParseLevel(level)})
+	return slog.New(handler).With(KeyService, service)
+}
+
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+		case "debug":
+			return slog.LevelDebug
+		case "warn", "warning":
+			return slog.LevelWarn
+		case "error":
+			return slog.LevelError
+		default:
+			return slog.LevelInfo
+	}
+}
+
+// Логгер запроса с request_id, пользователем и т.д., либо логгер по умолчанию
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Добавляет поля ко всем следующим строкам в рамках контекста
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.Attr{}
+	}
+	return slog.String(KeyError, err.Error())
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/tracing"
+)
+
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// Заменяет стандартное логирование gin: принимает или создаёт X-Request-ID,
+// кладёт в контекст логгер с полями запроса и пишет одну строку на запрос.
+// Должен стоять после tracing.Middleware, чтобы в строки попал trace_id
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		requestID := ctx.GetHeader(HeaderRequestID)
+		if !validRequestID(requestID) {
+			requestID = NewRequestID()
+		}
+		ctx.Header(HeaderRequestID, requestID)
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		args := []any{KeyRequestID, requestID, "method", ctx.Request.Method, "route", route}
+		if user := ctx.GetHeader("X-User-Name"); user != "" {
+			args = append(args, KeyUser, user)
+		}
+		if sc := tracing.SpanContextFromContext(ctx.Request.Context()); sc.IsValid() {
+			args = append(args, KeyTraceID, sc.TraceIDString())
+		}
+
+		reqCtx := context.WithValue(ctx.Request.Context(), requestIDKey{}, requestID)
+		reqCtx = WithLogger(reqCtx, slog.Default().With(args...))
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+			case status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			case strings.HasPrefix(route, "/manage/health") || strings.HasPrefix(route, "/manage/metrics"):
+				// Пробы и сбор метрик не засоряют лог
+				level = slog.LevelDebug
+		}
+
+		FromContext(ctx.Request.Context()).Log(ctx.Request.Context(), level, "request completed",
+			"path", ctx.Request.URL.Path,
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", ctx.ClientIP(),
+		)
+	}
+}
+
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// Контекст с известным request_id, например при повторе запроса из очереди
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+	return With(ctx, KeyRequestID, requestID)
+}
+
+// Передаёт request_id в исходящий запрос
+func Inject(ctx context.Context, header http.Header) {
+	if requestID := RequestID(ctx); requestID != "" {
+		header.Set(HeaderRequestID, requestID)
+	}
+}
+
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strings.ReplaceAll(time.Now().Format("20060102150405.000000000"), ".", "")
+	}
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRouter(t *testing.T) (*gin.Engine, *bytes.Buffer, *string) {
+	var out bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(New(&out, "gateway", "info"))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	var forwarded string
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/rental/:uid", func(ctx *gin.Context) {
+		reqCtx := With(ctx.Request.Context(), KeyRentalUID, ctx.Param("uid"))
+		FromContext(reqCtx).Info("rental requested")
+
+		header := http.Header{}
+		Inject(reqCtx, header)
+		forwarded = header.Get(HeaderRequestID)
+
+		ctx.Status(http.StatusOK)
+	})
+
+	return router, &out, &forwarded
+}
+
+func readLines(t *testing.T, out *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, raw := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(raw, &line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Тест: входящий X-Request-ID сохраняется, попадает в ответ, строки лога и исходящие запросы
+func TestMiddleware_AcceptsRequestID(t *testing.T) {
+	router, out, forwarded := setupRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/rental/42", nil)
+	req.Header.Set(HeaderRequestID, "req-1")
+	req.Header.Set("X-User-Name", "Test Max")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "req-1", w.Header().Get(HeaderRequestID))
+	assert.Equal(t, "req-1", *forwarded)
+
+	lines := readLines(t, out)
+	require.Len(t, lines, 2)
+
+	assert.Equal(t, "rental requested", lines[0]["msg"])
+	assert.Equal(t, "42", lines[0][KeyRentalUID])
+
+	for _, line := range lines {
+		assert.Equal(t, "gateway", line[KeyService])
+		assert.Equal(t, "req-1", line[KeyRequestID])
+		assert.Equal(t, "Test Max", line[KeyUser])
+	}
+
+	assert.Equal(t, "request completed", lines[1]["msg"])
+	assert.Equal(t, float64(http.StatusOK), lines[1]["status"])
+	assert.Equal(t, "/rental/:uid", lines[1]["route"])
+}
+
+// Тест: без заголовка или с некорректным значением создаётся новый идентификатор
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	router, _, forwarded := setupRouter(t)
+
+	for _, incoming := range []string{"", "bad id\twith spaces"} {
+		req := httptest.NewRequest(http.MethodGet, "/rental/42", nil)
+		if incoming != "" {
+			req.Header.Set(HeaderRequestID, incoming)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		generated := w.Header().Get(HeaderRequestID)
+		assert.Len(t, generated, 32)
+		assert.NotEqual(t, incoming, generated)
+		assert.Equal(t, generated, *forwarded)
+	}
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var Registry = prometheus.NewRegistry()
+
+// Имена метрик без префикса сервиса, он добавляется в Init
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:		"http_requests_total",
+		Help:		"Number of handled HTTP requests.",
+	}, []string{"method", "route", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:		"http_request_duration_seconds",
+		Help:		"Duration of handled HTTP requests.",
+		Buckets:	prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:		"db_query_duration_seconds",
+		Help:		"Duration of gorm queries.",
+		Buckets:	[]float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+)
+
+// Регистрирует общие метрики с префиксом сервиса ("car_http_requests_total").
+// Вызывается один раз при старте
+func Init(service string) {
+	prometheus.WrapRegistererWithPrefix(service+"_", Registry).MustRegister(
+		requestsTotal,
+		requestDuration,
+		queryDuration,
+	)
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Считает запросы по шаблону маршрута, чтобы значения параметров не попадали в метки
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(ctx.Writer.Status())
+
+		requestsTotal.WithLabelValues(ctx.Request.Method, route, status).Inc()
+		requestDuration.WithLabelValues(ctx.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
+
+func ObserveQuery(operation, table string, duration time.Duration) {
+	queryDuration.WithLabelValues(operation, table).Observe(duration.Seconds())
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Тест: запросы считаются по шаблону маршрута, метрики доступны на /manage/metrics с префиксом сервиса
+func TestMiddleware_RecordsRouteTemplate(t *testing.T) {
+	Init("test")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/rental/:rentalUid", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	router.GET("/manage/metrics", Handler())
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/rental/1", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/rental/2", nil))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(requestsTotal.WithLabelValues("GET", "/rental/:rentalUid", "200")))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/manage/metrics", nil))
+	assert.True(t, strings.Contains(w.Body.String(), `test_http_requests_total{method="GET",route="/rental/:rentalUid",status="200"} 2`))
+}
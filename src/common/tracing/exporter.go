@@ -0,0 +1,240 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+type Exporter interface {
+	Export(span SpanData)
+}
+
+var (
+	exporterMu  sync.RWMutex
+	exporter    Exporter = NoopExporter{}
+	serviceName          = "unknown"
+)
+
+func SetExporter(e Exporter) {
+	exporterMu.Lock()
+	defer exporterMu.Unlock()
+
+	exporter = e
+}
+
+func currentExporter() Exporter {
+	exporterMu.RLock()
+	defer exporterMu.RUnlock()
+
+	return exporter
+}
+
+// Выбирает экспортёр по названию: none, stdout или otlp
+func Init(service, kind, endpoint string) {
+	exporterMu.Lock()
+	serviceName = service
+	exporterMu.Unlock()
+
+	switch kind {
+	case ExporterStdout:
+		SetExporter(NewStdoutExporter(os.Stdout))
+	case ExporterOTLP:
+		SetExporter(NewOTLPExporter(endpoint))
+	default:
+		SetExporter(NoopExporter{})
+	}
+}
+
+type NoopExporter struct{}
+
+func (NoopExporter) Export(SpanData) {}
+
+// Пишет каждый span отдельной строкой в формате OTLP JSON,
+// не требует коллектора
+type StdoutExporter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func NewStdoutExporter(out io.Writer) *StdoutExporter {
+	return &StdoutExporter{out: out}
+}
+
+func (e *StdoutExporter) Export(span SpanData) {
+	data, err := json.Marshal(otlpRequest([]SpanData{span}))
+	if err != nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.out.Write(append(data, '\n'))
+}
+
+const (
+	otlpBatchSize     = 100
+	otlpFlushInterval = 5 * time.Second
+	otlpQueueSize     = 2048
+)
+
+// Отправляет span'ы пачками в OTLP/HTTP коллектор (<endpoint>/v1/traces)
+type OTLPExporter struct {
+	url    string
+	client *http.Client
+	spans  chan SpanData
+}
+
+func NewOTLPExporter(endpoint string) *OTLPExporter {
+	e := &OTLPExporter{
+		url:    endpoint + "/v1/traces",
+		client: &http.Client{Timeout: 5 * time.Second},
+		spans:  make(chan SpanData, otlpQueueSize),
+	}
+
+	go e.run()
+
+	return e
+}
+
+func (e *OTLPExporter) Export(span SpanData) {
+	select {
+	case e.spans <- span:
+	default:
+		// Коллектор не успевает, span теряется
+	}
+}
+
+func (e *OTLPExporter) run() {
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, otlpBatchSize)
+	for {
+		select {
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) < otlpBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		e.send(batch)
+		batch = batch[:0]
+	}
+}
+
+func (e *OTLPExporter) send(batch []SpanData) {
+	data, err := json.Marshal(otlpRequest(batch))
+	if err != nil {
+		return
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		slog.Warn("trace export error", "error", err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		slog.Warn("trace export error", "status", resp.StatusCode)
+	}
+}
+
+type otlpKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+func keyValue(key, value string) otlpKeyValue {
+	kv := otlpKeyValue{Key: key}
+	kv.Value.StringValue = value
+	return kv
+}
+
+// Тело ExportTraceServiceRequest в JSON-представлении OTLP
+func otlpRequest(spans []SpanData) map[string]any {
+	exporterMu.RLock()
+	service := serviceName
+	exporterMu.RUnlock()
+
+	converted := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceID:           span.Context.TraceIDString(),
+			SpanID:            span.Context.SpanIDString(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+		}
+
+		if span.Parent.IsValid() {
+			s.ParentSpanID = span.Parent.SpanIDString()
+		}
+
+		for k, v := range span.Attributes {
+			s.Attributes = append(s.Attributes, keyValue(k, v))
+		}
+
+		if span.Error != "" {
+			s.Status = otlpStatus{Code: 2, Message: span.Error}
+		}
+
+		converted = append(converted, s)
+	}
+
+	return map[string]any{
+		"resourceSpans": []any{
+			map[string]any{
+				"resource": map[string]any{
+					"attributes": []otlpKeyValue{keyValue("service.name", service)},
+				},
+				"scopeSpans": []any{
+					map[string]any{
+						"scope": map[string]string{"name": "bmstu_rsoi/tracing"},
+						"spans": converted,
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Продолжает трассу из входящего traceparent и кладёт серверный span
+// в контекст запроса, откуда его берут исходящие вызовы и репозитории
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		reqCtx := ctx.Request.Context()
+		if remote, ok := ParseTraceParent(ctx.GetHeader(HeaderTraceParent)); ok {
+			reqCtx = ContextWithRemote(reqCtx, remote)
+		}
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		reqCtx, span := Start(reqCtx, ctx.Request.Method+" "+route, KindServer)
+		defer span.End()
+
+		span.SetAttribute("http.method", ctx.Request.Method)
+		span.SetAttribute("http.route", route)
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttribute("http.status_code", strconv.Itoa(status))
+		if status >= http.StatusInternalServerError {
+			span.SetError(errorStatus(status))
+		}
+	}
+}
+
+// Добавляет traceparent текущего span'а в заголовки исходящего запроса
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if sc.IsValid() {
+		header.Set(HeaderTraceParent, sc.TraceParent())
+	}
+}
+
+type errorStatus int
+
+func (e errorStatus) Error() string {
+	return http.StatusText(int(e))
+}
@@ -0,0 +1,208 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+const HeaderTraceParent = "traceparent"
+
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// Идентификаторы из заголовка W3C traceparent
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+func (sc SpanContext) TraceIDString() string {
+	return hex.EncodeToString(sc.TraceID[:])
+}
+
+func (sc SpanContext) SpanIDString() string {
+	return hex.EncodeToString(sc.SpanID[:])
+}
+
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceIDString() + "-" + sc.SpanIDString() + "-" + flags
+}
+
+// Формат: version-traceid-spanid-flags, например
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func ParseTraceParent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+
+	return sc, true
+}
+
+type Span struct {
+	mu         sync.Mutex
+	name       string
+	kind       int
+	context    SpanContext
+	parent     SpanContext
+	start      time.Time
+	attributes map[string]string
+	errMessage string
+	ended      bool
+}
+
+// Данные завершённого span'а, которые получает экспортёр
+type SpanData struct {
+	Name       string
+	Kind       int
+	Context    SpanContext
+	Parent     SpanContext
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]string
+	Error      string
+}
+
+func (s *Span) Context() SpanContext {
+	return s.context
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attributes[key] = value
+}
+
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errMessage = err.Error()
+}
+
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+
+	attributes := make(map[string]string, len(s.attributes))
+	for k, v := range s.attributes {
+		attributes[k] = v
+	}
+
+	data := SpanData{
+		Name:       s.name,
+		Kind:       s.kind,
+		Context:    s.context,
+		Parent:     s.parent,
+		Start:      s.start,
+		End:        time.Now(),
+		Attributes: attributes,
+		Error:      s.errMessage,
+	}
+	s.mu.Unlock()
+
+	if data.Context.Sampled {
+		currentExporter().Export(data)
+	}
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Контекст трассы, пришедший извне (заголовок или сохранённое сообщение)
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.context
+	}
+
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// Новый span продолжает трассу из ctx или начинает новую
+func Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	span := &Span{
+		name:       name,
+		kind:       kind,
+		parent:     parent,
+		start:      time.Now(),
+		attributes: map[string]string{},
+	}
+
+	if parent.IsValid() {
+		span.context.TraceID = parent.TraceID
+		span.context.Sampled = parent.Sampled
+	} else {
+		rand.Read(span.context.TraceID[:])
+		span.context.Sampled = true
+	}
+	rand.Read(span.context.SpanID[:])
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Значение traceparent для исходящего запроса, пустое - если трассы нет
+func TraceParent(ctx context.Context) string {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	return sc.TraceParent()
+}
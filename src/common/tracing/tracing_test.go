@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingExporter struct {
+	spans []SpanData
+}
+
+func (e *recordingExporter) Export(span SpanData) {
+	e.spans = append(e.spans, span)
+}
+
+// Тест: разбор и формирование заголовка traceparent
+func TestParseTraceParent(t *testing.T) {
+	value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, ok := ParseTraceParent(value)
+	require.True(t, ok)
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, value, sc.TraceParent())
+
+	for _, invalid := range []string{"", "00-123-456-01", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01"} {
+		_, ok := ParseTraceParent(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
+
+// Тест: middleware продолжает входящую трассу, дочерний span наследует trace id
+func TestMiddleware_ContinuesIncomingTrace(t *testing.T) {
+	exporter := &recordingExporter{}
+	SetExporter(exporter)
+	t.Cleanup(func() { SetExporter(NoopExporter{}) })
+
+	var outgoing string
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/cars/:uid", func(ctx *gin.Context) {
+		_, span := Start(ctx.Request.Context(), "child", KindClient)
+		header := http.Header{}
+		Inject(context.WithValue(ctx.Request.Context(), spanKey{}, span), header)
+		outgoing = header.Get(HeaderTraceParent)
+		span.End()
+		ctx.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/cars/1", nil)
+	req.Header.Set(HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Len(t, exporter.spans, 2)
+	child, server := exporter.spans[0], exporter.spans[1]
+
+	assert.Equal(t, "GET /cars/:uid", server.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.Context.TraceIDString())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanIDString())
+	assert.Equal(t, server.Context.SpanID, child.Parent.SpanID)
+
+	sc, ok := ParseTraceParent(outgoing)
+	require.True(t, ok)
+	assert.Equal(t, child.Context, sc)
+}
+
+// Тест: stdout-экспортёр пишет span в формате OTLP JSON
+func TestStdoutExporter_WritesOTLP(t *testing.T) {
+	var out bytes.Buffer
+	SetExporter(NewStdoutExporter(&out))
+	t.Cleanup(func() { SetExporter(NoopExporter{}) })
+
+	_, span := Start(context.Background(), "GET /cars", KindServer)
+	span.SetAttribute("http.status_code", "200")
+	span.End()
+
+	var body struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID string `json:"traceId"`
+					Name    string `json:"name"`
+					Kind    int    `json:"kind"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &body))
+
+	exported := body.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	assert.Equal(t, "GET /cars", exported.Name)
+	assert.Equal(t, KindServer, exported.Kind)
+	assert.Equal(t, span.Context().TraceIDString(), exported.TraceID)
+}
@@ -0,0 +1,149 @@
+package balancer
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cb "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/circuitBreaker"
+)
+
+const (
+	RoundRobin		= "round-robin"
+	LeastInFlight	= "least-in-flight"
+)
+
+var ErrNoInstances = errors.New("no available instances")
+
+type Config struct {
+	// round-robin или least-in-flight
+	Policy			string
+	// Как часто перечитывать DNS для адресов вида dns+http://
+	ResolveInterval	time.Duration
+	// Настройки breaker'а каждого экземпляра: открытый breaker исключает экземпляр
+	Breaker			cb.Config
+}
+
+// Экземпляр сервиса со своим circuit breaker и числом выполняющихся вызовов
+type Instance struct {
+	URL			string
+	Breaker		*cb.CircuitBreaker
+	inFlight	atomic.Int64
+}
+
+func (i *Instance) InFlight() int64 {
+	return i.inFlight.Load()
+}
+
+// Записывает результат вызова в breaker экземпляра
+func (i *Instance) Record(success bool, duration time.Duration) {
+	i.inFlight.Add(-1)
+	i.Breaker.Record(success, duration)
+}
+
+// Вызов отменён вызывающей стороной и ничего не говорит об экземпляре
+func (i *Instance) Release() {
+	i.inFlight.Add(-1)
+	i.Breaker.Release()
+}
+
+// Распределяет вызовы одного сервиса между его экземплярами. Экземпляры
+// с открытым breaker'ом пропускаются, пока не истечёт его таймаут
+type Balancer struct {
+	Name		string
+	policy		string
+	breaker		cb.Config
+
+	mu			sync.RWMutex
+	instances	[]*Instance
+	next		atomic.Uint64
+}
+
+func New(name string, cfg Config) *Balancer {
+	policy := cfg.Policy
+	if policy != LeastInFlight {
+		policy = RoundRobin
+	}
+
+	return &Balancer{Name: name, policy: policy, breaker: cfg.Breaker}
+}
+
+// Заменяет список экземпляров. Для оставшихся адресов состояние breaker'а сохраняется
+func (b *Balancer) SetURLs(urls []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	existing := make(map[string]*Instance, len(b.instances))
+	for _, instance := range b.instances {
+		existing[instance.URL] = instance
+	}
+
+	instances := make([]*Instance, 0, len(urls))
+	for _, url := range urls {
+		if instance, ok := existing[url]; ok {
+			instances = append(instances, instance)
+			continue
+		}
+		instances = append(instances, b.newInstance(url))
+	}
+
+	b.instances = instances
+}
+
+func (b *Balancer) newInstance(url string) *Instance {
+	breaker := cb.NewCircuitBreakerWithConfig(b.breaker)
+	breaker.Name = b.Name + "@" + url
+	breaker.OnStateChange(func(change cb.StateChange) {
+		switch change.To {
+			case cb.StateOpen:
+				slog.Warn("downstream instance ejected", "downstream", b.Name, "instance", url, "reason", change.Reason)
+			case cb.StateClosed:
+				slog.Info("downstream instance restored", "downstream", b.Name, "instance", url)
+		}
+	})
+
+	return &Instance{URL: url, Breaker: breaker}
+}
+
+// Выбирает экземпляр для вызова. После вызова нужно вызвать Record или Release
+func (b *Balancer) Pick() (*Instance, error) {
+	b.mu.RLock()
+	candidates := make([]*Instance, len(b.instances))
+	copy(candidates, b.instances)
+	b.mu.RUnlock()
+
+	if len(candidates) == 0 {
+		return nil, ErrNoInstances
+	}
+
+	start := int(b.next.Add(1) - 1) % len(candidates)
+	candidates = append(candidates[start:], candidates[:start]...)
+
+	if b.policy == LeastInFlight {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].InFlight() < candidates[j].InFlight()
+		})
+	}
+
+	for _, instance := range candidates {
+		if instance.Breaker.AllowRequest() {
+			instance.inFlight.Add(1)
+			return instance, nil
+		}
+	}
+
+	return nil, ErrNoInstances
+}
+
+func (b *Balancer) Instances() []*Instance {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	instances := make([]*Instance, len(b.instances))
+	copy(instances, b.instances)
+
+	return instances
+}
@@ -0,0 +1,105 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cb "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/circuitBreaker"
+)
+
+var testBreaker = cb.Config{BufferSize: 2, FailureRate: 0.5, Timeout: time.Minute, MinimumCalls: 2}
+
+func pickURL(t *testing.T, b *Balancer) string {
+	instance, err := b.Pick()
+	require.NoError(t, err)
+	instance.Record(true, 0)
+	return instance.URL
+}
+
+// Тест: round-robin обходит экземпляры по очереди
+func TestBalancer_RoundRobin(t *testing.T) {
+	b := New("car", Config{Breaker: testBreaker})
+	b.SetURLs(ParseURLs("http://a:8070/api/v1, http://b:8070/api/v1/"))
+
+	assert.Equal(t, "http://a:8070/api/v1", pickURL(t, b))
+	assert.Equal(t, "http://b:8070/api/v1", pickURL(t, b))
+	assert.Equal(t, "http://a:8070/api/v1", pickURL(t, b))
+}
+
+// Тест: least-in-flight выбирает экземпляр с меньшим числом вызовов
+func TestBalancer_LeastInFlight(t *testing.T) {
+	b := New("car", Config{Policy: LeastInFlight, Breaker: testBreaker})
+	b.SetURLs([]string{"http://a", "http://b"})
+
+	busy, err := b.Pick()
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		assert.NotEqual(t, busy.URL, pickURL(t, b))
+	}
+}
+
+// Тест: экземпляр с открытым breaker'ом исключается, пока есть другие
+func TestBalancer_EjectsOutliers(t *testing.T) {
+	b := New("payment", Config{Breaker: testBreaker})
+	b.SetURLs([]string{"http://a", "http://b"})
+
+	for _, instance := range b.Instances() {
+		if instance.URL == "http://a" {
+			instance.Breaker.RecordFailure()
+			instance.Breaker.RecordFailure()
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "http://b", pickURL(t, b))
+	}
+
+	b.Instances()[1].Breaker.ForceOpen()
+	_, err := b.Pick()
+	assert.ErrorIs(t, err, ErrNoInstances)
+}
+
+// Тест: при обновлении списка состояние оставшихся экземпляров сохраняется
+func TestBalancer_SetURLsKeepsState(t *testing.T) {
+	b := New("rental", Config{Breaker: testBreaker})
+	b.SetURLs([]string{"http://a", "http://b"})
+	b.Instances()[0].Breaker.ForceOpen()
+
+	b.SetURLs([]string{"http://a", "http://c"})
+
+	instances := b.Instances()
+	require.Len(t, instances, 2)
+	assert.Equal(t, cb.StateOpen, instances[0].Breaker.Snapshot().ForcedState)
+	assert.Equal(t, "http://c", instances[1].URL)
+}
+
+// Тест: адрес dns+ раскрывается в экземпляры по IP, ошибка DNS не сбрасывает список
+func TestNewFromTarget_DNS(t *testing.T) {
+	original := lookupHost
+	defer func() { lookupHost = original }()
+
+	lookupHost = func(_ context.Context, host string) ([]string, error) {
+		assert.Equal(t, "cars-headless", host)
+		return []string{"10.0.0.2", "10.0.0.1"}, nil
+	}
+
+	b := NewFromTarget("car", "dns+http://cars-headless:8070/api/v1", Config{Breaker: testBreaker})
+
+	instances := b.Instances()
+	require.Len(t, instances, 2)
+	assert.Equal(t, "http://10.0.0.1:8070/api/v1", instances[0].URL)
+	assert.Equal(t, "http://10.0.0.2:8070/api/v1", instances[1].URL)
+
+	lookupHost = func(context.Context, string) ([]string, error) {
+		return nil, errors.New("no such host")
+	}
+	b.resolve("http://cars-headless:8070/api/v1")
+
+	assert.Len(t, b.Instances(), 2)
+}
@@ -0,0 +1,87 @@
+package balancer
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Схема адреса, хост которого перечитывается из DNS: dns+http://cars-headless:8070/api/v1
+const dnsPrefix = "dns+"
+
+var lookupHost = net.DefaultResolver.LookupHost
+
+// Балансировщик для адреса сервиса: список через запятую или dns+<url>
+func NewFromTarget(name, target string, cfg Config) *Balancer {
+	b := New(name, cfg)
+
+	if !strings.HasPrefix(target, dnsPrefix) {
+		b.SetURLs(ParseURLs(target))
+		return b
+	}
+
+	base := strings.TrimPrefix(target, dnsPrefix)
+	b.resolve(base)
+
+	if cfg.ResolveInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.ResolveInterval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				b.resolve(base)
+			}
+		}()
+	}
+
+	return b
+}
+
+func ParseURLs(target string) []string {
+	var urls []string
+	for _, item := range strings.Split(target, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			urls = append(urls, strings.TrimSuffix(item, "/"))
+		}
+	}
+	return urls
+}
+
+// Адреса экземпляров: хост base заменяется на каждый IP из DNS.
+// При ошибке DNS остаётся прежний список
+func (b *Balancer) resolve(base string) {
+	parsed, err := url.Parse(base)
+	if err != nil {
+		slog.Error("invalid downstream address", "downstream", b.Name, "url", base, "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addrs, err := lookupHost(ctx, parsed.Hostname())
+	if err != nil {
+		slog.Warn("can't resolve downstream instances", "downstream", b.Name, "host", parsed.Hostname(), "error", err)
+		return
+	}
+	sort.Strings(addrs)
+
+	urls := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		instance := *parsed
+		instance.Host = net.JoinHostPort(addr, parsed.Port())
+		if parsed.Port() == "" {
+			instance.Host = addr
+			if strings.Contains(addr, ":") {
+				instance.Host = "[" + addr + "]"
+			}
+		}
+		urls = append(urls, strings.TrimSuffix(instance.String(), "/"))
+	}
+
+	b.SetURLs(urls)
+}
@@ -0,0 +1,106 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrFull = errors.New("bulkhead is full")
+
+type Config struct {
+	// Одновременных вызовов сервиса, 0 - без ограничения
+	MaxConcurrent	int
+	// Вызовов, ожидающих свободного места. Остальные отклоняются сразу
+	MaxWaiting		int
+	// Сколько вызов может ждать в очереди
+	MaxWait			time.Duration
+}
+
+// Ограничивает число одновременных вызовов одного сервиса, чтобы медленный
+// сервис не занимал все соединения и горутины gateway. nil - без ограничения
+type Bulkhead struct {
+	Name		string
+	slots		chan struct{}
+	maxWaiting	int
+	maxWait		time.Duration
+
+	mu			sync.Mutex
+	waiting		int
+}
+
+func New(name string, cfg Config) *Bulkhead {
+	b := &Bulkhead{
+		Name:		name,
+		maxWaiting:	cfg.MaxWaiting,
+		maxWait:	cfg.MaxWait,
+	}
+	if cfg.MaxConcurrent > 0 {
+		b.slots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+
+	return b
+}
+
+// Занимает место для вызова. ErrFull - очередь заполнена или время ожидания
+// истекло, ошибка контекста - вызывающий перестал ждать
+func (b *Bulkhead) Acquire(ctx context.Context) error {
+	if b == nil || b.slots == nil {
+		return nil
+	}
+
+	select {
+		case b.slots <- struct{}{}:
+			return nil
+		default:
+	}
+
+	b.mu.Lock()
+	if b.waiting >= b.maxWaiting {
+		b.mu.Unlock()
+		return ErrFull
+	}
+	b.waiting++
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		b.waiting--
+		b.mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if b.maxWait > 0 {
+		timer := time.NewTimer(b.maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+		case b.slots <- struct{}{}:
+			return nil
+		case <-timeout:
+			return ErrFull
+		case <-ctx.Done():
+			return ctx.Err()
+	}
+}
+
+func (b *Bulkhead) Release() {
+	if b == nil || b.slots == nil {
+		return
+	}
+	<-b.slots
+}
+
+func (b *Bulkhead) InFlight() int {
+	return len(b.slots)
+}
+
+func (b *Bulkhead) Waiting() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.waiting
+}
@@ -0,0 +1,57 @@
+package bulkhead
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тест: сверх MaxConcurrent и MaxWaiting вызовы отклоняются сразу
+func TestBulkhead_RejectsOverflow(t *testing.T) {
+	b := New("payment", Config{MaxConcurrent: 1, MaxWaiting: 1, MaxWait: time.Second})
+
+	require.NoError(t, b.Acquire(context.Background()))
+
+	waited := make(chan error)
+	go func() {
+		waited <- b.Acquire(context.Background())
+	}()
+
+	require.Eventually(t, func() bool { return b.Waiting() == 1 }, time.Second, time.Millisecond)
+
+	start := time.Now()
+	assert.ErrorIs(t, b.Acquire(context.Background()), ErrFull)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+	b.Release()
+	assert.NoError(t, <-waited)
+	assert.Equal(t, 1, b.InFlight())
+	assert.Equal(t, 0, b.Waiting())
+}
+
+// Тест: ожидание ограничено MaxWait и контекстом
+func TestBulkhead_WaitLimits(t *testing.T) {
+	b := New("car", Config{MaxConcurrent: 1, MaxWaiting: 10, MaxWait: 20 * time.Millisecond})
+	require.NoError(t, b.Acquire(context.Background()))
+
+	assert.ErrorIs(t, b.Acquire(context.Background()), ErrFull)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, b.Acquire(ctx), context.Canceled)
+	assert.Equal(t, 0, b.Waiting())
+}
+
+// Тест: без MaxConcurrent вызовы не ограничиваются
+func TestBulkhead_Unlimited(t *testing.T) {
+	b := New("rental", Config{})
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, b.Acquire(context.Background()))
+	}
+	b.Release()
+	assert.Equal(t, 0, b.InFlight())
+}
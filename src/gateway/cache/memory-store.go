@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	entry     Entry
+	expiresAt time.Time
+}
+
+type MemoryStore struct {
+	mu       sync.Mutex
+	entries  map[string]memoryEntry
+	versions map[string]int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry), versions: make(map[string]int64)}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) (*Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.entries[key]
+	if !ok || time.Now().After(stored.expiresAt) {
+		return nil, ErrorNotFound
+	}
+
+	entry := stored.entry
+	return &entry, nil
+}
+
+func (m *MemoryStore) Set(ctx context.Context, key string, entry Entry, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[key] = memoryEntry{entry: entry, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *MemoryStore) Version(ctx context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.versions[key], nil
+}
+
+func (m *MemoryStore) Incr(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.versions[key]++
+	return nil
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (r *RedisStore) Get(ctx context.Context, key string) (*Entry, error) {
+	data, err := r.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrorNotFound
+		}
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (r *RedisStore) Set(ctx context.Context, key string, entry Entry, ttl time.Duration) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(ctx, key, data, ttl).Err()
+}
+
+func (r *RedisStore) Version(ctx context.Context, key string) (int64, error) {
+	version, err := r.client.Get(ctx, key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return version, err
+}
+
+func (r *RedisStore) Incr(ctx context.Context, key string) error {
+	return r.client.Incr(ctx, key).Err()
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+)
+
+const (
+	HeaderCache = "X-Cache"
+
+	StatusHit   = "HIT"
+	StatusMiss  = "MISS"
+	StatusStale = "STALE"
+
+	// RFC 7234, 5.5.1
+	StaleWarning = `110 - "Response is Stale"`
+)
+
+// Кэш успешных ответов: в пределах freshTTL ответ отдаётся без запроса
+// к сервису, а до истечения staleTTL - только если сервис недоступен.
+// Invalidate увеличивает версию данных: записи прежней версии больше не
+// свежие, но остаются устаревшей копией на случай недоступности сервиса
+type ResponseCache struct {
+	store    Store
+	prefix   string
+	freshTTL time.Duration
+	staleTTL time.Duration
+}
+
+func NewResponseCache(store Store, prefix string, freshTTL, staleTTL time.Duration) *ResponseCache {
+	if staleTTL < freshTTL {
+		staleTTL = freshTTL
+	}
+
+	return &ResponseCache{store: store, prefix: prefix, freshTTL: freshTTL, staleTTL: staleTTL}
+}
+
+// Ключ не зависит от порядка параметров в строке запроса
+func (c *ResponseCache) Key(query url.Values) string {
+	return c.prefix + ":" + query.Encode()
+}
+
+func (c *ResponseCache) versionKey() string {
+	return c.prefix + ":version"
+}
+
+// Текущая версия данных. Читается до запроса к сервису и передаётся в
+// Save, чтобы ответ, полученный до Invalidate, не считался свежим.
+// Если версию прочитать нельзя, записи не считаются свежими
+func (c *ResponseCache) Version(ctx context.Context) int64 {
+	version, err := c.store.Version(ctx, c.versionKey())
+	if err != nil {
+		logging.FromContext(ctx).Warn("response cache version reading error", logging.Err(err))
+		return -1
+	}
+	return version
+}
+
+func (c *ResponseCache) Invalidate(ctx context.Context) {
+	if err := c.store.Incr(ctx, c.versionKey()); err != nil {
+		logging.FromContext(ctx).Warn("response cache invalidation error", logging.Err(err))
+	}
+}
+
+// Возвращает запись и признак того, что она ещё свежая
+func (c *ResponseCache) Lookup(ctx context.Context, key string, version int64) (*Entry, bool) {
+	entry, err := c.store.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, ErrorNotFound) {
+			logging.FromContext(ctx).Warn("response cache lookup error", logging.Err(err))
+		}
+		return nil, false
+	}
+
+	return entry, version >= 0 && entry.Version == version && time.Since(entry.StoredAt) < c.freshTTL
+}
+
+func (c *ResponseCache) Save(ctx context.Context, key string, version int64, status int, contentType string, body []byte) {
+	entry := Entry{Status: status, ContentType: contentType, Body: body, StoredAt: time.Now(), Version: version}
+
+	if err := c.store.Set(ctx, key, entry, c.staleTTL); err != nil {
+		logging.FromContext(ctx).Warn("response cache save error", logging.Err(err))
+	}
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тест: ключ не зависит от порядка параметров запроса
+func TestResponseCache_KeyNormalizesQuery(t *testing.T) {
+	c := NewResponseCache(NewMemoryStore(), "cache:cars", time.Minute, time.Hour)
+
+	first, _ := url.ParseQuery("page=1&size=10&showAll=true")
+	second, _ := url.ParseQuery("showAll=true&size=10&page=1")
+	other, _ := url.ParseQuery("page=2&size=10&showAll=true")
+
+	assert.Equal(t, c.Key(first), c.Key(second))
+	assert.NotEqual(t, c.Key(first), c.Key(other))
+}
+
+// Тест: запись свежая в пределах freshTTL и остаётся доступной как устаревшая
+func TestResponseCache_FreshAndStale(t *testing.T) {
+	store := NewMemoryStore()
+	c := NewResponseCache(store, "cache:cars", time.Minute, time.Hour)
+	ctx := context.Background()
+
+	c.Save(ctx, "key", 0, 200, "application/json", []byte(`{"items":[]}`))
+
+	entry, fresh := c.Lookup(ctx, "key", 0)
+	require.NotNil(t, entry)
+	assert.True(t, fresh)
+
+	// Запись старше freshTTL
+	entry.StoredAt = time.Now().Add(-2 * time.Minute)
+	require.NoError(t, store.Set(ctx, "key", *entry, time.Hour))
+
+	entry, fresh = c.Lookup(ctx, "key", 0)
+	require.NotNil(t, entry)
+	assert.False(t, fresh)
+	assert.Equal(t, `{"items":[]}`, string(entry.Body))
+}
+
+// Тест: промах кэша
+func TestResponseCache_Miss(t *testing.T) {
+	c := NewResponseCache(NewMemoryStore(), "cache:cars", time.Minute, time.Hour)
+
+	entry, fresh := c.Lookup(context.Background(), "missing", 0)
+	assert.Nil(t, entry)
+	assert.False(t, fresh)
+}
+
+// Тест: после Invalidate запись не свежая, но остаётся устаревшей копией
+func TestResponseCache_Invalidate(t *testing.T) {
+	c := NewResponseCache(NewMemoryStore(), "cache:cars", time.Minute, time.Hour)
+	ctx := context.Background()
+
+	version := c.Version(ctx)
+	c.Save(ctx, "key", version, 200, "application/json", []byte(`{"items":[]}`))
+
+	c.Invalidate(ctx)
+
+	entry, fresh := c.Lookup(ctx, "key", c.Version(ctx))
+	require.NotNil(t, entry)
+	assert.False(t, fresh)
+
+	// Ответ, полученный до Invalidate, сохраняется со старой версией
+	c.Save(ctx, "key", version, 200, "application/json", []byte(`{"items":[]}`))
+	_, fresh = c.Lookup(ctx, "key", c.Version(ctx))
+	assert.False(t, fresh)
+}
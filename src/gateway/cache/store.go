@@ -0,0 +1,25 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrorNotFound = errors.New("cache entry not found")
+
+type Entry struct {
+	Status      int       `json:"status"`
+	ContentType string    `json:"contentType,omitempty"`
+	Body        []byte    `json:"body"`
+	StoredAt    time.Time `json:"storedAt"`
+	Version     int64     `json:"version,omitempty"`
+}
+
+type Store interface {
+	Get(ctx context.Context, key string) (*Entry, error)
+	Set(ctx context.Context, key string, entry Entry, ttl time.Duration) error
+	// Счётчик версии, 0 - если ещё не увеличивался
+	Version(ctx context.Context, key string) (int64, error)
+	Incr(ctx context.Context, key string) error
+}
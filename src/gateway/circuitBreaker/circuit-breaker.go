@@ -2,6 +2,7 @@ package circuitbreaker
 
 import (
 	"errors"
+	"log/slog"
 	"sync"
 	"time"
 )
@@ -12,78 +13,358 @@ const (
 	StateHalfOpen = "half-open"
 )
 
-// TODO: переделать под циклический массив
+// Минимум вызовов в окне time, если он не задан: иначе одна ошибка
+// в почти пустом окне открывала бы breaker
+const DefaultMinimumCalls = 5
+
+type Config struct {
+	// count - последние BufferSize вызовов, time - вызовы за WindowDuration
+	WindowType			string
+	BufferSize			int
+	WindowDuration		time.Duration
+	WindowBuckets		int
+	FailureRate			float64
+	Timeout				time.Duration
+	// Сколько пробных вызовов пропускается в half-open
+	HalfOpenMaxCalls	int
+	// Доля ошибок не оценивается, пока в окне меньше вызовов
+	MinimumCalls		int
+	// Медленные вызовы учитываются, только если SlowCallDuration > 0
+	SlowCallDuration	time.Duration
+	SlowCallRate		float64
+}
+
+// Переход между состояниями
+type StateChange struct {
+	Name		string		`json:"name"`
+	From		string		`json:"from"`
+	To			string		`json:"to"`
+	Reason		string		`json:"reason"`
+	At			time.Time	`json:"at"`
+}
+
 type CircuitBreaker struct {
 	mu					sync.RWMutex
+	Name				string
 	State 				string
-	RingBuffer			*RingBuffer
+	// Состояние, выставленное администратором вручную, имеет приоритет
+	ForcedState			string
+	Window				Window
 	FailureRate			float64
 	LastRequestTime		time.Time
 	Timeout				time.Duration
+	HalfOpenMaxCalls	int
+	MinimumCalls		int
+	SlowCallDuration	time.Duration
+	SlowCallRate		float64
+
+	// Пробные вызовы текущего half-open
+	halfOpenCalls		int
+	halfOpenSuccesses	int
+
+	listeners			[]func(StateChange)
+
+	// Общее состояние реплик, nil - только локальное
+	shared				SharedStore
+	syncInterval		time.Duration
+	syncMu				sync.Mutex
+	lastSync			time.Time
+	updatedAt			time.Time
 }
 
 func NewCircuitBreaker(bufferSize int, failureRate float64, timeout time.Duration) *CircuitBreaker {
+	return NewCircuitBreakerWithConfig(Config{
+		BufferSize:		bufferSize,
+		FailureRate:	failureRate,
+		Timeout:		timeout,
+	})
+}
+
+func NewCircuitBreakerWithConfig(cfg Config) *CircuitBreaker {
+	if cfg.BufferSize < 1 {
+		cfg.BufferSize = 1
+	}
+	if cfg.HalfOpenMaxCalls < 1 {
+		cfg.HalfOpenMaxCalls = 1
+	}
+
+	var window Window
+	if cfg.WindowType == WindowTime {
+		window = NewTimeWindow(cfg.WindowDuration, cfg.WindowBuckets)
+		if cfg.MinimumCalls < 1 {
+			cfg.MinimumCalls = DefaultMinimumCalls
+		}
+	} else {
+		window = NewRingBuffer(cfg.BufferSize)
+		if cfg.MinimumCalls < 1 || cfg.MinimumCalls > cfg.BufferSize {
+			cfg.MinimumCalls = cfg.BufferSize
+		}
+	}
+
 	return &CircuitBreaker{
-		State:        	StateClosed,
-		RingBuffer: 	NewRingBuffer(bufferSize),
-		FailureRate: 	failureRate,
-		Timeout:      	timeout,
+		State:        		StateClosed,
+		Window: 			window,
+		FailureRate: 		cfg.FailureRate,
+		Timeout:      		cfg.Timeout,
+		HalfOpenMaxCalls:	cfg.HalfOpenMaxCalls,
+		MinimumCalls:		cfg.MinimumCalls,
+		SlowCallDuration:	cfg.SlowCallDuration,
+		SlowCallRate:		cfg.SlowCallRate,
 	}
 }
 
+// Подписчики вызываются синхронно после смены состояния, вне блокировки
+func (cb *CircuitBreaker) OnStateChange(listener func(StateChange)) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.listeners = append(cb.listeners, listener)
+}
+
+// Состояние и окно хранятся в store, локальная копия сверяется
+// с ним не чаще syncInterval
+func (cb *CircuitBreaker) useSharedStore(store SharedStore, cfg Config, syncInterval time.Duration) {
+	cb.Window = store.NewWindow(cb.Name, cfg)
+	cb.shared = store
+	cb.syncInterval = syncInterval
+}
+
 func (cb *CircuitBreaker) AllowRequest() bool {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
+	cb.syncShared()
 
-	switch cb.State {
+	cb.mu.Lock()
+
+	switch cb.ForcedState {
+		case StateOpen:
+			cb.mu.Unlock()
+			return false
 		case StateClosed:
+			cb.mu.Unlock()
 			return true
+	}
+
+	var change *StateChange
+	allowed := true
+
+	switch cb.State {
 		case StateOpen:
-			if time.Since(cb.LastRequestTime) >= cb.Timeout {
-				cb.State = StateHalfOpen
-				return true
+			if time.Since(cb.LastRequestTime) < cb.Timeout {
+				allowed = false
+				break
 			}
-			return false
+			change = cb.transition(StateHalfOpen, "open timeout elapsed")
+			cb.halfOpenCalls = 1
 		case StateHalfOpen:
-			return true
-		default:
-			return true
+			if cb.halfOpenCalls >= cb.HalfOpenMaxCalls {
+				allowed = false
+				break
+			}
+			cb.halfOpenCalls++
 	}
+
+	cb.mu.Unlock()
+	cb.emit(change)
+
+	return allowed
 }
 
 func (cb *CircuitBreaker) RecordSuccess() {
+	cb.Record(true, 0)
+}
+
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.Record(false, 0)
+}
+
+// Записывает результат вызова вместе с его длительностью
+func (cb *CircuitBreaker) Record(success bool, duration time.Duration) {
+	cb.mu.Lock()
+
+	slow := cb.SlowCallDuration > 0 && duration >= cb.SlowCallDuration
+	cb.Window.Add(RequestResult{Success: success, Slow: slow, Time: time.Now()})
+
+	var change *StateChange
+
+	if cb.ForcedState == "" {
+		switch cb.State {
+			case StateHalfOpen:
+				change = cb.recordHalfOpen(success, slow)
+			case StateClosed:
+				change = cb.evaluateClosed()
+		}
+	}
+
+	cb.mu.Unlock()
+	cb.emit(change)
+}
+
+// Вызов, отменённый вызывающей стороной, ничего не говорит о сервисе:
+// результат не записывается, а в half-open освобождается место пробного вызова
+func (cb *CircuitBreaker) Release() {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	cb.RingBuffer.Add(RequestResult{Success: true, Time: time.Now()})
+	if cb.State == StateHalfOpen && cb.halfOpenCalls > cb.halfOpenSuccesses {
+		cb.halfOpenCalls--
+	}
+}
+
+// В half-open первая неудачная или медленная пробная попытка снова открывает
+// breaker, а успех всех пробных попыток закрывает его с чистым окном
+func (cb *CircuitBreaker) recordHalfOpen(success, slow bool) *StateChange {
+	if !success {
+		return cb.open("trial call failed")
+	}
+	if slow {
+		return cb.open("trial call was slow")
+	}
+
+	cb.halfOpenSuccesses++
+	if cb.halfOpenSuccesses < cb.HalfOpenMaxCalls {
+		return nil
+	}
+
+	cb.Window.Clear()
+	return cb.transition(StateClosed, "trial calls succeeded")
+}
+
+func (cb *CircuitBreaker) evaluateClosed() *StateChange {
+	if cb.Window.Count() < cb.MinimumCalls {
+		return nil
+	}
 
-	if cb.State == StateHalfOpen {
-		cb.State = StateClosed
+	if cb.Window.GetFailureRate() > cb.FailureRate {
+		return cb.open("failure rate threshold exceeded")
 	}
 
-	if cb.State == StateClosed && cb.RingBuffer.GetFailureRate() <= cb.FailureRate {
-	} else if cb.State == StateClosed && cb.RingBuffer.GetFailureRate() > cb.FailureRate {
-		cb.State = StateOpen
-		cb.LastRequestTime = time.Now()
+	if cb.SlowCallDuration > 0 && cb.Window.GetSlowCallRate() > cb.SlowCallRate {
+		return cb.open("slow call rate threshold exceeded")
 	}
+
+	return nil
 }
 
-func (cb *CircuitBreaker) RecordFailure() {
+func (cb *CircuitBreaker) open(reason string) *StateChange {
+	cb.LastRequestTime = time.Now()
+	return cb.transition(StateOpen, reason)
+}
+
+// Вызывается под блокировкой
+func (cb *CircuitBreaker) transition(to, reason string) *StateChange {
+	if cb.State == to {
+		return nil
+	}
+
+	change := &StateChange{Name: cb.Name, From: cb.State, To: to, Reason: reason, At: time.Now()}
+
+	cb.State = to
+	cb.halfOpenCalls = 0
+	cb.halfOpenSuccesses = 0
+	cb.updatedAt = change.At
+
+	return change
+}
+
+// Уведомляет подписчиков и сохраняет локальный переход в общее хранилище
+func (cb *CircuitBreaker) emit(change *StateChange) {
+	if change == nil {
+		return
+	}
+
+	cb.publish()
+	cb.notify(change)
+}
+
+func (cb *CircuitBreaker) notify(change *StateChange) {
+	if change == nil {
+		return
+	}
+
+	cb.mu.RLock()
+	listeners := cb.listeners
+	cb.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(*change)
+	}
+}
+
+func (cb *CircuitBreaker) publish() {
+	if cb.shared == nil {
+		return
+	}
+
+	cb.mu.RLock()
+	state := SharedState{
+		State:			cb.State,
+		ForcedState:	cb.ForcedState,
+		OpenedAt:		cb.LastRequestTime,
+		UpdatedAt:		cb.updatedAt,
+	}
+	cb.mu.RUnlock()
+
+	if err := cb.shared.SaveState(cb.Name, state); err != nil {
+		slog.Warn("circuit breaker shared state save error", "circuit_breaker", cb.Name, "error", err.Error())
+	}
+}
+
+// Принимает состояние, сохранённое другой репликой позже локального
+func (cb *CircuitBreaker) syncShared() {
+	if cb.shared == nil {
+		return
+	}
+
+	cb.mu.RLock()
+	due := time.Since(cb.lastSync) >= cb.syncInterval
+	cb.mu.RUnlock()
+
+	if !due || !cb.syncMu.TryLock() {
+		return
+	}
+	defer cb.syncMu.Unlock()
+
+	remote, err := cb.shared.LoadState(cb.Name)
+
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
+	cb.lastSync = time.Now()
 
-	cb.RingBuffer.Add(RequestResult{Success: false, Time: time.Now()})
-	
-	if cb.State == StateHalfOpen {
-		cb.State = StateOpen
-		cb.LastRequestTime = time.Now()
+	if err != nil {
+		cb.mu.Unlock()
+		slog.Warn("circuit breaker shared state load error", "circuit_breaker", cb.Name, "error", err.Error())
 		return
 	}
-	
-	if cb.State == StateClosed && cb.RingBuffer.GetFailureRate() > cb.FailureRate {
-		cb.State = StateOpen
-		cb.LastRequestTime = time.Now()
+
+	if !remote.UpdatedAt.After(cb.updatedAt) {
+		cb.mu.Unlock()
+		return
 	}
+
+	var change *StateChange
+	if remote.State != cb.State || remote.ForcedState != cb.ForcedState {
+		to := remote.State
+		if remote.ForcedState != "" {
+			to = remote.ForcedState
+		}
+		change = &StateChange{Name: cb.Name, From: cb.effectiveState(), To: to, Reason: "synced from another replica", At: time.Now()}
+	}
+
+	cb.State = remote.State
+	cb.ForcedState = remote.ForcedState
+	cb.LastRequestTime = remote.OpenedAt
+	cb.updatedAt = remote.UpdatedAt
+	cb.halfOpenCalls = 0
+	cb.halfOpenSuccesses = 0
+
+	cb.mu.Unlock()
+	cb.notify(change)
+}
+
+// Вызывается под блокировкой
+func (cb *CircuitBreaker) effectiveState() string {
+	if cb.ForcedState != "" {
+		return cb.ForcedState
+	}
+	return cb.State
 }
 
 func (cb *CircuitBreaker) Execute(operation func() error, fallback func()) error {
@@ -92,17 +373,123 @@ func (cb *CircuitBreaker) Execute(operation func() error, fallback func()) error
         return errors.New("circuit breaker open")
     }
 
+    start := time.Now()
     err := operation()
     if err != nil {
-        cb.RecordFailure()
+        cb.Record(false, time.Since(start))
         fallback()
         return err
     }
 
-    cb.RecordSuccess()
+    cb.Record(true, time.Since(start))
     return nil
 }
 
 func (cb *CircuitBreaker) GetFailureRate() float64 {
-	return cb.RingBuffer.GetFailureRate()
-}
\ No newline at end of file
+	return cb.Window.GetFailureRate()
+}
+
+func (cb *CircuitBreaker) ForceOpen() {
+	cb.force(StateOpen)
+}
+
+func (cb *CircuitBreaker) ForceClosed() {
+	cb.force(StateClosed)
+}
+
+func (cb *CircuitBreaker) force(state string) {
+	cb.mu.Lock()
+
+	change := &StateChange{Name: cb.Name, From: cb.effectiveState(), To: state, Reason: "forced by administrator", At: time.Now()}
+	cb.ForcedState = state
+	cb.updatedAt = change.At
+
+	cb.mu.Unlock()
+	cb.emit(change)
+}
+
+// Снимает ручное состояние и очищает окно
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+
+	from := cb.effectiveState()
+
+	cb.ForcedState = ""
+	cb.State = StateClosed
+	cb.LastRequestTime = time.Time{}
+	cb.halfOpenCalls = 0
+	cb.halfOpenSuccesses = 0
+	cb.Window.Clear()
+	cb.updatedAt = time.Now()
+
+	var change *StateChange
+	if from != StateClosed {
+		change = &StateChange{Name: cb.Name, From: from, To: StateClosed, Reason: "reset by administrator", At: cb.updatedAt}
+	}
+
+	cb.mu.Unlock()
+
+	cb.publish()
+	cb.notify(change)
+}
+
+type Snapshot struct {
+	Name				string			`json:"name"`
+	State				string			`json:"state"`
+	ForcedState			string			`json:"forcedState,omitempty"`
+	FailureRate			float64			`json:"failureRate"`
+	FailureThreshold	float64			`json:"failureThreshold"`
+	SlowCallRate		float64			`json:"slowCallRate"`
+	MinimumCalls		int				`json:"minimumCalls"`
+	HalfOpenCalls		int				`json:"halfOpenCalls"`
+	HalfOpenMaxCalls	int				`json:"halfOpenMaxCalls"`
+	WindowType			string			`json:"windowType"`
+	Shared				bool			`json:"shared"`
+	Window				[]RequestResult	`json:"window,omitempty"`
+	Buckets				[]BucketStats	`json:"buckets,omitempty"`
+	HalfOpenIn			string			`json:"halfOpenIn,omitempty"`
+}
+
+func (cb *CircuitBreaker) Snapshot() Snapshot {
+	cb.syncShared()
+
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	snapshot := Snapshot{
+		Name:				cb.Name,
+		State:				cb.State,
+		ForcedState:		cb.ForcedState,
+		FailureRate:		cb.Window.GetFailureRate(),
+		FailureThreshold:	cb.FailureRate,
+		SlowCallRate:		cb.Window.GetSlowCallRate(),
+		MinimumCalls:		cb.MinimumCalls,
+		HalfOpenCalls:		cb.halfOpenCalls,
+		HalfOpenMaxCalls:	cb.HalfOpenMaxCalls,
+	}
+
+	window := cb.Window
+	if shared, ok := window.(interface{ Local() Window }); ok {
+		snapshot.Shared = true
+		window = shared.Local()
+	}
+
+	switch window := window.(type) {
+		case *RingBuffer:
+			snapshot.WindowType = WindowCount
+			snapshot.Window = window.Results()
+		case *TimeWindow:
+			snapshot.WindowType = WindowTime
+			snapshot.Buckets = window.Buckets()
+	}
+
+	if cb.State == StateOpen {
+		remaining := cb.Timeout - time.Since(cb.LastRequestTime)
+		if remaining < 0 {
+			remaining = 0
+		}
+		snapshot.HalfOpenIn = remaining.Round(time.Millisecond).String()
+	}
+
+	return snapshot
+}
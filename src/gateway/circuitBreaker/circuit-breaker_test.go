@@ -0,0 +1,183 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBreaker(cfg Config) (*CircuitBreaker, *[]StateChange) {
+	breaker := NewCircuitBreakerWithConfig(cfg)
+
+	var mu sync.Mutex
+	changes := []StateChange{}
+	breaker.OnStateChange(func(change StateChange) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, change)
+	})
+
+	return breaker, &changes
+}
+
+// Тест: до минимального числа вызовов доля ошибок не оценивается
+func TestCircuitBreaker_MinimumCalls(t *testing.T) {
+	breaker, _ := newTestBreaker(Config{BufferSize: 10, FailureRate: 0.4, Timeout: time.Minute, MinimumCalls: 4})
+
+	for i := 0; i < 3; i++ {
+		breaker.RecordFailure()
+	}
+	assert.Equal(t, StateClosed, breaker.Snapshot().State)
+
+	breaker.RecordFailure()
+	assert.Equal(t, StateOpen, breaker.Snapshot().State)
+	assert.False(t, breaker.AllowRequest())
+}
+
+// Тест: в half-open пропускается только заданное число пробных вызовов
+func TestCircuitBreaker_HalfOpenLimitsTrialCalls(t *testing.T) {
+	breaker, changes := newTestBreaker(Config{BufferSize: 2, FailureRate: 0.4, Timeout: time.Millisecond, HalfOpenMaxCalls: 2})
+
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	require.Equal(t, StateOpen, breaker.Snapshot().State)
+
+	time.Sleep(5 * time.Millisecond)
+
+	assert.True(t, breaker.AllowRequest())
+	assert.True(t, breaker.AllowRequest())
+	assert.False(t, breaker.AllowRequest())
+	assert.Equal(t, StateHalfOpen, breaker.Snapshot().State)
+
+	breaker.RecordSuccess()
+	assert.Equal(t, StateHalfOpen, breaker.Snapshot().State)
+	breaker.RecordSuccess()
+
+	snapshot := breaker.Snapshot()
+	assert.Equal(t, StateClosed, snapshot.State)
+	assert.Empty(t, snapshot.Window)
+
+	require.Len(t, *changes, 3)
+	assert.Equal(t, []string{StateOpen, StateHalfOpen, StateClosed},
+		[]string{(*changes)[0].To, (*changes)[1].To, (*changes)[2].To})
+}
+
+// Тест: ошибка пробного вызова снова открывает breaker
+func TestCircuitBreaker_HalfOpenFailureReopens(t *testing.T) {
+	breaker, _ := newTestBreaker(Config{BufferSize: 1, FailureRate: 0.4, Timeout: time.Millisecond, HalfOpenMaxCalls: 3})
+
+	breaker.RecordFailure()
+	time.Sleep(5 * time.Millisecond)
+
+	require.True(t, breaker.AllowRequest())
+	breaker.RecordFailure()
+
+	assert.Equal(t, StateOpen, breaker.Snapshot().State)
+	assert.False(t, breaker.AllowRequest())
+}
+
+// Тест: отменённый пробный вызов не учитывается и освобождает место в half-open
+func TestCircuitBreaker_ReleaseHalfOpenTrial(t *testing.T) {
+	breaker, _ := newTestBreaker(Config{BufferSize: 1, FailureRate: 0.4, Timeout: time.Millisecond, HalfOpenMaxCalls: 1})
+
+	breaker.RecordFailure()
+	time.Sleep(5 * time.Millisecond)
+
+	require.True(t, breaker.AllowRequest())
+	assert.False(t, breaker.AllowRequest())
+
+	breaker.Release()
+	assert.Equal(t, StateHalfOpen, breaker.Snapshot().State)
+	require.True(t, breaker.AllowRequest())
+
+	breaker.RecordSuccess()
+	assert.Equal(t, StateClosed, breaker.Snapshot().State)
+}
+
+// Тест: доля медленных вызовов открывает breaker без ошибок
+func TestCircuitBreaker_SlowCallRate(t *testing.T) {
+	breaker, changes := newTestBreaker(Config{
+		BufferSize: 4, FailureRate: 0.5, Timeout: time.Minute,
+		SlowCallDuration: 100 * time.Millisecond, SlowCallRate: 0.5,
+	})
+
+	breaker.Record(true, 10*time.Millisecond)
+	breaker.Record(true, 200*time.Millisecond)
+	breaker.Record(true, 200*time.Millisecond)
+	assert.Equal(t, StateClosed, breaker.Snapshot().State)
+
+	breaker.Record(true, 200*time.Millisecond)
+	assert.Equal(t, StateOpen, breaker.Snapshot().State)
+
+	require.Len(t, *changes, 1)
+	assert.Equal(t, "slow call rate threshold exceeded", (*changes)[0].Reason)
+}
+
+// Тест: ручное состояние имеет приоритет, сброс закрывает breaker
+func TestCircuitBreaker_ForceAndReset(t *testing.T) {
+	breaker, _ := newTestBreaker(Config{BufferSize: 1, FailureRate: 0.4, Timeout: time.Minute})
+
+	breaker.ForceOpen()
+	assert.False(t, breaker.AllowRequest())
+
+	breaker.ForceClosed()
+	breaker.RecordFailure()
+	assert.True(t, breaker.AllowRequest())
+
+	breaker.Reset()
+	snapshot := breaker.Snapshot()
+	assert.Equal(t, StateClosed, snapshot.State)
+	assert.Empty(t, snapshot.ForcedState)
+	assert.Empty(t, snapshot.Window)
+}
+
+// Тест: при конкурентных вызовах в half-open проходит не больше разрешённых
+// пробных запросов (запускать с -race)
+func TestCircuitBreaker_ConcurrentHalfOpen(t *testing.T) {
+	breaker, _ := newTestBreaker(Config{BufferSize: 1, FailureRate: 0.4, Timeout: time.Millisecond, HalfOpenMaxCalls: 3})
+
+	breaker.RecordFailure()
+	time.Sleep(5 * time.Millisecond)
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if breaker.AllowRequest() {
+				allowed.Add(1)
+			}
+			breaker.Snapshot()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(3), allowed.Load())
+}
+
+// Тест: конкурентная запись результатов и чтение состояния (запускать с -race)
+func TestCircuitBreaker_ConcurrentRecord(t *testing.T) {
+	breaker, _ := newTestBreaker(Config{BufferSize: 10, FailureRate: 0.5, Timeout: time.Millisecond, HalfOpenMaxCalls: 2})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if breaker.AllowRequest() {
+				breaker.Record(i%3 != 0, time.Duration(i)*time.Millisecond)
+			}
+			breaker.Snapshot()
+			breaker.GetFailureRate()
+		}(i)
+	}
+	wg.Wait()
+
+	state := breaker.Snapshot().State
+	assert.Contains(t, []string{StateClosed, StateOpen, StateHalfOpen}, state)
+}
@@ -0,0 +1,463 @@
+package circuitbreaker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisTimeout = 200 * time.Millisecond
+	// Как долго локальная копия окна считается актуальной
+	windowCacheTTL = time.Second
+	// Результаты, которые не успели записать в Redis, отбрасываются
+	windowQueueSize = 1024
+)
+
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func stateKey(name string) string {
+	return "circuit_breaker:" + name + ":state"
+}
+
+func windowKey(name string) string {
+	return "circuit_breaker:" + name + ":window"
+}
+
+func bucketKey(name string, epoch int64) string {
+	return "circuit_breaker:" + name + ":bucket:" + strconv.FormatInt(epoch, 10)
+}
+
+func (r *RedisStore) LoadState(name string) (SharedState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := r.client.Get(ctx, stateKey(name)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return SharedState{}, nil
+	}
+	if err != nil {
+		return SharedState{}, err
+	}
+
+	var state SharedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return SharedState{}, err
+	}
+
+	return state, nil
+}
+
+func (r *RedisStore) SaveState(name string, state SharedState) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(ctx, stateKey(name), data, 0).Err()
+}
+
+func (r *RedisStore) NewWindow(name string, cfg Config) Window {
+	window := &redisWindow{
+		client:	r.client,
+		name:	name,
+		cfg:	cfg,
+		ops:	make(chan windowOp, windowQueueSize),
+		clears:	make(chan struct{}, 1),
+	}
+
+	if cfg.WindowType == WindowTime {
+		window.local = NewTimeWindow(cfg.WindowDuration, cfg.WindowBuckets)
+	} else {
+		window.local = NewRingBuffer(max(cfg.BufferSize, 1))
+	}
+
+	go window.flush()
+
+	return window
+}
+
+type windowOp struct {
+	result		RequestResult
+	generation	int
+}
+
+type windowTotals struct {
+	calls		int
+	failures	int
+	slow		int
+}
+
+// Окно, общее для реплик: результаты асинхронно пишутся в Redis,
+// а доли считаются по копии, которая перечитывается в фоне раз в
+// windowCacheTTL. Окно вызывается под блокировкой breaker'а, поэтому
+// ни один его метод не ждёт Redis. Пока Redis недоступен, используется
+// локальное окно
+type redisWindow struct {
+	client		*redis.Client
+	name		string
+	cfg			Config
+	local		Window
+	ops			chan windowOp
+	// Будит flusher, чтобы очистить окно в Redis
+	clears		chan struct{}
+
+	mu			sync.Mutex
+	cached		windowTotals
+	// Результаты, ещё не записанные в Redis, добавляются к прочитанным
+	pending		windowTotals
+	// Меняется при очистке: результаты прошлых поколений в Redis не пишутся
+	generation	int
+	fetchedAt	time.Time
+	refreshing	bool
+	healthy		bool
+}
+
+func (w *redisWindow) Local() Window {
+	return w.local
+}
+
+func (w *redisWindow) Add(result RequestResult) {
+	w.local.Add(result)
+
+	w.mu.Lock()
+	w.cached.add(result)
+	w.pending.add(result)
+	generation := w.generation
+	w.mu.Unlock()
+
+	select {
+		case w.ops <- windowOp{result: result, generation: generation}:
+		default:
+			w.written(windowOp{result: result, generation: generation})
+			slog.Warn("circuit breaker shared window queue is full, result dropped", "circuit_breaker", w.name)
+	}
+}
+
+func (w *redisWindow) Clear() {
+	w.local.Clear()
+
+	w.mu.Lock()
+	w.cached = windowTotals{}
+	w.pending = windowTotals{}
+	w.generation++
+	w.fetchedAt = time.Now()
+	w.mu.Unlock()
+
+	select {
+		case w.clears <- struct{}{}:
+		default:
+	}
+}
+
+func (w *redisWindow) GetFailureRate() float64 {
+	totals, ok := w.totals()
+	if !ok {
+		return w.local.GetFailureRate()
+	}
+	if totals.calls == 0 {
+		return 0.0
+	}
+
+	return float64(totals.failures) / float64(totals.calls)
+}
+
+func (w *redisWindow) GetSlowCallRate() float64 {
+	totals, ok := w.totals()
+	if !ok {
+		return w.local.GetSlowCallRate()
+	}
+	if totals.calls == 0 {
+		return 0.0
+	}
+
+	return float64(totals.slow) / float64(totals.calls)
+}
+
+func (w *redisWindow) Count() int {
+	totals, ok := w.totals()
+	if !ok {
+		return w.local.Count()
+	}
+
+	return totals.calls
+}
+
+// Возвращает копию без обращения к Redis; устаревшая копия
+// перечитывается в фоне
+func (w *redisWindow) totals() (windowTotals, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if time.Since(w.fetchedAt) >= windowCacheTTL && !w.refreshing {
+		w.refreshing = true
+		go w.refresh()
+	}
+
+	return w.cached, w.healthy
+}
+
+func (w *redisWindow) refresh() {
+	w.mu.Lock()
+	generation := w.generation
+	w.mu.Unlock()
+
+	totals, err := w.fetch()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.refreshing = false
+	w.fetchedAt = time.Now()
+
+	// Окно очистили, пока шло чтение: прочитанное уже неактуально
+	if generation != w.generation {
+		return
+	}
+
+	if err != nil {
+		if w.healthy {
+			slog.Warn("circuit breaker shared window unavailable, using local one", "circuit_breaker", w.name, "error", err.Error())
+		}
+		w.healthy = false
+		return
+	}
+
+	w.cached = totals
+	w.cached.merge(w.pending)
+	w.healthy = true
+}
+
+func (w *redisWindow) fetch() (windowTotals, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if w.cfg.WindowType != WindowTime {
+		codes, err := w.client.LRange(ctx, windowKey(w.name), 0, -1).Result()
+		if err != nil {
+			return windowTotals{}, err
+		}
+
+		var totals windowTotals
+		for _, code := range codes {
+			totals.add(decodeResult(code))
+		}
+		return totals, nil
+	}
+
+	epochs := w.epochs()
+	pipe := w.client.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(epochs))
+	for i, epoch := range epochs {
+		cmds[i] = pipe.HMGet(ctx, bucketKey(w.name, epoch), "calls", "failures", "slow")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return windowTotals{}, err
+	}
+
+	var totals windowTotals
+	for _, cmd := range cmds {
+		values := cmd.Val()
+		totals.calls += parseCount(values, 0)
+		totals.failures += parseCount(values, 1)
+		totals.slow += parseCount(values, 2)
+	}
+
+	return totals, nil
+}
+
+// Все записи в Redis выполняются по порядку в одной горутине. Перед
+// результатом нового поколения окно в Redis очищается, результаты
+// прошлых поколений отбрасываются
+func (w *redisWindow) flush() {
+	cleared := 0
+
+	for {
+		var op windowOp
+		hasOp := true
+
+		select {
+			case op = <-w.ops:
+			case <-w.clears:
+				hasOp = false
+		}
+
+		w.mu.Lock()
+		generation := w.generation
+		w.mu.Unlock()
+
+		if cleared < generation {
+			ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+			if err := w.clearShared(ctx); err != nil {
+				slog.Warn("circuit breaker shared window clear error", "circuit_breaker", w.name, "error", err.Error())
+			}
+			cancel()
+			cleared = generation
+		}
+
+		if !hasOp || op.generation < generation {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+
+		var err error
+		if w.cfg.WindowType == WindowTime {
+			err = w.addBucket(ctx, op.result)
+		} else {
+			pipe := w.client.TxPipeline()
+			pipe.LPush(ctx, windowKey(w.name), encodeResult(op.result))
+			pipe.LTrim(ctx, windowKey(w.name), 0, int64(max(w.cfg.BufferSize, 1)-1))
+			_, err = pipe.Exec(ctx)
+		}
+
+		cancel()
+		w.written(op)
+
+		if err != nil {
+			slog.Warn("circuit breaker shared window write error", "circuit_breaker", w.name, "error", err.Error())
+		}
+	}
+}
+
+func (w *redisWindow) written(op windowOp) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if op.generation == w.generation {
+		w.pending.remove(op.result)
+	}
+}
+
+func (w *redisWindow) addBucket(ctx context.Context, result RequestResult) error {
+	key := bucketKey(w.name, w.epochOf(result.Time))
+
+	pipe := w.client.TxPipeline()
+	pipe.HIncrBy(ctx, key, "calls", 1)
+	if !result.Success {
+		pipe.HIncrBy(ctx, key, "failures", 1)
+	}
+	if result.Slow {
+		pipe.HIncrBy(ctx, key, "slow", 1)
+	}
+	pipe.PExpire(ctx, key, w.cfg.WindowDuration + w.bucketWidth())
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (w *redisWindow) clearShared(ctx context.Context) error {
+	if w.cfg.WindowType != WindowTime {
+		return w.client.Del(ctx, windowKey(w.name)).Err()
+	}
+
+	epochs := w.epochs()
+	keys := make([]string, len(epochs))
+	for i, epoch := range epochs {
+		keys[i] = bucketKey(w.name, epoch)
+	}
+
+	return w.client.Del(ctx, keys...).Err()
+}
+
+func (w *redisWindow) bucketWidth() time.Duration {
+	width := w.cfg.WindowDuration / time.Duration(max(w.cfg.WindowBuckets, 1))
+	if width <= 0 {
+		width = time.Second
+	}
+	return width
+}
+
+func (w *redisWindow) epochOf(t time.Time) int64 {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UnixNano() / int64(w.bucketWidth())
+}
+
+// Корзины, попадающие в окно на текущий момент
+func (w *redisWindow) epochs() []int64 {
+	count := int64(max(w.cfg.WindowBuckets, 1))
+	current := w.epochOf(time.Now())
+
+	epochs := make([]int64, 0, count)
+	for epoch := current - count + 1; epoch <= current; epoch++ {
+		epochs = append(epochs, epoch)
+	}
+
+	return epochs
+}
+
+// Результат в списке: "1" - успех, "0" - ошибка, суффикс "s" - медленный вызов
+func encodeResult(result RequestResult) string {
+	code := "0"
+	if result.Success {
+		code = "1"
+	}
+	if result.Slow {
+		code += "s"
+	}
+	return code
+}
+
+func decodeResult(code string) RequestResult {
+	return RequestResult{
+		Success:	len(code) > 0 && code[0] == '1',
+		Slow:		len(code) > 1 && code[1] == 's',
+	}
+}
+
+func (t *windowTotals) add(result RequestResult) {
+	t.calls++
+	if !result.Success {
+		t.failures++
+	}
+	if result.Slow {
+		t.slow++
+	}
+}
+
+func (t *windowTotals) remove(result RequestResult) {
+	t.calls--
+	if !result.Success {
+		t.failures--
+	}
+	if result.Slow {
+		t.slow--
+	}
+}
+
+func (t *windowTotals) merge(other windowTotals) {
+	t.calls += other.calls
+	t.failures += other.failures
+	t.slow += other.slow
+}
+
+func parseCount(values []interface{}, i int) int {
+	if i >= len(values) || values[i] == nil {
+		return 0
+	}
+
+	s, ok := values[i].(string)
+	if !ok {
+		return 0
+	}
+
+	n, _ := strconv.Atoi(s)
+	return n
+}
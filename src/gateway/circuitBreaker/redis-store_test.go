@@ -0,0 +1,144 @@
+package circuitbreaker
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupSharedRegistries(t *testing.T, cfg Config) (*Registry, *Registry, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	first := NewSharedRegistry(NewRedisStore(client), 0)
+	second := NewSharedRegistry(NewRedisStore(client), 0)
+	first.Register("payment", cfg)
+	second.Register("payment", cfg)
+
+	return first, second, mr
+}
+
+// Тест: breaker, открытый одной репликой, открывается и на другой
+func TestRedisStore_StateSharedBetweenReplicas(t *testing.T) {
+	first, second, _ := setupSharedRegistries(t, Config{BufferSize: 2, FailureRate: 0.4, Timeout: time.Minute})
+
+	firstCB := first.MustGet("payment")
+	secondCB := second.MustGet("payment")
+
+	firstCB.RecordFailure()
+	firstCB.RecordFailure()
+	require.Equal(t, StateOpen, firstCB.Snapshot().State)
+
+	assert.False(t, secondCB.AllowRequest())
+	assert.Equal(t, StateOpen, secondCB.Snapshot().State)
+
+	secondCB.Reset()
+	assert.True(t, firstCB.AllowRequest())
+	assert.Equal(t, StateClosed, firstCB.Snapshot().State)
+}
+
+// Тест: ручное состояние, выставленное на одной реплике, видно на другой
+func TestRedisStore_ForcedStateShared(t *testing.T) {
+	first, second, _ := setupSharedRegistries(t, Config{BufferSize: 2, FailureRate: 0.4, Timeout: time.Minute})
+
+	first.MustGet("payment").ForceOpen()
+
+	snapshot := second.MustGet("payment").Snapshot()
+	assert.Equal(t, StateOpen, snapshot.ForcedState)
+	assert.True(t, snapshot.Shared)
+}
+
+// Тест: ошибки разных реплик попадают в общее окно
+func TestRedisStore_WindowShared(t *testing.T) {
+	first, second, mr := setupSharedRegistries(t, Config{BufferSize: 4, FailureRate: 0.4, Timeout: time.Minute})
+
+	first.MustGet("payment").RecordFailure()
+	first.MustGet("payment").RecordFailure()
+
+	assert.Eventually(t, func() bool {
+		codes, _ := mr.List(windowKey("payment"))
+		return len(codes) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// Общее окно перечитывается в фоне
+	window := second.MustGet("payment").Window
+	assert.Eventually(t, func() bool {
+		return window.Count() == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1.0, window.GetFailureRate())
+}
+
+// Тест: при недоступном Redis используется локальное окно
+func TestRedisStore_FallbackToLocalWindow(t *testing.T) {
+	first, _, mr := setupSharedRegistries(t, Config{BufferSize: 2, FailureRate: 0.4, Timeout: time.Minute})
+	mr.Close()
+
+	breaker := first.MustGet("payment")
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+
+	assert.Equal(t, StateOpen, breaker.Snapshot().State)
+	assert.False(t, breaker.AllowRequest())
+}
+
+// Тест: очистка окна не ждёт flusher, даже если он завис на Redis
+func TestRedisWindow_ClearDoesNotBlock(t *testing.T) {
+	// Без горутины flush и с небуферизованной очередью: любая
+	// блокирующая отправка зависла бы навсегда
+	window := &redisWindow{
+		name:	"payment",
+		local:	NewRingBuffer(2),
+		ops:	make(chan windowOp),
+		clears:	make(chan struct{}, 1),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		window.Clear()
+		window.Clear()
+		close(done)
+	}()
+
+	select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Clear blocked")
+	}
+}
+
+// Тест: при зависшем Redis запись результата не ждёт его под блокировкой breaker'а
+func TestRedisStore_RecordDoesNotWaitForRedis(t *testing.T) {
+	// Принимает соединения и ничего не отвечает
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String()})
+	t.Cleanup(func() { client.Close() })
+
+	registry := NewSharedRegistry(NewRedisStore(client), time.Hour)
+	registry.Register("payment", Config{BufferSize: 10, FailureRate: 0.4, Timeout: time.Minute, MinimumCalls: 5})
+	breaker := registry.MustGet("payment")
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		breaker.RecordSuccess()
+	}
+
+	assert.Less(t, time.Since(start), redisTimeout/2)
+}
@@ -0,0 +1,94 @@
+package circuitbreaker
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Именованные circuit breaker'ы для каждого нижестоящего сервиса
+type Registry struct {
+	mu			sync.RWMutex
+	breakers	map[string]*CircuitBreaker
+	listeners	[]func(StateChange)
+
+	shared			SharedStore
+	syncInterval	time.Duration
+}
+
+func NewRegistry() *Registry {
+	return &Registry{breakers: make(map[string]*CircuitBreaker)}
+}
+
+// Реестр, breaker'ы которого делят состояние между репликами через store
+func NewSharedRegistry(store SharedStore, syncInterval time.Duration) *Registry {
+	return &Registry{
+		breakers:		make(map[string]*CircuitBreaker),
+		shared:			store,
+		syncInterval:	syncInterval,
+	}
+}
+
+func (r *Registry) Register(name string, cfg Config) *CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	breaker := NewCircuitBreakerWithConfig(cfg)
+	breaker.Name = name
+	if r.shared != nil {
+		breaker.useSharedStore(r.shared, cfg, r.syncInterval)
+	}
+	for _, listener := range r.listeners {
+		breaker.OnStateChange(listener)
+	}
+	r.breakers[name] = breaker
+
+	return breaker
+}
+
+// Подписывает на переходы все зарегистрированные и будущие breaker'ы
+func (r *Registry) OnStateChange(listener func(StateChange)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.listeners = append(r.listeners, listener)
+	for _, breaker := range r.breakers {
+		breaker.OnStateChange(listener)
+	}
+}
+
+func (r *Registry) Get(name string) (*CircuitBreaker, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	breaker, ok := r.breakers[name]
+	return breaker, ok
+}
+
+// Для сервисов, без которых gateway не может работать
+func (r *Registry) MustGet(name string) *CircuitBreaker {
+	breaker, ok := r.Get(name)
+	if !ok {
+		panic("circuit breaker " + name + " is not configured")
+	}
+	return breaker
+}
+
+func (r *Registry) Snapshots() []Snapshot {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.breakers))
+	for name := range r.breakers {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+
+	sort.Strings(names)
+
+	snapshots := make([]Snapshot, 0, len(names))
+	for _, name := range names {
+		breaker, _ := r.Get(name)
+		snapshots = append(snapshots, breaker.Snapshot())
+	}
+
+	return snapshots
+}
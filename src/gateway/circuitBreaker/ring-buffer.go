@@ -6,8 +6,9 @@ import (
 )
 
 type RequestResult struct {
-	Success 	bool
-	Time		time.Time
+	Success 	bool		`json:"success"`
+	Slow		bool		`json:"slow,omitempty"`
+	Time		time.Time	`json:"time"`
 }
 
 type RingBuffer struct {
@@ -59,6 +60,47 @@ func (rb *RingBuffer) GetFailureRate() float64 {
 	return float64(failures) / float64(rb.count)
 }
 
+func (rb *RingBuffer) GetSlowCallRate() float64 {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+
+	if rb.count == 0 {
+		return 0.0
+	}
+
+	slow := 0
+	for i := 0; i < rb.count; i++ {
+		idx := (rb.head - 1 - i + rb.size) % rb.size
+
+		if rb.buffer[idx].Slow {
+			slow++
+		}
+	}
+
+	return float64(slow) / float64(rb.count)
+}
+
+func (rb *RingBuffer) Count() int {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+
+	return rb.count
+}
+
+// Содержимое окна от старых результатов к новым
+func (rb *RingBuffer) Results() []RequestResult {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+
+	results := make([]RequestResult, rb.count)
+	for i := 0; i < rb.count; i++ {
+		idx := (rb.head - rb.count + i + rb.size) % rb.size
+		results[i] = rb.buffer[idx]
+	}
+
+	return results
+}
+
 func (rb *RingBuffer) IsRecentFailure() bool {
 	rb.mu.RLock()
 	defer rb.mu.RUnlock()
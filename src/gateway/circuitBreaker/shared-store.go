@@ -0,0 +1,19 @@
+package circuitbreaker
+
+import "time"
+
+// Состояние breaker'а, общее для всех реплик gateway
+type SharedState struct {
+	State			string		`json:"state"`
+	ForcedState		string		`json:"forcedState,omitempty"`
+	OpenedAt		time.Time	`json:"openedAt"`
+	UpdatedAt		time.Time	`json:"updatedAt"`
+}
+
+// Хранилище общего состояния и окна результатов. Реплики кэшируют
+// состояние локально и сверяются с хранилищем не чаще syncInterval
+type SharedStore interface {
+	LoadState(name string) (SharedState, error)
+	SaveState(name string, state SharedState) error
+	NewWindow(name string, cfg Config) Window
+}
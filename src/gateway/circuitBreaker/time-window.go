@@ -0,0 +1,176 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	WindowCount = "count"
+	WindowTime = "time"
+)
+
+// Окно, по которому breaker считает долю ошибок и медленных вызовов
+type Window interface {
+	Add(result RequestResult)
+	GetFailureRate() float64
+	GetSlowCallRate() float64
+	Count() int
+	Clear()
+}
+
+type BucketStats struct {
+	Start		time.Time	`json:"start"`
+	Calls		int			`json:"calls"`
+	Failures	int			`json:"failures"`
+	Slow		int			`json:"slow"`
+}
+
+type bucket struct {
+	epoch		int64
+	calls		int
+	failures	int
+	slow		int
+}
+
+// Окно за последние duration: результаты агрегируются по корзинам
+// одинаковой ширины, поэтому память не зависит от нагрузки
+type TimeWindow struct {
+	buckets		[]bucket
+	width		time.Duration
+	mu			sync.RWMutex
+	now			func() time.Time
+}
+
+func NewTimeWindow(duration time.Duration, bucketCount int) *TimeWindow {
+	if bucketCount < 1 {
+		bucketCount = 1
+	}
+
+	width := duration / time.Duration(bucketCount)
+	if width <= 0 {
+		width = time.Second
+	}
+
+	return &TimeWindow{
+		buckets:	make([]bucket, bucketCount),
+		width:		width,
+		now:		time.Now,
+	}
+}
+
+func (tw *TimeWindow) epochOf(t time.Time) int64 {
+	return t.UnixNano() / int64(tw.width)
+}
+
+func (tw *TimeWindow) Add(result RequestResult) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	at := result.Time
+	if at.IsZero() {
+		at = tw.now()
+	}
+
+	epoch := tw.epochOf(at)
+	current := tw.epochOf(tw.now())
+	if epoch <= current - int64(len(tw.buckets)) {
+		return
+	}
+
+	b := &tw.buckets[epoch % int64(len(tw.buckets))]
+	if b.epoch != epoch {
+		*b = bucket{epoch: epoch}
+	}
+
+	b.calls++
+	if !result.Success {
+		b.failures++
+	}
+	if result.Slow {
+		b.slow++
+	}
+}
+
+// Вызывается под блокировкой
+func (tw *TimeWindow) totals() (calls, failures, slow int) {
+	current := tw.epochOf(tw.now())
+	oldest := current - int64(len(tw.buckets)) + 1
+
+	for _, b := range tw.buckets {
+		if b.epoch < oldest || b.epoch > current {
+			continue
+		}
+		calls += b.calls
+		failures += b.failures
+		slow += b.slow
+	}
+
+	return calls, failures, slow
+}
+
+func (tw *TimeWindow) GetFailureRate() float64 {
+	tw.mu.RLock()
+	defer tw.mu.RUnlock()
+
+	calls, failures, _ := tw.totals()
+	if calls == 0 {
+		return 0.0
+	}
+
+	return float64(failures) / float64(calls)
+}
+
+func (tw *TimeWindow) GetSlowCallRate() float64 {
+	tw.mu.RLock()
+	defer tw.mu.RUnlock()
+
+	calls, _, slow := tw.totals()
+	if calls == 0 {
+		return 0.0
+	}
+
+	return float64(slow) / float64(calls)
+}
+
+func (tw *TimeWindow) Count() int {
+	tw.mu.RLock()
+	defer tw.mu.RUnlock()
+
+	calls, _, _ := tw.totals()
+	return calls
+}
+
+// Непустые корзины окна от старых к новым
+func (tw *TimeWindow) Buckets() []BucketStats {
+	tw.mu.RLock()
+	defer tw.mu.RUnlock()
+
+	current := tw.epochOf(tw.now())
+
+	stats := []BucketStats{}
+	for epoch := current - int64(len(tw.buckets)) + 1; epoch <= current; epoch++ {
+		b := tw.buckets[((epoch % int64(len(tw.buckets))) + int64(len(tw.buckets))) % int64(len(tw.buckets))]
+		if b.epoch != epoch || b.calls == 0 {
+			continue
+		}
+
+		stats = append(stats, BucketStats{
+			Start:		time.Unix(0, epoch * int64(tw.width)),
+			Calls:		b.calls,
+			Failures:	b.failures,
+			Slow:		b.slow,
+		})
+	}
+
+	return stats
+}
+
+func (tw *TimeWindow) Clear() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	for i := range tw.buckets {
+		tw.buckets[i] = bucket{}
+	}
+}
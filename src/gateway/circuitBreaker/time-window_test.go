@@ -0,0 +1,90 @@
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTimeWindow(duration time.Duration, buckets int) (*TimeWindow, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	window := NewTimeWindow(duration, buckets)
+	window.now = func() time.Time { return now }
+	return window, &now
+}
+
+// Тест: учитываются только результаты за последние duration
+func TestTimeWindow_ExpiresOldResults(t *testing.T) {
+	window, now := newTestTimeWindow(10*time.Second, 10)
+
+	window.Add(RequestResult{Success: false, Time: *now})
+	window.Add(RequestResult{Success: false, Time: *now})
+
+	*now = now.Add(5 * time.Second)
+	window.Add(RequestResult{Success: true, Time: *now})
+
+	assert.Equal(t, 3, window.Count())
+	assert.InDelta(t, 2.0/3.0, window.GetFailureRate(), 0.001)
+
+	// Ошибки вышли из окна, остался только успешный вызов
+	*now = now.Add(6 * time.Second)
+	assert.Equal(t, 1, window.Count())
+	assert.Zero(t, window.GetFailureRate())
+
+	*now = now.Add(time.Minute)
+	assert.Zero(t, window.Count())
+}
+
+// Тест: корзина переиспользуется по кругу, память не растёт
+func TestTimeWindow_BucketsReused(t *testing.T) {
+	window, now := newTestTimeWindow(3*time.Second, 3)
+
+	for i := 0; i < 100; i++ {
+		window.Add(RequestResult{Success: i%2 == 0, Slow: true, Time: *now})
+		*now = now.Add(500 * time.Millisecond)
+	}
+
+	assert.Len(t, window.buckets, 3)
+	assert.LessOrEqual(t, window.Count(), 6)
+	assert.Equal(t, 1.0, window.GetSlowCallRate())
+
+	buckets := window.Buckets()
+	require.NotEmpty(t, buckets)
+	for i := 1; i < len(buckets); i++ {
+		assert.True(t, buckets[i-1].Start.Before(buckets[i].Start))
+	}
+}
+
+// Тест: breaker в режиме time открывается по ошибкам внутри окна
+func TestCircuitBreaker_TimeWindowMode(t *testing.T) {
+	breaker := NewCircuitBreakerWithConfig(Config{
+		WindowType: WindowTime, WindowDuration: time.Minute, WindowBuckets: 6,
+		FailureRate: 0.5, Timeout: time.Minute, MinimumCalls: 3,
+	})
+
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	assert.Equal(t, StateClosed, breaker.Snapshot().State)
+
+	breaker.RecordFailure()
+
+	snapshot := breaker.Snapshot()
+	assert.Equal(t, StateOpen, snapshot.State)
+	assert.Equal(t, WindowTime, snapshot.WindowType)
+	require.Len(t, snapshot.Buckets, 1)
+	assert.Equal(t, 3, snapshot.Buckets[0].Failures)
+}
+
+// Тест: без MinimumCalls одна ошибка в почти пустом окне time не открывает breaker
+func TestCircuitBreaker_TimeWindowMode_DefaultMinimumCalls(t *testing.T) {
+	breaker := NewCircuitBreakerWithConfig(Config{
+		WindowType: WindowTime, WindowDuration: time.Minute, WindowBuckets: 6,
+		FailureRate: 0.5, Timeout: time.Minute,
+	})
+
+	breaker.RecordFailure()
+	assert.Equal(t, StateClosed, breaker.Snapshot().State)
+	assert.Equal(t, DefaultMinimumCalls, breaker.Snapshot().MinimumCalls)
+}
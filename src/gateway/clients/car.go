@@ -0,0 +1,132 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/balancer"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/bulkhead"
+	cb "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/circuitBreaker"
+	cfg "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/config"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/models"
+)
+
+type CarClient interface {
+	ListCars(ctx context.Context, query url.Values) (*models.PaginationResponse, error)
+	GetCar(ctx context.Context, uid string) (*models.ShortCarResponse, error)
+	GetCars(ctx context.Context, uids []string) ([]models.ShortCarResponse, error)
+	GetCalendar(ctx context.Context, uid string, query url.Values) (*models.CarCalendar, error)
+	// Удерживает автомобиль на интервал [dateFrom, dateTo). Без подтверждения
+	// удержание истекает само
+	Reserve(ctx context.Context, uid, dateFrom, dateTo string) (*models.ReservationResponse, error)
+	Confirm(ctx context.Context, uid, reservationUid, rentalUid string) error
+	Release(ctx context.Context, uid, reservationUid string) error
+	// Откладывает Release в очередь повторов
+	QueueRelease(ctx context.Context, uid, reservationUid string) error
+	// Снимает бронь аренды
+	ReleaseRental(ctx context.Context, uid, rentalUid string) error
+	// Откладывает ReleaseRental в очередь повторов
+	QueueReleaseRental(ctx context.Context, uid, rentalUid string) error
+}
+
+// Данные об автомобиле, если Car Service недоступен
+func CarFallback(uid string) models.CarInfo {
+	return models.CarInfo{CarUID: uid}
+}
+
+type carClient struct {
+	baseClient
+}
+
+func NewCarClient(targets *balancer.Balancer, httpClient *http.Client, breaker *cb.CircuitBreaker, bulkhead *bulkhead.Bulkhead) CarClient {
+	return &carClient{baseClient{service: cfg.DownstreamCar, targets: targets, http: httpClient, breaker: breaker, bulkhead: bulkhead}}
+}
+
+func (c *carClient) ListCars(ctx context.Context, query url.Values) (*models.PaginationResponse, error) {
+	var page models.PaginationResponse
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/cars", query: query}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (c *carClient) GetCar(ctx context.Context, uid string) (*models.ShortCarResponse, error) {
+	var car models.ShortCarResponse
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/cars/" + url.PathEscape(uid)}, &car); err != nil {
+		return nil, err
+	}
+	return &car, nil
+}
+
+func (c *carClient) GetCars(ctx context.Context, uids []string) ([]models.ShortCarResponse, error) {
+	var cars []models.ShortCarResponse
+	r := request{method: http.MethodPost, path: "/cars/query", body: models.CarsRequest{UIDs: uids}}
+	if err := c.do(ctx, r, &cars); err != nil {
+		return nil, err
+	}
+	return cars, nil
+}
+
+func (c *carClient) GetCalendar(ctx context.Context, uid string, query url.Values) (*models.CarCalendar, error) {
+	var calendar models.CarCalendar
+	r := request{method: http.MethodGet, path: "/cars/" + url.PathEscape(uid) + "/calendar", query: query}
+	if err := c.do(ctx, r, &calendar); err != nil {
+		return nil, err
+	}
+	return &calendar, nil
+}
+
+func (c *carClient) Reserve(ctx context.Context, uid, dateFrom, dateTo string) (*models.ReservationResponse, error) {
+	var reservation models.ReservationResponse
+	r := request{
+		method:	http.MethodPost,
+		path:	"/cars/" + url.PathEscape(uid) + "/reservations",
+		body:	models.ReservationRequest{DateFrom: dateFrom, DateTo: dateTo},
+	}
+	if err := c.do(ctx, r, &reservation); err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+func (c *carClient) Confirm(ctx context.Context, uid, reservationUid, rentalUid string) error {
+	r := request{
+		method:	http.MethodPost,
+		path:	reservationPath(uid, reservationUid) + "/confirm",
+		body:	models.ReservationConfirmRequest{RentalUID: rentalUid},
+	}
+	return c.do(ctx, r, nil)
+}
+
+func (c *carClient) Release(ctx context.Context, uid, reservationUid string) error {
+	return c.do(ctx, releaseRequest(uid, reservationUid), nil)
+}
+
+func (c *carClient) QueueRelease(ctx context.Context, uid, reservationUid string) error {
+	return c.enqueue(ctx, releaseRequest(uid, reservationUid))
+}
+
+func (c *carClient) ReleaseRental(ctx context.Context, uid, rentalUid string) error {
+	return c.do(ctx, releaseRentalRequest(uid, rentalUid), nil)
+}
+
+func (c *carClient) QueueReleaseRental(ctx context.Context, uid, rentalUid string) error {
+	return c.enqueue(ctx, releaseRentalRequest(uid, rentalUid))
+}
+
+func reservationPath(uid, reservationUid string) string {
+	return "/cars/" + url.PathEscape(uid) + "/reservations/" + url.PathEscape(reservationUid)
+}
+
+func releaseRequest(uid, reservationUid string) request {
+	return request{method: http.MethodDelete, path: reservationPath(uid, reservationUid)}
+}
+
+func releaseRentalRequest(uid, rentalUid string) request {
+	return request{
+		method:	http.MethodDelete,
+		path:	"/cars/" + url.PathEscape(uid) + "/reservations",
+		query:	url.Values{"rentalUid": {rentalUid}},
+	}
+}
@@ -0,0 +1,253 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/deadline"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/tracing"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/balancer"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/bulkhead"
+	cb "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/circuitBreaker"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/metrics"
+	queue "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/queue"
+)
+
+// Таймаут вызова, если у контекста нет дедлайна (например, у компенсаций
+// при восстановлении саги)
+const defaultRequestTimeout = 5 * time.Second
+
+// Общий клиент с пулом соединений. Время вызова ограничено дедлайном
+// контекста запроса, а не таймаутом клиента
+func NewHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 32
+	transport.IdleConnTimeout = 90 * time.Second
+
+	return &http.Client{Transport: transport}
+}
+
+type request struct {
+	method	string
+	path	string
+	query	url.Values
+	headers	map[string]string
+	body	any
+}
+
+func userHeaders(username string) map[string]string {
+	return map[string]string{"X-User-Name": username}
+}
+
+// Общая часть клиентов: circuit breaker, трассировка, request_id,
+// дедлайн, метрики и преобразование ответа в типизированную ошибку
+type baseClient struct {
+	service		string
+	// Экземпляры сервиса, каждый со своим breaker'ом для исключения неисправных
+	targets		*balancer.Balancer
+	http		*http.Client
+	breaker		*cb.CircuitBreaker
+	bulkhead	*bulkhead.Bulkhead
+}
+
+func (c *baseClient) do(ctx context.Context, r request, out any) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultRequestTimeout)
+		defer cancel()
+	}
+
+	if c.breaker != nil && !c.breaker.AllowRequest() {
+		metrics.ObserveRejected(c.breaker.Name, r.method)
+		logging.FromContext(ctx).Warn("downstream is unavailable, circuit breaker is open", logging.KeyDownstream, c.service)
+		return &CallError{Service: c.service, Err: ErrCircuitOpen}
+	}
+
+	if err := c.acquire(ctx, r.method); err != nil {
+		c.release()
+		return &CallError{Service: c.service, Err: err}
+	}
+	defer c.bulkhead.Release()
+
+	instance, err := c.targets.Pick()
+	if err != nil {
+		c.release()
+		logging.FromContext(ctx).Warn("no available downstream instances", logging.KeyDownstream, c.service)
+		return &CallError{Service: c.service, Err: err}
+	}
+
+	req, err := c.newRequest(ctx, instance.URL, r)
+	if err != nil {
+		c.release()
+		instance.Release()
+		return &CallError{Service: c.service, Err: err}
+	}
+
+	spanCtx, span := tracing.Start(ctx, r.method + " " + req.URL.Path, tracing.KindClient)
+	defer span.End()
+
+	span.SetAttribute("http.method", r.method)
+	span.SetAttribute("http.url", req.URL.String())
+	span.SetAttribute("peer.service", c.service)
+	tracing.Inject(spanCtx, req.Header)
+	logging.Inject(ctx, req.Header)
+	deadline.Inject(ctx, req.Header)
+
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		c.finish(ctx, req, instance, 0, err, time.Since(start))
+		span.SetError(err)
+		return &CallError{Service: c.service, Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	c.finish(ctx, req, instance, resp.StatusCode, err, time.Since(start))
+
+	span.SetAttribute("http.status_code", strconv.Itoa(resp.StatusCode))
+	if err != nil {
+		span.SetError(err)
+		return &CallError{Service: c.service, Err: err}
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		statusErr := &StatusError{Service: c.service, Status: resp.StatusCode, Body: body}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			span.SetError(statusErr)
+		}
+		return statusErr
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%s service: %w: %v", c.service, ErrInvalidResponse, err)
+	}
+
+	return nil
+}
+
+// Место в bulkhead сервиса. При переполнении вызов отклоняется без ожидания
+func (c *baseClient) acquire(ctx context.Context, method string) error {
+	err := c.bulkhead.Acquire(ctx)
+	if errors.Is(err, bulkhead.ErrFull) {
+		metrics.ObserveBulkheadRejected(c.service, method)
+		logging.FromContext(ctx).Warn("downstream call rejected, bulkhead is full", logging.KeyDownstream, c.service)
+	}
+	return err
+}
+
+func (r request) url(baseURL string) string {
+	target := baseURL + r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+	return target
+}
+
+func (c *baseClient) newRequest(ctx context.Context, baseURL string, r request) (*http.Request, error) {
+	target := r.url(baseURL)
+
+	var body io.Reader
+	if r.body != nil {
+		data, err := json.Marshal(r.body)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+	if r.body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return req, nil
+}
+
+// Записывает результат в breaker'ы сервиса и экземпляра, метрики и лог. Если
+// клиент gateway закрыл соединение, сервис не виноват и вызов не учитывается.
+// Исчерпанный бюджет времени считается ошибкой: сервис не успел ответить
+func (c *baseClient) finish(ctx context.Context, req *http.Request, instance *balancer.Instance, status int, err error, duration time.Duration) {
+	metrics.ObserveDownstream(req.URL.Host, req.Method, status, err, duration)
+
+	level := slog.LevelDebug
+	if err != nil || status >= http.StatusInternalServerError {
+		level = slog.LevelWarn
+	}
+	logging.FromContext(ctx).Log(ctx, level, "downstream call",
+		logging.KeyDownstream, c.service,
+		"instance", req.URL.Host,
+		"method", req.Method,
+		"url", req.URL.Path,
+		"status", status,
+		"duration_ms", duration.Milliseconds(),
+		logging.Err(err),
+	)
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		c.release()
+		instance.Release()
+		return
+	}
+
+	success := err == nil && status < http.StatusInternalServerError
+	if c.breaker != nil {
+		c.breaker.Record(success, duration)
+	}
+	instance.Record(success, duration)
+}
+
+func (c *baseClient) release() {
+	if c.breaker != nil {
+		c.breaker.Release()
+	}
+}
+
+// Откладывает запрос в очередь повторов с контекстом трассировки и request_id
+func (c *baseClient) enqueue(ctx context.Context, r request) error {
+	var body []byte
+	if r.body != nil {
+		data, err := json.Marshal(r.body)
+		if err != nil {
+			return err
+		}
+		body = data
+	}
+
+	err := queue.EnqueueRetry(queue.RetryRequest{
+		Method:      r.method,
+		Service:     c.service,
+		Path:        r.url(""),
+		Headers:     r.headers,
+		Body:        body,
+		TraceParent: tracing.TraceParent(ctx),
+		RequestID:   logging.RequestID(ctx),
+	})
+
+	logging.FromContext(ctx).Warn("downstream request queued for retry",
+		logging.KeyDownstream, c.service, "method", r.method, "url", r.path, logging.Err(err))
+
+	return err
+}
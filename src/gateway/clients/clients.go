@@ -0,0 +1,62 @@
+package clients
+
+import (
+	"time"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/balancer"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/bulkhead"
+	cb "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/circuitBreaker"
+	cfg "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/config"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/metrics"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/queue"
+)
+
+type Config struct {
+	// Адреса экземпляров через запятую или dns+http://host:port/path
+	CarURL				string
+	RentalURL			string
+	PaymentURL			string
+	// round-robin или least-in-flight
+	Balancing			string
+	ResolveInterval		time.Duration
+	// Настройки breaker'а каждого экземпляра по сервисам
+	InstanceBreakers	map[string]cb.Config
+	Bulkheads			map[string]bulkhead.Config
+}
+
+// Клиенты нижестоящих сервисов с общим пулом соединений
+type Clients struct {
+	Car		CarClient
+	Rental	RentalClient
+	Payment	PaymentClient
+}
+
+func New(config Config, breakers *cb.Registry) Clients {
+	httpClient := NewHTTPClient()
+
+	return Clients{
+		Car:		NewCarClient(config.targets(cfg.DownstreamCar, config.CarURL), httpClient, breakers.MustGet(cfg.DownstreamCar), config.bulkhead(cfg.DownstreamCar)),
+		Rental:		NewRentalClient(config.targets(cfg.DownstreamRental, config.RentalURL), httpClient, breakers.MustGet(cfg.DownstreamRental), config.bulkhead(cfg.DownstreamRental)),
+		Payment:	NewPaymentClient(config.targets(cfg.DownstreamPayment, config.PaymentURL), httpClient, breakers.MustGet(cfg.DownstreamPayment), config.bulkhead(cfg.DownstreamPayment)),
+	}
+}
+
+// Балансировщик сервиса, он же используется воркером очереди повторов
+func (c Config) targets(name, target string) *balancer.Balancer {
+	b := balancer.NewFromTarget(name, target, balancer.Config{
+		Policy:				c.Balancing,
+		ResolveInterval:	c.ResolveInterval,
+		Breaker:			c.InstanceBreakers[name],
+	})
+	queue.RegisterTargets(name, b)
+
+	return b
+}
+
+// Bulkhead сервиса с метриками занятости
+func (c Config) bulkhead(name string) *bulkhead.Bulkhead {
+	b := bulkhead.New(name, c.Bulkheads[name])
+	metrics.RegisterBulkhead(name, b.InFlight, b.Waiting)
+
+	return b
+}
@@ -0,0 +1,278 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/balancer"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/bulkhead"
+	cb "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/circuitBreaker"
+	cfg "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/config"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/models"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/queue"
+)
+
+var testBreakerConfig = cb.Config{BufferSize: 10, FailureRate: 0.5, Timeout: time.Minute, MinimumCalls: 10}
+
+func newTestBreaker() *cb.CircuitBreaker {
+	return cb.NewCircuitBreakerWithConfig(testBreakerConfig)
+}
+
+func newTestTargets(urls ...string) *balancer.Balancer {
+	targets := balancer.New("test", balancer.Config{Breaker: testBreakerConfig})
+	targets.SetURLs(urls)
+	return targets
+}
+
+// Тест: ответ сервиса декодируется, заголовки пользователя и request_id передаются
+func TestRentalClient_GetRental(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rental/r-1", r.URL.Path)
+		assert.Equal(t, "user", r.Header.Get("X-User-Name"))
+		assert.Equal(t, "req-1", r.Header.Get(logging.HeaderRequestID))
+
+		json.NewEncoder(w).Encode(models.RentalInfo{RentalUID: "r-1", CarUID: "c-1", Status: "IN_PROGRESS"})
+	}))
+	defer server.Close()
+
+	client := NewRentalClient(newTestTargets(server.URL), NewHTTPClient(), newTestBreaker(), nil)
+	ctx := logging.ContextWithRequestID(context.Background(), "req-1")
+
+	rental, err := client.GetRental(ctx, "user", "r-1")
+
+	require.NoError(t, err)
+	assert.Equal(t, "c-1", rental.CarUID)
+	assert.Equal(t, "IN_PROGRESS", rental.Status)
+}
+
+// Тест: тело запроса отправляется в JSON
+func TestCarClient_GetCars(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var req models.CarsRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, []string{"c-1", "c-2"}, req.UIDs)
+
+		json.NewEncoder(w).Encode([]models.ShortCarResponse{{CarUID: "c-1"}, {CarUID: "c-2"}})
+	}))
+	defer server.Close()
+
+	client := NewCarClient(newTestTargets(server.URL), NewHTTPClient(), newTestBreaker(), nil)
+
+	cars, err := client.GetCars(context.Background(), []string{"c-1", "c-2"})
+
+	require.NoError(t, err)
+	assert.Len(t, cars, 2)
+}
+
+// Тест: статус ответа преобразуется в типизированную ошибку
+func TestClient_StatusErrors(t *testing.T) {
+	tests := []struct {
+		status		int
+		target		error
+		clientError	bool
+	}{
+		{http.StatusNotFound, ErrNotFound, true},
+		{http.StatusBadRequest, ErrRejected, true},
+		{http.StatusInternalServerError, ErrUnavailable, false},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(`{"message":"error"}`))
+		}))
+
+		client := NewPaymentClient(newTestTargets(server.URL), NewHTTPClient(), newTestBreaker(), nil)
+		_, err := client.GetPayment(context.Background(), "p-1")
+		server.Close()
+
+		assert.ErrorIs(t, err, tt.target)
+
+		statusErr, ok := ClientError(err)
+		assert.Equal(t, tt.clientError, ok)
+		if ok {
+			assert.Equal(t, tt.status, statusErr.Status)
+			assert.JSONEq(t, `{"message":"error"}`, string(statusErr.Body))
+		}
+	}
+}
+
+// Тест: недоступный сервис и некорректный ответ различаются
+func TestClient_CallErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+	defer server.Close()
+
+	client := NewCarClient(newTestTargets(server.URL), NewHTTPClient(), newTestBreaker(), nil)
+	_, err := client.GetCar(context.Background(), "c-1")
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+	assert.False(t, errors.Is(err, ErrUnavailable))
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	client = NewCarClient(newTestTargets(closed.URL), NewHTTPClient(), newTestBreaker(), nil)
+	_, err = client.GetCar(context.Background(), "c-1")
+	assert.ErrorIs(t, err, ErrUnavailable)
+
+	var callErr *CallError
+	require.ErrorAs(t, err, &callErr)
+	assert.Equal(t, "car", callErr.Service)
+}
+
+// Тест: при открытом breaker сервис не вызывается
+func TestClient_CircuitOpen(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	breaker := newTestBreaker()
+	breaker.ForceOpen()
+
+	client := NewPaymentClient(newTestTargets(server.URL), NewHTTPClient(), breaker, nil)
+	err := client.SetStatus(context.Background(), "p-1", "CANCELED")
+
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, 0, calls)
+}
+
+// Тест: при заполненном bulkhead вызов отклоняется сразу и не учитывается breaker'ом
+func TestClient_BulkheadFull(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	breaker := newTestBreaker()
+	limiter := bulkhead.New("payment", bulkhead.Config{MaxConcurrent: 1})
+	client := NewPaymentClient(newTestTargets(server.URL), NewHTTPClient(), breaker, limiter)
+
+	done := make(chan error)
+	go func() {
+		done <- client.SetStatus(context.Background(), "p-1", "CANCELED")
+	}()
+	require.Eventually(t, func() bool { return limiter.InFlight() == 1 }, time.Second, time.Millisecond)
+
+	err := client.SetStatus(context.Background(), "p-2", "CANCELED")
+
+	assert.ErrorIs(t, err, ErrBulkheadFull)
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, "bulkhead_full", Reason(err))
+	assert.Empty(t, breaker.Snapshot().Window)
+
+	close(release)
+	assert.NoError(t, <-done)
+}
+
+// Тест: ошибки экземпляра исключают его, остальные вызовы идут на исправный
+func TestClient_EjectsFailingInstance(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	healthy := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthy++
+		json.NewEncoder(w).Encode(models.ShortCarResponse{CarUID: "c-1"})
+	}))
+	defer server.Close()
+
+	targets := balancer.New("car", balancer.Config{Breaker: cb.Config{BufferSize: 2, FailureRate: 0.5, Timeout: time.Minute, MinimumCalls: 2}})
+	targets.SetURLs([]string{failing.URL, server.URL})
+	client := NewCarClient(targets, NewHTTPClient(), nil, nil)
+
+	for i := 0; i < 4; i++ {
+		client.GetCar(context.Background(), "c-1")
+	}
+	healthy = 0
+
+	for i := 0; i < 4; i++ {
+		_, err := client.GetCar(context.Background(), "c-1")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 4, healthy)
+}
+
+// Тест: фильтры каталога передаются Car Service без изменений
+func TestCarClient_ListCars_ForwardsQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, []string{"SUV", "SEDAN"}, r.URL.Query()["type"])
+		assert.Equal(t, "5000", r.URL.Query().Get("maxPrice"))
+		assert.Equal(t, "price", r.URL.Query().Get("sortBy"))
+
+		json.NewEncoder(w).Encode(models.PaginationResponse{Page: 1, PageSize: 10, TotalElements: 1})
+	}))
+	defer server.Close()
+
+	client := NewCarClient(newTestTargets(server.URL), NewHTTPClient(), nil, nil)
+	query := url.Values{"type": {"SUV", "SEDAN"}, "maxPrice": {"5000"}, "sortBy": {"price"}}
+
+	page, err := client.ListCars(context.Background(), query)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, page.TotalElements)
+}
+
+// Тест: бронь отправляется с интервалом дат в теле запроса
+func TestCarClient_Reserve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/cars/c-1/reservations", r.URL.Path)
+
+		var body models.ReservationRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, models.ReservationRequest{DateFrom: "2021-10-08", DateTo: "2021-10-11"}, body)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.ReservationResponse{ReservationUID: "res-1", CarUID: "c-1", DateFrom: body.DateFrom, DateTo: body.DateTo})
+	}))
+	defer server.Close()
+
+	client := NewCarClient(newTestTargets(server.URL), NewHTTPClient(), nil, nil)
+
+	reservation, err := client.Reserve(context.Background(), "c-1", "2021-10-08", "2021-10-11")
+
+	require.NoError(t, err)
+	assert.Equal(t, "res-1", reservation.ReservationUID)
+}
+
+// Тест: отложенное снятие брони аренды сохраняет сервис и путь с параметрами запроса
+func TestCarClient_QueueReleaseRental_KeepsQuery(t *testing.T) {
+	mr := miniredis.RunT(t)
+	queue.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { queue.RedisClient.Close() })
+
+	client := NewCarClient(newTestTargets("http://car:8070/api/v1"), NewHTTPClient(), nil, nil)
+
+	require.NoError(t, client.QueueReleaseRental(context.Background(), "c-1", "r-1"))
+
+	pending, err := queue.ListPending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, http.MethodDelete, pending[0].Request.Method)
+	assert.Equal(t, cfg.DownstreamCar, pending[0].Request.Service)
+	assert.Equal(t, "/cars/c-1/reservations?rentalUid=r-1", pending[0].Request.Path)
+	assert.Equal(t, "/cars/c-1/reservations", pending[0].Request.Entity)
+	assert.Empty(t, pending[0].Request.URL)
+}
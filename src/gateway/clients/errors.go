@@ -0,0 +1,88 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/bulkhead"
+)
+
+var (
+	// Сервис не ответил: breaker открыт, ошибка сети, таймаут или ответ 5xx
+	ErrUnavailable		= errors.New("service unavailable")
+	ErrNotFound			= errors.New("not found")
+	// Сервис отклонил запрос с другим кодом 4xx
+	ErrRejected			= errors.New("request rejected")
+	ErrInvalidResponse	= errors.New("invalid response")
+	ErrCircuitOpen		= errors.New("circuit breaker is open")
+	// Слишком много одновременных вызовов сервиса, запрос можно повторить позже
+	ErrBulkheadFull		= bulkhead.ErrFull
+)
+
+// Сервис ответил неуспешным статусом. Тело ответа сохраняется,
+// чтобы gateway мог вернуть его клиенту без изменений
+type StatusError struct {
+	Service	string
+	Status	int
+	Body	[]byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s service responded with status %d", e.Service, e.Status)
+}
+
+func (e *StatusError) Unwrap() error {
+	switch {
+		case e.Status >= http.StatusInternalServerError:
+			return ErrUnavailable
+		case e.Status == http.StatusNotFound:
+			return ErrNotFound
+		default:
+			return ErrRejected
+	}
+}
+
+// Вызов не дошёл до сервиса или ответ не был получен
+type CallError struct {
+	Service	string
+	Err		error
+}
+
+func (e *CallError) Error() string {
+	return fmt.Sprintf("%s service call failed: %v", e.Service, e.Err)
+}
+
+func (e *CallError) Unwrap() []error {
+	return []error{ErrUnavailable, e.Err}
+}
+
+// Ответ, который можно вернуть клиенту как есть: 4xx от сервиса.
+// Ответы 5xx не пробрасываются, вместо них используется fallback
+func ClientError(err error) (*StatusError, bool) {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.Status < http.StatusInternalServerError {
+		return statusErr, true
+	}
+	return nil, false
+}
+
+
+// Причина, по которой ответ сервиса заменён fallback-значением
+func Reason(err error) string {
+	switch {
+		case errors.Is(err, ErrCircuitOpen):
+			return "circuit_open"
+		case errors.Is(err, ErrBulkheadFull):
+			return "bulkhead_full"
+		case errors.Is(err, context.DeadlineExceeded):
+			return "timeout"
+		case errors.Is(err, ErrInvalidResponse):
+			return "invalid_response"
+		case errors.Is(err, ErrNotFound):
+			return "not_found"
+		default:
+			return "unavailable"
+	}
+}
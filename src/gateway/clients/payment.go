@@ -0,0 +1,76 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/balancer"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/bulkhead"
+	cb "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/circuitBreaker"
+	cfg "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/config"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/models"
+)
+
+type PaymentClient interface {
+	GetPayment(ctx context.Context, uid string) (*models.PaymentInfo, error)
+	GetPayments(ctx context.Context, uids []string) ([]models.PaymentInfo, error)
+	CreatePayment(ctx context.Context, payment models.PaymentCreateRequest) (*models.PaymentCreationResponse, error)
+	SetStatus(ctx context.Context, uid, status string) error
+	// Откладывает SetStatus в очередь повторов
+	QueueSetStatus(ctx context.Context, uid, status string) error
+}
+
+// Данные об оплате, если Payment Service недоступен
+func PaymentFallback(uid string) models.PaymentInfo {
+	return models.PaymentInfo{PaymentUID: uid}
+}
+
+type paymentClient struct {
+	baseClient
+}
+
+func NewPaymentClient(targets *balancer.Balancer, httpClient *http.Client, breaker *cb.CircuitBreaker, bulkhead *bulkhead.Bulkhead) PaymentClient {
+	return &paymentClient{baseClient{service: cfg.DownstreamPayment, targets: targets, http: httpClient, breaker: breaker, bulkhead: bulkhead}}
+}
+
+func (c *paymentClient) GetPayment(ctx context.Context, uid string) (*models.PaymentInfo, error) {
+	var payment models.PaymentInfo
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/payment/" + url.PathEscape(uid)}, &payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (c *paymentClient) GetPayments(ctx context.Context, uids []string) ([]models.PaymentInfo, error) {
+	var payments []models.PaymentInfo
+	r := request{method: http.MethodPost, path: "/payment/query", body: models.PaymentsRequest{UIDs: uids}}
+	if err := c.do(ctx, r, &payments); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (c *paymentClient) CreatePayment(ctx context.Context, payment models.PaymentCreateRequest) (*models.PaymentCreationResponse, error) {
+	var created models.PaymentCreationResponse
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/payment", body: payment}, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *paymentClient) SetStatus(ctx context.Context, uid, status string) error {
+	return c.do(ctx, paymentStatusRequest(uid, status), nil)
+}
+
+func (c *paymentClient) QueueSetStatus(ctx context.Context, uid, status string) error {
+	return c.enqueue(ctx, paymentStatusRequest(uid, status))
+}
+
+func paymentStatusRequest(uid, status string) request {
+	return request{
+		method:	http.MethodPatch,
+		path:	"/payment/" + url.PathEscape(uid),
+		body:	models.PaymentUpsert{Status: status},
+	}
+}
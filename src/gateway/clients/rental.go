@@ -0,0 +1,77 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/balancer"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/bulkhead"
+	cb "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/circuitBreaker"
+	cfg "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/config"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/models"
+)
+
+type RentalClient interface {
+	GetRentals(ctx context.Context, username string) ([]models.RentalInfo, error)
+	GetRental(ctx context.Context, username, uid string) (*models.RentalInfo, error)
+	CreateRental(ctx context.Context, rental models.RentCreation) (*models.RentalInfo, error)
+	SetStatus(ctx context.Context, username, uid, status string) (*models.RentalInfo, error)
+	// Откладывает SetStatus в очередь повторов
+	QueueSetStatus(ctx context.Context, username, uid, status string) error
+}
+
+type rentalClient struct {
+	baseClient
+}
+
+func NewRentalClient(targets *balancer.Balancer, httpClient *http.Client, breaker *cb.CircuitBreaker, bulkhead *bulkhead.Bulkhead) RentalClient {
+	return &rentalClient{baseClient{service: cfg.DownstreamRental, targets: targets, http: httpClient, breaker: breaker, bulkhead: bulkhead}}
+}
+
+func (c *rentalClient) GetRentals(ctx context.Context, username string) ([]models.RentalInfo, error) {
+	var rentals []models.RentalInfo
+	r := request{method: http.MethodGet, path: "/rental", headers: userHeaders(username)}
+	if err := c.do(ctx, r, &rentals); err != nil {
+		return nil, err
+	}
+	return rentals, nil
+}
+
+func (c *rentalClient) GetRental(ctx context.Context, username, uid string) (*models.RentalInfo, error) {
+	var rental models.RentalInfo
+	r := request{method: http.MethodGet, path: "/rental/" + url.PathEscape(uid), headers: userHeaders(username)}
+	if err := c.do(ctx, r, &rental); err != nil {
+		return nil, err
+	}
+	return &rental, nil
+}
+
+func (c *rentalClient) CreateRental(ctx context.Context, rental models.RentCreation) (*models.RentalInfo, error) {
+	var created models.RentalInfo
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/rental", body: rental}, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *rentalClient) SetStatus(ctx context.Context, username, uid, status string) (*models.RentalInfo, error) {
+	var rental models.RentalInfo
+	if err := c.do(ctx, rentalStatusRequest(username, uid, status), &rental); err != nil {
+		return nil, err
+	}
+	return &rental, nil
+}
+
+func (c *rentalClient) QueueSetStatus(ctx context.Context, username, uid, status string) error {
+	return c.enqueue(ctx, rentalStatusRequest(username, uid, status))
+}
+
+func rentalStatusRequest(username, uid, status string) request {
+	return request{
+		method:		http.MethodPatch,
+		path:		"/rental/" + url.PathEscape(uid),
+		headers:	userHeaders(username),
+		body:		models.RentalUpsert{Status: status},
+	}
+}
@@ -1,30 +1,87 @@
 package main
 
 import (
-	"log"
+	"log/slog"
+	"os"
 
-	handler "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/handler"
+	logging "github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+	commonmetrics "github.com/SwanPoi/bmstu_rsoi_lab2/src/common/metrics"
+	tracing "github.com/SwanPoi/bmstu_rsoi_lab2/src/common/tracing"
+	bulkhead "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/bulkhead"
+	cache "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/cache"
+	cb "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/circuitBreaker"
+	clients "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/clients"
 	config "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/config"
+	handler "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/handler"
+	idempotency "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/idempotency"
+	metrics "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/metrics"
+	redis 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/queue"
+	saga 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/saga"
 	server "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/server"
 	services "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/services"
-	redis 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/queue"
 )
 
 func main() {
 	handlerConfig := config.Load()
 
+	logging.Init("gateway", handlerConfig.LogLevel)
+	tracing.Init("gateway", handlerConfig.TraceExporter, handlerConfig.OTLPEndpoint)
+	commonmetrics.Init("gateway")
+
 	redis.InitRedis(handlerConfig.RedisAddr(), handlerConfig.RedisPassword)
-	redis.StartRetryWorker()
+	redis.StartRetryWorker(redis.RetryConfig{
+		Workers:     handlerConfig.RetryWorkers,
+		MaxAttempts: handlerConfig.RetryMaxAttempts,
+		BaseDelay:   handlerConfig.RetryBaseDelay,
+		MaxDelay:    handlerConfig.RetryMaxDelay,
+	})
 	
-	services := services.NewServices()
+	metrics.RegisterQueueDepth(redis.PendingDepth, redis.DeadLetterDepth)
+
+	var breakerStore cb.SharedStore
+	if handlerConfig.BreakerMode == "redis" {
+		breakerStore = cb.NewRedisStore(redis.RedisClient)
+	}
 
-	handler := handler.NewHandler(services, &handlerConfig)
+	breakers := handler.NewBreakerRegistry(&handlerConfig, breakerStore)
+	downstream := clients.New(clients.Config{
+		CarURL:           handlerConfig.CarUrl,
+		RentalURL:        handlerConfig.RentalUrl,
+		PaymentURL:       handlerConfig.PaymentUrl,
+		Balancing:        handlerConfig.LoadBalancing,
+		ResolveInterval:  handlerConfig.ResolveInterval,
+		InstanceBreakers: handler.BreakerConfigs(&handlerConfig),
+		Bulkheads:        bulkheadConfigs(handlerConfig.Bulkheads),
+	}, breakers)
+
+	services := services.NewServices(downstream)
+
+	sagaStore := saga.NewRedisStore(redis.RedisClient)
+	idempotencyStore := idempotency.NewRedisStore(redis.RedisClient)
+
+	carsCacheStore := cache.NewRedisStore(redis.RedisClient)
+
+	handler := handler.NewHandler(services, downstream, breakers, &handlerConfig, sagaStore, idempotencyStore, carsCacheStore)
+	handler.StartSagaRecovery(handlerConfig.SagaRecoveryInterval)
 
 	srv := new(server.CommonServer)
 
 
 	if err := srv.Run(handlerConfig.Addr(), handler.SetupRoutes()); err != nil {
-		log.Fatal("Fail during gateway server start: ", err)
-		return
+		slog.Error("fail during gateway server start", "error", err)
+		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
+
+func bulkheadConfigs(configs map[string]config.BulkheadConfig) map[string]bulkhead.Config {
+	bulkheads := make(map[string]bulkhead.Config, len(configs))
+	for name, c := range configs {
+		bulkheads[name] = bulkhead.Config{
+			MaxConcurrent:	c.MaxConcurrent,
+			MaxWaiting:		c.MaxWaiting,
+			MaxWait:		c.MaxWait,
+		}
+	}
+
+	return bulkheads
+}
@@ -3,17 +3,78 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Названия нижестоящих сервисов, для каждого из которых настраивается circuit breaker
+const (
+	DownstreamCar		= "car"
+	DownstreamRental	= "rental"
+	DownstreamPayment	= "payment"
+)
+
+type BreakerConfig struct {
+	WindowType			string
+	BufferSize			int
+	WindowDuration		time.Duration
+	WindowBuckets		int
+	FailureRate			float64
+	Timeout				time.Duration
+	HalfOpenMaxCalls	int
+	MinimumCalls		int
+	SlowCallDuration	time.Duration
+	SlowCallRate		float64
+}
+
+// Ограничение одновременных вызовов сервиса
+type BulkheadConfig struct {
+	MaxConcurrent	int
+	MaxWaiting		int
+	MaxWait			time.Duration
+}
+
 type HandlerConfig struct {
 	Host			string
 	Port			string
+	// Адреса экземпляров через запятую или dns+http://host:port/path
 	CarUrl			string
 	RentalUrl		string
 	PaymentUrl		string
+	// round-robin или least-in-flight
+	LoadBalancing			string
+	// Как часто перечитывать DNS для адресов dns+http://
+	ResolveInterval			time.Duration
 	RedisHost		string
 	RedisPort		string
 	RedisPassword	string
+	SagaStaleAfter			time.Duration
+	SagaRecoveryInterval	time.Duration
+	IdempotencyTTL			time.Duration
+	RetryWorkers			int
+	RetryMaxAttempts		int
+	RetryBaseDelay			time.Duration
+	RetryMaxDelay			time.Duration
+	AdminToken				string
+	Breakers				map[string]BreakerConfig
+	Bulkheads				map[string]BulkheadConfig
+	// local - состояние в памяти реплики, redis - общее для всех реплик
+	BreakerMode				string
+	BreakerSyncInterval		time.Duration
+	// Брони и завершение аренд через gateway сбрасывают свежесть кэша сразу,
+	// изменения парка в Car Service и истёкшие удержания - через CarsCacheFreshTTL
+	CarsCacheFreshTTL		time.Duration
+	CarsCacheStaleTTL		time.Duration
+	// none, stdout (OTLP JSON построчно) или otlp (OTLP/HTTP коллектор)
+	TraceExporter			string
+	OTLPEndpoint			string
+	// debug, info, warn или error
+	LogLevel				string
+	// Бюджет времени на запрос к /api/v1, включая все вызовы сервисов
+	RequestTimeout			time.Duration
+	// Переопределения по маршрутам: "METHOD /route" -> длительность
+	RouteTimeouts			map[string]time.Duration
 }
 
 func Load() HandlerConfig {
@@ -23,9 +84,67 @@ func Load() HandlerConfig {
 		CarUrl: 		getenv("CAR_URL", "http://cars:8070/api/v1"),
 		RentalUrl: 		getenv("RENTAL_URL", "http://rental:8060/api/v1"),
 		PaymentUrl: 	getenv("PAYMENT_URL", "http://payment:8050/api/v1"),
+		LoadBalancing:			getenv("LB_POLICY", "round-robin"),
+		ResolveInterval:		getenvDuration("LB_RESOLVE_INTERVAL", 30*time.Second),
 		RedisHost: 		getenv("REDIS_HOST", "redis"),
 		RedisPort: 		getenv("REDIS_PORT", "6379"),
 		RedisPassword:	getenv("REDIS_PASSWORD", ""),
+		SagaStaleAfter:			getenvDuration("SAGA_STALE_AFTER", time.Minute),
+		SagaRecoveryInterval:	getenvDuration("SAGA_RECOVERY_INTERVAL", 30*time.Second),
+		IdempotencyTTL:			getenvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		RetryWorkers:			getenvInt("RETRY_WORKERS", 4),
+		RetryMaxAttempts:		getenvInt("RETRY_MAX_ATTEMPTS", 10),
+		RetryBaseDelay:			getenvDuration("RETRY_BASE_DELAY", time.Second),
+		RetryMaxDelay:			getenvDuration("RETRY_MAX_DELAY", 5*time.Minute),
+		AdminToken:				getenv("ADMIN_TOKEN", ""),
+		Breakers: map[string]BreakerConfig{
+			DownstreamCar:		loadBreakerConfig(DownstreamCar),
+			DownstreamRental:	loadBreakerConfig(DownstreamRental),
+			DownstreamPayment:	loadBreakerConfig(DownstreamPayment),
+		},
+		Bulkheads: map[string]BulkheadConfig{
+			DownstreamCar:		loadBulkheadConfig(DownstreamCar),
+			DownstreamRental:	loadBulkheadConfig(DownstreamRental),
+			DownstreamPayment:	loadBulkheadConfig(DownstreamPayment),
+		},
+		BreakerMode:			getenv("CB_MODE", "local"),
+		BreakerSyncInterval:	getenvDuration("CB_SYNC_INTERVAL", time.Second),
+		CarsCacheFreshTTL:		getenvDuration("CARS_CACHE_FRESH_TTL", 10*time.Second),
+		CarsCacheStaleTTL:		getenvDuration("CARS_CACHE_STALE_TTL", 24*time.Hour),
+		TraceExporter:			getenv("TRACE_EXPORTER", "none"),
+		OTLPEndpoint:			getenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318"),
+		LogLevel:				getenv("LOG_LEVEL", "info"),
+		RequestTimeout:			getenvDuration("REQUEST_TIMEOUT", 10*time.Second),
+		RouteTimeouts:			getenvDurations("ROUTE_TIMEOUTS", "POST /api/v1/rental=15s"),
+	}
+}
+
+// Настройки из CB_<NAME>_*, по умолчанию - общие CB_* для всех сервисов
+func loadBreakerConfig(name string) BreakerConfig {
+	prefix := "CB_" + strings.ToUpper(name) + "_"
+
+	return BreakerConfig{
+		WindowType:			getenv(prefix+"WINDOW_TYPE", getenv("CB_WINDOW_TYPE", "count")),
+		WindowDuration:		getenvDuration(prefix+"WINDOW_DURATION", getenvDuration("CB_WINDOW_DURATION", time.Minute)),
+		WindowBuckets:		getenvInt(prefix+"WINDOW_BUCKETS", getenvInt("CB_WINDOW_BUCKETS", 10)),
+		BufferSize:			getenvInt(prefix+"BUFFER_SIZE", getenvInt("CB_BUFFER_SIZE", 5)),
+		FailureRate:		getenvFloat(prefix+"FAILURE_RATE", getenvFloat("CB_FAILURE_RATE", 0.4)),
+		Timeout:			getenvDuration(prefix+"TIMEOUT", getenvDuration("CB_TIMEOUT", 30*time.Second)),
+		HalfOpenMaxCalls:	getenvInt(prefix+"HALF_OPEN_CALLS", getenvInt("CB_HALF_OPEN_CALLS", 3)),
+		MinimumCalls:		getenvInt(prefix+"MINIMUM_CALLS", getenvInt("CB_MINIMUM_CALLS", 5)),
+		SlowCallDuration:	getenvDuration(prefix+"SLOW_CALL_DURATION", getenvDuration("CB_SLOW_CALL_DURATION", 0)),
+		SlowCallRate:		getenvFloat(prefix+"SLOW_CALL_RATE", getenvFloat("CB_SLOW_CALL_RATE", 0.5)),
+	}
+}
+
+// Настройки из BULKHEAD_<NAME>_*, по умолчанию - общие BULKHEAD_* для всех сервисов
+func loadBulkheadConfig(name string) BulkheadConfig {
+	prefix := "BULKHEAD_" + strings.ToUpper(name) + "_"
+
+	return BulkheadConfig{
+		MaxConcurrent:	getenvInt(prefix+"MAX_CONCURRENT", getenvInt("BULKHEAD_MAX_CONCURRENT", 32)),
+		MaxWaiting:		getenvInt(prefix+"MAX_WAITING", getenvInt("BULKHEAD_MAX_WAITING", 64)),
+		MaxWait:		getenvDuration(prefix+"MAX_WAIT", getenvDuration("BULKHEAD_MAX_WAIT", 500*time.Millisecond)),
 	}
 }
 
@@ -42,4 +161,53 @@ func getenv(key, def string) string {
 		return v
 	}
 	return def
-}
\ No newline at end of file
+}
+
+func getenvDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
+// Список вида "GET /api/v1/cars=3s,POST /api/v1/rental=15s",
+// некорректные элементы пропускаются
+func getenvDurations(key, def string) map[string]time.Duration {
+	durations := map[string]time.Duration{}
+
+	for _, item := range strings.Split(getenv(key, def), ",") {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+
+		durations[strings.TrimSpace(name)] = d
+	}
+
+	return durations
+}
+
+func getenvInt(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+func getenvFloat(key string, def float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
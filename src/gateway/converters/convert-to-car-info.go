@@ -0,0 +1,12 @@
+package converters
+
+import "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/models"
+
+func ConvertToCarInfo(car models.ShortCarResponse) models.CarInfo {
+	return models.CarInfo{
+		CarUID: car.CarUID,
+		Brand: car.Brand,
+		Model: car.Model,
+		RegistrationNumber: car.RegistrationNumber,
+	}
+}
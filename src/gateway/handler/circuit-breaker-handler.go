@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	cb "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/circuitBreaker"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/models"
+)
+
+func (h *GatewayHandler) GetCircuitBreakers(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, h.breakers.Snapshots())
+}
+
+func (h *GatewayHandler) GetCircuitBreaker(ctx *gin.Context) {
+	breaker, ok := h.findBreaker(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, breaker.Snapshot())
+}
+
+func (h *GatewayHandler) ForceOpenCircuitBreaker(ctx *gin.Context) {
+	breaker, ok := h.findBreaker(ctx)
+	if !ok {
+		return
+	}
+
+	breaker.ForceOpen()
+	requestLogger(ctx).Warn("circuit breaker forced open", "circuit_breaker", breaker.Name)
+
+	ctx.JSON(http.StatusOK, breaker.Snapshot())
+}
+
+func (h *GatewayHandler) ForceCloseCircuitBreaker(ctx *gin.Context) {
+	breaker, ok := h.findBreaker(ctx)
+	if !ok {
+		return
+	}
+
+	breaker.ForceClosed()
+	requestLogger(ctx).Warn("circuit breaker forced closed", "circuit_breaker", breaker.Name)
+
+	ctx.JSON(http.StatusOK, breaker.Snapshot())
+}
+
+func (h *GatewayHandler) ResetCircuitBreaker(ctx *gin.Context) {
+	breaker, ok := h.findBreaker(ctx)
+	if !ok {
+		return
+	}
+
+	breaker.Reset()
+	requestLogger(ctx).Warn("circuit breaker reset", "circuit_breaker", breaker.Name)
+
+	ctx.JSON(http.StatusOK, breaker.Snapshot())
+}
+
+func (h *GatewayHandler) findBreaker(ctx *gin.Context) (*cb.CircuitBreaker, bool) {
+	name := ctx.Param("name")
+
+	breaker, ok := h.breakers.Get(name)
+	if !ok {
+		ctx.JSON(http.StatusNotFound, models.ErrorResponse{Message: "Circuit breaker " + name + " not found"})
+		return nil, false
+	}
+
+	return breaker, true
+}
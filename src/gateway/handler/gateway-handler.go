@@ -1,766 +1,334 @@
 package handler
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
-	"io"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
-	"time"
-
-	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/converters"
+	"sort"
+	"strings"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/tracing"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/cache"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/clients"
+	cfg "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/config"
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/models"
-	cb "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/circuitBreaker"
-	queue "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/queue"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/saga"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
-func forwardRequest(c *gin.Context, method, targetURL string, headers map[string]string, body []byte) (int, []byte, http.Header, error) {
-	if len(c.Request.URL.RawQuery) > 0 {
-		targetURL = fmt.Sprintf("%s?%s", targetURL, c.Request.URL.RawQuery)
-	}
+const HeaderDegraded = "X-Degraded"
 
-	req, err := http.NewRequest(method, targetURL, bytes.NewReader(body))
-	if err != nil {
-		return 0, nil, nil, err
-	}
+// Идентификатор саги аренды: по нему можно найти и неудавшуюся сагу
+const HeaderSagaUID = "X-Saga-Uid"
 
-	for k, v := range headers {
-		req.Header.Set(k, v)
-	}
+// Через сколько секунд повторить запрос, отклонённый bulkhead
+const bulkheadRetryAfter = "1"
 
-	if c.Request.Header.Get("Content-Type") != "" {
-		req.Header.Set("Content-Type", c.Request.Header.Get("Content-Type"))
-	}
+// Логгер запроса: request_id, пользователь, trace_id и поля из addLogFields
+func requestLogger(c *gin.Context) *slog.Logger {
+	return logging.FromContext(c.Request.Context())
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, nil, nil, err
-	}
-	defer resp.Body.Close()
+// Добавляет поля ко всем следующим строкам лога запроса, в том числе о вызовах сервисов
+func addLogFields(c *gin.Context, args ...any) {
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), args...))
+}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return resp.StatusCode, nil, resp.Header, err
+// Ответ 4xx сервиса возвращается клиенту как есть
+func writeClientError(c *gin.Context, err error) bool {
+	statusErr, ok := clients.ClientError(err)
+	if !ok {
+		return false
 	}
 
-	return resp.StatusCode, respBody, resp.Header, nil
+	requestLogger(c).Warn("downstream rejected request", logging.KeyDownstream, statusErr.Service, "status", statusErr.Status)
+	c.Data(statusErr.Status, "application/json", statusErr.Body)
+	return true
 }
 
-// Функция forward с Circuit Breaker
-func (h *GatewayHandler) forwardRequestWithCB(
-	c *gin.Context,
-	method, targetURL string,
-	headers map[string]string,
-	body []byte,
-	cb *cb.CircuitBreaker,
-	isCritical bool,
-) (int, []byte, http.Header, error) {
-
-	if !cb.AllowRequest() {
-		if isCritical {
-			log.Println(targetURL + " is unavailable (critical)")
-			return 0, nil, nil, fmt.Errorf(targetURL + " is unavailable")
-		}
-		log.Println(targetURL + " is unavailable (not critical)")
-		return http.StatusOK, []byte("{}"), nil, nil
-	}
-
-	if len(c.Request.URL.RawQuery) > 0 {
-		targetURL = fmt.Sprintf("%s?%s", targetURL, c.Request.URL.RawQuery)
+// Ошибка получения аренды: 4xx пробрасывается, иначе сервис считается недоступным
+func writeRentalError(c *gin.Context, err error, unavailableStatus int) {
+	if writeClientError(c, err) {
+		return
 	}
 
-	req, err := http.NewRequest(method, targetURL, bytes.NewReader(body))
-	if err != nil {
-		cb.RecordFailure()
-		return 0, nil, nil, err
+	if errors.Is(err, clients.ErrInvalidResponse) {
+		requestLogger(c).Error("rental parsing error", logging.KeyDownstream, cfg.DownstreamRental, logging.Err(err))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Rental parsing error"})
+		return
 	}
 
-	for k, v := range headers {
-		req.Header.Set(k, v)
-	}
+	requestLogger(c).Error("can't get rental", logging.KeyDownstream, cfg.DownstreamRental, logging.Err(err))
+	writeUnavailable(c, err, unavailableStatus, "Rental Service unavailable")
+}
 
-	if c.Request.Header.Get("Content-Type") != "" {
-		req.Header.Set("Content-Type", c.Request.Header.Get("Content-Type"))
+// Переполнение bulkhead временное: 503 с Retry-After вместо обычного статуса
+func writeUnavailable(c *gin.Context, err error, status int, message string) {
+	if errors.Is(err, clients.ErrBulkheadFull) {
+		c.Header("Retry-After", bulkheadRetryAfter)
+		status = http.StatusServiceUnavailable
 	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		cb.RecordFailure()
-		return 0, nil, nil, err
-	}
-	defer resp.Body.Close()
+	c.JSON(status, models.ErrorResponse{Message: message})
+}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		cb.RecordFailure()
-		return resp.StatusCode, nil, resp.Header, err
+// Заголовок с причинами деградации ответа, например "car=circuit_open, payment=timeout"
+func setDegradedHeader(c *gin.Context, degradation models.Degradation) {
+	if len(degradation) == 0 {
+		return
 	}
 
-	if resp.StatusCode >= 500 {
-		cb.RecordFailure()
-		if isCritical {
-			return resp.StatusCode, respBody, resp.Header, fmt.Errorf("service error: %d", resp.StatusCode)
-		}
-		return http.StatusOK, []byte("{}"), nil, nil
+	parts := make([]string, 0, len(degradation))
+	for part, reason := range degradation {
+		parts = append(parts, part + "=" + reason)
 	}
+	sort.Strings(parts)
 
-	cb.RecordSuccess()
-	return resp.StatusCode, respBody, resp.Header, nil
-}
-
-// Rollbacks
-func (h *GatewayHandler) rollbackCarBooking(ctx *gin.Context, carUID string) {
-	carStatusUpsert := models.CarStatusUpsert{Availability: true}
-	carStatusBytes, _ := json.Marshal(carStatusUpsert)
-	forwardRequest(ctx, "PATCH", h.config.CarUrl + "/cars/" + carUID, nil, carStatusBytes)
-}
-
-func (h *GatewayHandler) rollbackPayment(ctx *gin.Context, paymentUID string) {
-	paymentStatusUpsert := models.PaymentUpsert{Status: "CANCELED"}
-	paymentStatusBytes, _ := json.Marshal(paymentStatusUpsert)
-	forwardRequest(ctx, "PATCH", h.config.PaymentUrl + "/payment/" + paymentUID, nil, paymentStatusBytes)
-}
-
-func (h *GatewayHandler) rollbackRental(ctx *gin.Context, rentalUID string, headers map[string]string) {
-	rentalStatusUpsert := models.RentalUpsert{Status: "CANCELED"}
-	rentalStatusBytes, _ := json.Marshal(rentalStatusUpsert)
-
-	rentalUrl := h.config.RentalUrl + "/rental/" + rentalUID
-	forwardRequest(ctx, "PATCH", rentalUrl, headers, rentalStatusBytes)
+	c.Header(HeaderDegraded, strings.Join(parts, ", "))
 }
 
-
-
 // Main functions
 func (h *GatewayHandler) GetCars(ctx *gin.Context) {
-	status, body, headers, err := h.forwardRequestWithCB(ctx, "GET", h.config.CarUrl + "/cars", nil, nil, h.carCB, true)
+	key := h.carsCache.Key(ctx.Request.URL.Query())
+	version := h.carsCache.Version(ctx.Request.Context())
 
-	if err != nil {
-		log.Println("GET /cars, ", err.Error())
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{ Message: "Car Service unavailable" })
+	cached, fresh := h.carsCache.Lookup(ctx.Request.Context(), key, version)
+	if cached != nil && fresh {
+		ctx.Header(cache.HeaderCache, cache.StatusHit)
+		ctx.Data(cached.Status, cached.ContentType, cached.Body)
 		return
 	}
 
-	ctx.Data(status, headers.Get("Content-Type"), body)
-}
-
-func (h *GatewayHandler) GetUserRentals(ctx *gin.Context) {
-	username := ctx.GetHeader("X-User-Name")
-	if username == "" {
-		log.Println("GET /rentals, Need X-User-Name for rentals")
-		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "X-User-Name header is required"})
+	page, err := h.clients.Car.ListCars(ctx.Request.Context(), ctx.Request.URL.Query())
+	if writeClientError(ctx, err) {
 		return
 	}
 
-	headers := map[string]string{"X-User-Name": username}
-
-	// 1. Получить аренды
-	status, body, _, err := h.forwardRequestWithCB(ctx, "GET", h.config.RentalUrl + "/rental", headers, nil, h.rentalCB, true)
-
-	if err != nil {
-		log.Println("GET /rentals, can't get rentals", err.Error())
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Rental Service unavailable"})
-		return
+	var body []byte
+	if err == nil {
+		body, err = json.Marshal(page)
 	}
 
-	if status != http.StatusOK {
-		ctx.Data(status, "application/json", body)
-		return
-	}
+	if err != nil {
+		requestLogger(ctx).Error("can't get cars", logging.KeyDownstream, cfg.DownstreamCar, logging.Err(err))
+
+		// Каталог меняется редко, поэтому при недоступности сервиса отдаём устаревшую копию
+		if cached != nil {
+			ctx.Header(cache.HeaderCache, cache.StatusStale)
+			ctx.Header("Warning", cache.StaleWarning)
+			ctx.Data(cached.Status, cached.ContentType, cached.Body)
+			return
+		}
 
-	var rentals []models.RentalInfo
-	if err := json.Unmarshal(body, &rentals); err != nil {
-		log.Println("GET /rentals, rental parsing error, ", err.Error())
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Rental parsing error"})
+		writeUnavailable(ctx, err, http.StatusInternalServerError, "Car Service unavailable")
 		return
 	}
 
-	carUIDs := make([]string, len(rentals))
-	paymentUIDs := make([]string, len(rentals))
+	h.carsCache.Save(ctx.Request.Context(), key, version, http.StatusOK, "application/json", body)
 
-	for i, rental := range rentals {
-		carUIDs[i] = rental.CarUID
-		paymentUIDs[i] = rental.PaymentUID
-	}
-	// 2. Получить автомобили
-	carUrl := h.config.CarUrl + "/cars/query"
-	carsRequest := models.CarsRequest{ UIDs: carUIDs }
-	carReqBody, _ := json.Marshal(carsRequest)
+	ctx.Header(cache.HeaderCache, cache.StatusMiss)
+	ctx.Data(http.StatusOK, "application/json", body)
+}
 
-	carStatus, carBody, _, err := h.forwardRequestWithCB(ctx, "POST", carUrl, nil, carReqBody, h.carCB, false)
-	if err != nil {
-		log.Println("GET /rentals, can't get cars, ", err.Error())
-	}
+func (h *GatewayHandler) GetCarCalendar(ctx *gin.Context) {
+	carUid := ctx.Param("carUid")
+	addLogFields(ctx, logging.KeyCarUID, carUid)
 
-	var cars []models.ShortCarResponse
-	if err == nil && carStatus == http.StatusOK && len(carBody) > 2 && string(carBody) != "{}" {
-		if err := json.Unmarshal(carBody, &cars); err != nil {
-			log.Println("GET /rentals, car parsing error, ", err.Error())
-			cars = nil // Будем использовать fallback
-		}
+	calendar, err := h.clients.Car.GetCalendar(ctx.Request.Context(), carUid, ctx.Request.URL.Query())
+	if writeClientError(ctx, err) {
+		return
 	}
-	
-	// 3. Получить оплаты
-	paymentUrl := h.config.PaymentUrl + "/payment/query"
-	paymentsRequest := models.PaymentsRequest{ UIDs: paymentUIDs }
-	paymentsReqBody, _ := json.Marshal(paymentsRequest)
 
-	paymentStatus, paymentBody, _, err := h.forwardRequestWithCB(ctx, "POST", paymentUrl, nil, paymentsReqBody, h.paymentCB, false)
 	if err != nil {
-		log.Println("GET /rentals, can't get payments, ", err.Error())
-	}
-
-	var payments []models.PaymentInfo
-	if err == nil && paymentStatus == http.StatusOK && len(paymentBody) > 2 && string(paymentBody) != "{}" {
-		if err := json.Unmarshal(paymentBody, &payments); err != nil {
-			log.Println("GET /rentals, payments parsing error, ", err.Error())
-			payments = nil // Будем использовать fallback
-		}
-	}
-	
-	// 4. Смэтчить в массив RentalResponse
-	carMap := make(map[string]models.CarInfo)
-	if cars != nil {
-		for _, car := range cars {
-			carMap[car.CarUID] = models.CarInfo{
-				CarUID:            car.CarUID,
-				Brand:             car.Brand,
-				Model:             car.Model,
-				RegistrationNumber: car.RegistrationNumber,
-			}
-		}
+		requestLogger(ctx).Error("can't get car calendar", logging.KeyDownstream, cfg.DownstreamCar, logging.Err(err))
+		writeUnavailable(ctx, err, http.StatusServiceUnavailable, "Car Service unavailable")
+		return
 	}
 
-	for _, rental := range rentals {
-		if _, exists := carMap[rental.CarUID]; !exists {
-			carMap[rental.CarUID] = models.CarInfo{CarUID: rental.CarUID}
-		}
-	}
+	ctx.JSON(http.StatusOK, calendar)
+}
 
-    paymentMap := make(map[string]models.PaymentInfo)
-	if payments != nil {
-		for _, payment := range payments {
-			paymentMap[payment.PaymentUID] = payment
-		}
+func (h *GatewayHandler) GetUserRentals(ctx *gin.Context) {
+	username := ctx.GetHeader("X-User-Name")
+	if username == "" {
+		requestLogger(ctx).Warn("X-User-Name header is required")
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "X-User-Name header is required"})
+		return
 	}
 
-	for _, rental := range rentals {
-		if _, exists := paymentMap[rental.PaymentUID]; !exists {
-			paymentMap[rental.PaymentUID] = models.PaymentInfo{PaymentUID: rental.PaymentUID}
-		}
+	rentals, degradation, err := h.services.GetUserRentals(ctx.Request.Context(), username)
+	if err != nil {
+		writeRentalError(ctx, err, http.StatusInternalServerError)
+		return
 	}
 
-	rentalsResponse := make([]models.RentalResponse, len(rentals))
-
-	for i, rental := range rentals {
-		rentalsResponse[i] = converters.ConvertToRentalResponse(rental, carMap[rental.CarUID], paymentMap[rental.PaymentUID])
-	}
+	setDegradedHeader(ctx, degradation)
 
-	ctx.JSON(http.StatusOK, rentalsResponse)
+	ctx.JSON(http.StatusOK, rentals)
 }
 
 func (h *GatewayHandler) GetRentalById(ctx *gin.Context) {
 	username := ctx.GetHeader("X-User-Name")
 	if username == "" {
-		log.Println("GET /rental/:id, Need X-User-Name for rental")
+		requestLogger(ctx).Warn("X-User-Name header is required")
 		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "X-User-Name header is required"})
 		return
 	}
 
-	headers := map[string]string{"X-User-Name": username}
-
 	rentalUid := ctx.Param("rentalUid")
+	addLogFields(ctx, logging.KeyRentalUID, rentalUid)
 
 	if rentalUid == "" {
-		log.Println("GET /rental/:id, need valid uid")
+		requestLogger(ctx).Warn("rental uid is required")
 		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "RentalUid is required"})
-	}
-
-	rentalUrl := h.config.RentalUrl + "/rental/" + rentalUid
-	
-	status, body, _, err := h.forwardRequestWithCB(ctx, "GET", rentalUrl, headers, nil, h.rentalCB, true)
-
-	if err != nil {
-		log.Println("GET /rental/:id, can't get rental with id = " + rentalUid + ", ", err.Error())
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{ Message: "Rental Service unavailable" })
-		return
-	}
-
-	if status != http.StatusOK {
-		log.Println("GET /rental/:id, rental getting error with uid = " + rentalUid)
-		ctx.Data(status, "application/json", body)
 		return
 	}
 
-	var rental models.RentalInfo
-	if err := json.Unmarshal(body, &rental); err != nil {
-		log.Println("GET /rental/:id, rental parsing error")
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Rental parsing error"})
-		return
-	}
-
-	// Получить авто
-	carUrl := h.config.CarUrl + "/cars/" + rental.CarUID
-
-	carStatus, carBody, _, err := h.forwardRequestWithCB(ctx, "GET", carUrl, nil, nil, h.carCB, false)
-	// if err != nil {
-	// 	log.Println("GET /rental/:id, can't get car with uid = " + rental.CarUID + " ", err.Error())
-	// 	ctx.JSON(http.StatusBadGateway, models.ErrorResponse{Message: err.Error()})
-	// 	return
-	// }
-
-	// TODO: уточнить
-	if carStatus != http.StatusOK && err == nil {
-		log.Println("GET /rental/:id, car getting error with uid = " + rental.CarUID + " ")
-		ctx.Data(carStatus, "application/json", carBody)
-		return
-	}
-
-	var car models.CarInfo
-	if err == nil && carStatus == http.StatusOK && len(carBody) > 2 && string(carBody) != "{}" {
-		var carResponse models.ShortCarResponse
-		if err := json.Unmarshal(carBody, &carResponse); err == nil {
-			car = models.CarInfo{
-				CarUID:            carResponse.CarUID,
-				Brand:             carResponse.Brand,
-				Model:             carResponse.Model,
-				RegistrationNumber: carResponse.RegistrationNumber,
-			}
-		} else {
-			car = models.CarInfo{CarUID: rental.CarUID}
-		}
-	} else {
-		car = models.CarInfo{CarUID: rental.CarUID}
-	}
-	
-	// Получить оплату
-	paymentUrl := h.config.PaymentUrl + "/payment/" + rental.PaymentUID
-
-	paymentStatus, paymentBody, _, err := h.forwardRequestWithCB(ctx, "GET", paymentUrl, nil, nil, h.paymentCB, false)
-	// if err != nil {
-	// 	log.Println("GET /rental/:id, can't get payment with with uid = " + rental.PaymentUID + " ", err.Error())
-	// 	ctx.JSON(http.StatusBadGateway, models.ErrorResponse{Message: err.Error()})
-	// 	return
-	// }
-
-	if paymentStatus != http.StatusOK && err == nil {
-		log.Println("GET /rental/:id, payment getting error with with uid = " + rental.CarUID + " ")
-		ctx.Data(paymentStatus, "application/json", paymentBody)
+	rental, degradation, err := h.services.GetUserRental(ctx.Request.Context(), username, rentalUid)
+	if err != nil {
+		writeRentalError(ctx, err, http.StatusInternalServerError)
 		return
 	}
 
-	var payment models.PaymentInfo
-	if err == nil && paymentStatus == http.StatusOK && len(paymentBody) > 2 && string(paymentBody) != "{}" {
-		if err := json.Unmarshal(paymentBody, &payment); err != nil {
-			payment = models.PaymentInfo{PaymentUID: rental.PaymentUID}
-		}
-	} else {
-		payment = models.PaymentInfo{PaymentUID: rental.PaymentUID}
-	}
-
-	response := converters.ConvertToRentalResponse(rental, car, payment)
-
-	ctx.JSON(http.StatusOK, response)
+	setDegradedHeader(ctx, degradation)
 
+	ctx.JSON(http.StatusOK, rental)
 }
 
 func (h *GatewayHandler) RentCar(ctx *gin.Context) {
 	username := ctx.GetHeader("X-User-Name")
 	if username == "" {
-		log.Println("POST /rental, Need X-User-Name for car rent creation")
+		requestLogger(ctx).Warn("X-User-Name header is required")
 		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "X-User-Name header is required"})
 		return
 	}
 
-	bodyBytes, err := io.ReadAll(ctx.Request.Body)
-	if err != nil {
-		log.Println("POST /rental, invalid body")
-		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Fail during reading of request body for car rent"})
-		return
-	}
-
-	ctx.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-
 	var rentReq models.RentCreationRequest
-	if err := json.Unmarshal(bodyBytes, &rentReq); err != nil {
-		log.Println("POST /rental, body parsing error, ", err.Error())
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Rent request parsing error"})
-		return
-	}
-
-	checkCarUrl := h.config.CarUrl + "/cars/" + rentReq.CarUID
-
-	carStatus, carBody, _, err := forwardRequest(ctx, "GET", checkCarUrl, nil, nil)
-	if err != nil {
-		log.Println("POST /rental, can't get car with uid = " + rentReq.CarUID + " ", err.Error())
-		ctx.JSON(http.StatusServiceUnavailable, models.ErrorResponse{ Message: "Car Service unavailable" })
-		return
-	}
-
-	if carStatus != http.StatusOK {
-		log.Println("POST /rental, car getting error with uid = " + rentReq.CarUID + " ")
-		ctx.Data(carStatus, "application/json", carBody)
-		return
-	}
-
-	var carResponse models.ShortCarResponse
-	if err := json.Unmarshal(carBody, &carResponse); err != nil {
-		log.Println("POST /rental, car parsing error with uid = " + rentReq.CarUID)
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Car parsing error"})
-		return
-	}
-
-	if !carResponse.Availability {
-		log.Println("POST /rental, can't rent unavailable car with uid = " + rentReq.CarUID)
-		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "can't rent unavailable car with uid = " + rentReq.CarUID})
-		return
-	}
-
-	carStatusUpsert := models.CarStatusUpsert{
-		Availability: false,
-	}
-
-	carStatusBytes, err := json.Marshal(carStatusUpsert)
-
-	if err != nil {
-		log.Println("POST /rental, car request marshaling error, ", err.Error())
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&rentReq); err != nil {
+		requestLogger(ctx).Warn("body parsing error", logging.Err(err))
 		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Rent request parsing error"})
 		return
 	}
 
-	carUrl := h.config.CarUrl + "/cars/" + rentReq.CarUID
-
-	status, body, _, err := forwardRequest(ctx, "PATCH", carUrl, nil, carStatusBytes)
-
-	if err != nil {
-		log.Println("POST /rental, can't update car with uid = " + rentReq.CarUID + " ", err.Error())
-		ctx.JSON(http.StatusServiceUnavailable, models.ErrorResponse{ Message: "Car Service unavailable" })
-		return
-	}
-
-	if status != http.StatusOK {
-		log.Println("POST /rental, updating error car with uid = " + rentReq.CarUID)
-		ctx.Data(status, "application/json", body)
-		return
-	}
-
-	payCreateReq := models.PaymentCreateRequest{
-		DateFrom: rentReq.DateFrom,
-		DateTo: rentReq.DateTo,
-	}
-
-	payCreateBytes, err := json.Marshal(payCreateReq)
-
-	if err != nil {
-		log.Println("POST /rental, payment request marshaling error, ", err.Error())
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Payment Creation request marshaling error"})
-		return
-	}
-
-	payStatus, payBody, _, err := forwardRequest(ctx, "POST", h.config.PaymentUrl + "/payment", nil, payCreateBytes)
-
-	if err != nil {
-		log.Println("POST /rental, can't create payment, ", err.Error())
-		h.rollbackCarBooking(ctx, rentReq.CarUID) // TODO: Queue
-		ctx.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Message: "Payment Service unavailable"})
-		return
-	}
-
-	if payStatus != http.StatusOK {
-		log.Println("POST /rental, payment creation error")
-		h.rollbackCarBooking(ctx, rentReq.CarUID) // TODO: Queue
-		ctx.Data(payStatus, "application/json", payBody)
-		return
-	}
-
-	var paymentResponse models.PaymentCreationResponse
-
-	if err := json.Unmarshal(payBody, &paymentResponse); err != nil {
-		log.Println("POST /rental, payment parsing, ", err.Error())
-		h.rollbackCarBooking(ctx, rentReq.CarUID) // TODO: Queue
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Payment response parsing error"})
-		return
-	}
+	addLogFields(ctx, logging.KeyCarUID, rentReq.CarUID)
 
-	rentCreation := models.RentCreation{
-		DateFrom: rentReq.DateFrom,
-		DateTo: rentReq.DateTo,
-		CarUID: rentReq.CarUID,
-		PaymentUID: paymentResponse.PaymentUID,
-		Username: username,
+	// Занятость проверяет сама бронь в Car Service: проигравший гонку запрос получит 409.
+	// uid оплаты и аренды генерируются заранее, чтобы прерванный шаг можно было компенсировать
+	sagaData := map[string]string{
+		"username":    username,
+		"paymentUid":  uuid.New().String(),
+		"rentalUid":   uuid.New().String(),
+		"carUid":      rentReq.CarUID,
+		"dateFrom":    rentReq.DateFrom,
+		"dateTo":      rentReq.DateTo,
+		"traceparent": tracing.TraceParent(ctx.Request.Context()),
+		"requestId":   logging.RequestID(ctx.Request.Context()),
 	}
 
-	rentBytes, err := json.Marshal(rentCreation)
-
-	if err != nil {
-		log.Println("POST /rental, rental marshaling error, ", err.Error())
-		h.rollbackCarBooking(ctx, rentReq.CarUID) // TODO: Queue
-		h.rollbackPayment(ctx, paymentResponse.PaymentUID) // TODO: Queue
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Rental Creation request marshaling error"})
-		return
+	rentSaga, err := h.sagas.Start(ctx.Request.Context(), RentCarSaga, sagaData)
+	if rentSaga != nil {
+		ctx.Header(HeaderSagaUID, rentSaga.ID)
 	}
 
-	rentStatus, rentBody, _, err := forwardRequest(ctx, "POST", h.config.RentalUrl + "/rental", nil, rentBytes)
-
 	if err != nil {
-		log.Println("POST /rental, can't create rental, ", err.Error())
-		h.rollbackCarBooking(ctx, rentReq.CarUID) // TODO: Queue
-		h.rollbackPayment(ctx, paymentResponse.PaymentUID) // TODO: Queue
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: err.Error()})
-		return
-	}
-
-	if rentStatus != http.StatusOK {
-		log.Println("POST /rental, rental creation error")
-		h.rollbackCarBooking(ctx, rentReq.CarUID) // TODO: Queue
-		h.rollbackPayment(ctx, paymentResponse.PaymentUID) // TODO: Queue
-		ctx.Data(rentStatus, "application/json", rentBody)
-		return
-	}
-
-	var rentalCreationResponse models.RentalInfo
+		var stepErr *sagaStepError
+		if !errors.As(err, &stepErr) {
+			requestLogger(ctx).Error("can't start rent saga", logging.Err(err))
+			ctx.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Message: "Rent Service unavailable"})
+			return
+		}
 
-	if err := json.Unmarshal(rentBody, &rentalCreationResponse); err != nil {
-		log.Println("POST /rental, can't parse rental, ", err.Error())
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Rental Creation response parsing error"})
+		requestLogger(ctx).Warn("rent saga failed", "step_status", stepErr.Status, logging.Err(err))
+		if stepErr.Body != nil {
+			ctx.Data(stepErr.Status, "application/json", stepErr.Body)
+		} else {
+			writeUnavailable(ctx, stepErr.Err, stepErr.Status, stepErr.Message)
+		}
 		return
 	}
 
-	rentResponse := converters.ConvertToCreateRentalResponse(rentalCreationResponse, paymentResponse)
-
-	ctx.JSON(http.StatusOK, rentResponse)
+	ctx.JSON(http.StatusOK, rentResponseFromSaga(rentSaga))
 }
 
-func (h *GatewayHandler) FinishCarRent(ctx *gin.Context) {
+func (h *GatewayHandler) GetRentalSaga(ctx *gin.Context) {
 	username := ctx.GetHeader("X-User-Name")
 	if username == "" {
-		log.Println("Need X-User-Name for car rent finishing")
+		requestLogger(ctx).Warn("X-User-Name header is required")
 		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "X-User-Name header is required"})
 		return
 	}
 
-	headers := map[string]string{"X-User-Name": username}
-
 	rentalUid := ctx.Param("rentalUid")
+	addLogFields(ctx, logging.KeyRentalUID, rentalUid)
 
-	if rentalUid == "" {
-		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "RentalUid is required"})
+	// Сага ищется по uid аренды, а если аренда не создана - по uid самой саги
+	rentSaga, err := h.sagas.Store().GetByRef(ctx.Request.Context(), rentalUid)
+	if errors.Is(err, saga.ErrorNotFound) {
+		rentSaga, err = h.sagas.Store().Get(ctx.Request.Context(), rentalUid)
 	}
 
-	checkRentalUrl := h.config.RentalUrl + "/rental/" + rentalUid
-	
-	status, body, _, err := forwardRequest(ctx, "GET", checkRentalUrl, headers, nil)
-
 	if err != nil {
-		log.Println("POST /rental/:id/finish, can't get rental with id = " + rentalUid + ", ", err.Error())
-		ctx.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Message: "Rental Service unavailable"})
-		return
-	}
-
-	if status != http.StatusOK {
-		log.Println("POST /rental/:id/finish, rental getting error with uid = " + rentalUid)
-		ctx.Data(status, "application/json", body)
-		return
-	}
+		if errors.Is(err, saga.ErrorNotFound) {
+			ctx.JSON(http.StatusNotFound, models.ErrorResponse{Message: "Saga for rental with uid = " + rentalUid + " is not found"})
+			return
+		}
 
-	var rental models.RentalInfo
-	if err := json.Unmarshal(body, &rental); err != nil {
-		log.Println("POST /rental/:id/finish, rental parsing error")
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Rental parsing error"})
+		requestLogger(ctx).Error("can't get rent saga", logging.Err(err))
+		ctx.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Message: "Saga journal unavailable"})
 		return
 	}
 
-	if rental.Status != "IN_PROGRESS" {
-		log.Println("POST /rental/:id/finish, rental with id = ", rental.RentalUID, " is not active")
-		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Rental with id = " + rental.RentalUID + " is not active"})
+	if rentSaga.Data["username"] != username {
+		ctx.JSON(http.StatusNotFound, models.ErrorResponse{Message: "Saga for rental with uid = " + rentalUid + " is not found"})
 		return
 	}
 
-	carStatusUpsert := models.CarStatusUpsert{
-		Availability: true,
-	}
-
-	carStatusBytes, err := json.Marshal(carStatusUpsert)
-
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Car upsert marshalling error"})
-		return
-	}
-
-	carUrl := h.config.CarUrl + "/cars/" + rental.CarUID
-
-	carStatus, _, _, err := forwardRequest(ctx, "PATCH", carUrl, nil, carStatusBytes)
-	if err != nil || carStatus != http.StatusOK {
-		queue.EnqueueRetry(queue.RetryRequest{
-			Method:  "PATCH",
-			URL:    carUrl,
-			Headers: nil,
-			Body:    carStatusBytes,
-		})
-	}
-
-	rentalReq := models.RentalUpsert{
-		Status: "FINISHED",
-	}
-
-	rentalBytes, err := json.Marshal(rentalReq)
-
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Payment Creation request marshaling error"})
-		return
-	}
-
-	rentalUrl := h.config.RentalUrl + "/rental/" + rentalUid
-	
-	status, rentBody, _, err := forwardRequest(ctx, "PATCH", rentalUrl, headers, rentalBytes)
-
-	if err != nil || status != http.StatusOK {
-		queue.EnqueueRetry(queue.RetryRequest{
-			Method:  "PATCH",
-			URL:    rentalUrl,
-			Headers: headers,
-			Body:    rentalBytes,
-		})
-	}
-
-	var rentalResponse models.RentalInfo
-
-	if err := json.Unmarshal(rentBody, &rentalResponse); err != nil {
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Rental Deletion response parsing error"})
-		return
-	}
+	ctx.JSON(http.StatusOK, rentSaga)
+}
 
-	ctx.Status(http.StatusNoContent)
+func (h *GatewayHandler) FinishCarRent(ctx *gin.Context) {
+	h.closeRental(ctx, h.services.FinishRental)
 }
 
 func (h *GatewayHandler) RevokeRent(ctx *gin.Context) {
+	h.closeRental(ctx, h.services.CancelRental)
+}
+
+// Общая часть завершения и отмены аренды
+func (h *GatewayHandler) closeRental(ctx *gin.Context, close func(ctx context.Context, username, rentalUid string) error) {
 	username := ctx.GetHeader("X-User-Name")
 	if username == "" {
-		log.Println("Need X-User-Name for rental revoking")
+		requestLogger(ctx).Warn("X-User-Name header is required")
 		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "X-User-Name header is required"})
 		return
 	}
 
-	headers := map[string]string{"X-User-Name": username}
-
 	rentalUid := ctx.Param("rentalUid")
+	addLogFields(ctx, logging.KeyRentalUID, rentalUid)
 
 	if rentalUid == "" {
 		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "RentalUid is required"})
-	}
-
-	checkRentalUrl := h.config.RentalUrl + "/rental/" + rentalUid
-	
-	status, body, _, err := forwardRequest(ctx, "GET", checkRentalUrl, headers, nil)
-
-	if err != nil {
-		log.Println("DELETE /rental/:id, can't get rental with id = " + rentalUid + ", ", err.Error())
-		ctx.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Message: "Rental Service unavailable"})
-		return
-	}
-
-	if status != http.StatusOK {
-		log.Println("DELETE /rental/:id, rental getting error with uid = " + rentalUid)
-		ctx.Data(status, "application/json", body)
-		return
-	}
-
-	var rental models.RentalInfo
-	if err := json.Unmarshal(body, &rental); err != nil {
-		log.Println("DELETE /rental/:id, rental parsing error")
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Rental parsing error"})
-		return
-	}
-
-	if rental.Status != "IN_PROGRESS" {
-		log.Println("DELETE /rental/:id, rental with id = ", rental.RentalUID, " is not active")
-		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Rental with id = " + rental.RentalUID + " is not active"})
 		return
 	}
 
-	carStatusUpsert := models.CarStatusUpsert{
-		Availability: true,
-	}
-
-	carStatusBytes, err := json.Marshal(carStatusUpsert)
-
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Car upsert marshalling error"})
-		return
-	}
-
-	carUrl := h.config.CarUrl + "/cars/" + rental.CarUID
-
-	carStatus, _, _, err := forwardRequest(ctx, "PATCH", carUrl, nil, carStatusBytes)
-
-	if err != nil || carStatus != http.StatusOK {
-		queue.EnqueueRetry(queue.RetryRequest{
-			Method:  "PATCH",
-			URL:     carUrl,
-			Headers: nil,
-			Body:    carStatusBytes,
-		})
-		log.Printf("Car upsert queued for retry: %s", rentalUid)
-	}
-
-	rentalReq := models.RentalUpsert{
-		Status: "CANCELED",
-	}
-
-	rentalBytes, err := json.Marshal(rentalReq)
-
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Rental request marshaling error"})
-		return
-	}
-
-	rentalUrl := h.config.RentalUrl + "/rental/" + rentalUid
-	
-	status, rentBody, _, err := forwardRequest(ctx, "PATCH", rentalUrl, headers, rentalBytes)
-
-	if err != nil || status != http.StatusOK  {
-		queue.EnqueueRetry(queue.RetryRequest{
-			Method:  "PATCH",
-			URL:    rentalUrl,
-			Headers: headers,
-			Body:    rentalBytes,
-		})
-	}
-
-	var rentalResponse models.RentalInfo
-
-	if err := json.Unmarshal(rentBody, &rentalResponse); err != nil {
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Rental Deletion response parsing error"})
-		return
-	}
-
-	paymentStatusUpsert := models.PaymentUpsert{
-		Status: "CANCELED",
-	}
-
-	paymentStatusBytes, err := json.Marshal(paymentStatusUpsert)
+	if err := close(ctx.Request.Context(), username, rentalUid); err != nil {
+		if errors.Is(err, services.ErrRentalNotActive) {
+			ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Rental with id = " + rentalUid + " is not active"})
+			return
+		}
 
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Payment upsert marshalling error"})
+		writeRentalError(ctx, err, http.StatusServiceUnavailable)
 		return
 	}
 
-	paymentUrl := h.config.PaymentUrl + "/payment/" + rentalResponse.PaymentUID
-
-	paymentStatus, _, _, err := forwardRequest(ctx, "PATCH", paymentUrl, nil, paymentStatusBytes)
-	if err != nil || paymentStatus != http.StatusOK {
-		queue.EnqueueRetry(queue.RetryRequest{
-			Method:  	"PATCH",
-			URL:    	paymentUrl,
-			Headers: 	nil,
-			Body:    	paymentStatusBytes,
-		})
-	}
+	// Автомобиль аренды снова доступен
+	h.carsCache.Invalidate(ctx.Request.Context())
 
 	ctx.Status(http.StatusNoContent)
-}
\ No newline at end of file
+}
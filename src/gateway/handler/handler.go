@@ -1,67 +1,153 @@
 package handler
 
 import (
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/auth"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/deadline"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+	commonmetrics "github.com/SwanPoi/bmstu_rsoi_lab2/src/common/metrics"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/tracing"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/cache"
 	cb "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/circuitBreaker"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/clients"
+	cfg "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/config"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/idempotency"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/metrics"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/saga"
 	services "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/services"
-	config "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/config"
 )
 
-type GatewayRoutesConfig struct {
-	CarUrl			string
-	RentalUrl		string
-	PaymentUrl		string
-}
-
 type GatewayHandler struct {
 	services 	*services.Services
-	config   	*GatewayRoutesConfig
-	carCB       *cb.CircuitBreaker
-	rentalCB    *cb.CircuitBreaker
-	paymentCB   *cb.CircuitBreaker
+	clients     clients.Clients
+	breakers    *cb.Registry
+	carsCache   *cache.ResponseCache
+	sagas       *saga.Orchestrator
+	idempotency gin.HandlerFunc
+	adminAuth   gin.HandlerFunc
+	timeouts    gin.HandlerFunc
 }
 
-func NewHandler(services *services.Services, config *config.HandlerConfig) *GatewayHandler {
-	return &GatewayHandler{
+func NewHandler(services *services.Services, clients clients.Clients, breakers *cb.Registry, config *cfg.HandlerConfig, sagaStore saga.Store, idempotencyStore idempotency.Store, cacheStore cache.Store) *GatewayHandler {
+	h := &GatewayHandler{
 		services: services,
-		config: &GatewayRoutesConfig{
-			CarUrl: config.CarUrl,
-			PaymentUrl: config.PaymentUrl,
-			RentalUrl: config.RentalUrl,
-		},
-		carCB:     cb.NewCircuitBreaker(5, 0.4, 30*time.Second),
-		rentalCB:  cb.NewCircuitBreaker(5, 0.4, 30*time.Second),
-		paymentCB: cb.NewCircuitBreaker(5, 0.4, 30*time.Second),
+		clients:   clients,
+		breakers:  breakers,
+		carsCache: cache.NewResponseCache(cacheStore, "cache:cars", config.CarsCacheFreshTTL, config.CarsCacheStaleTTL),
+		sagas:     saga.NewOrchestrator(sagaStore, config.SagaStaleAfter),
+		idempotency: idempotency.Middleware(idempotencyStore, config.IdempotencyTTL, HeaderSagaUID, HeaderDegraded),
+		adminAuth:   auth.Admin(config.AdminToken, "Admin API is disabled"),
+		timeouts:    deadline.Middleware(deadline.Budgets{Default: config.RequestTimeout, Routes: config.RouteTimeouts}),
 	}
+
+	h.sagas.Register(h.rentCarSagaDefinition())
+
+	return h
+}
+
+// Без общего хранилища состояние breaker'ов локально для реплики
+func NewBreakerRegistry(config *cfg.HandlerConfig, store cb.SharedStore) *cb.Registry {
+	registry := cb.NewRegistry()
+	if store != nil {
+		registry = cb.NewSharedRegistry(store, config.BreakerSyncInterval)
+	}
+
+	for name, c := range BreakerConfigs(config) {
+		registry.Register(name, c)
+	}
+
+	registry.OnStateChange(func(change cb.StateChange) {
+		slog.Warn("circuit breaker state changed", "circuit_breaker", change.Name, "from", change.From, "to", change.To, "reason", change.Reason)
+		metrics.SetCircuitBreakerState(change.Name, change.To)
+	})
+
+	for _, snapshot := range registry.Snapshots() {
+		metrics.SetCircuitBreakerState(snapshot.Name, snapshot.State)
+	}
+
+	return registry
+}
+
+// Настройки breaker'ов по сервисам. Те же настройки получают breaker'ы
+// отдельных экземпляров сервиса
+func BreakerConfigs(config *cfg.HandlerConfig) map[string]cb.Config {
+	configs := make(map[string]cb.Config, len(config.Breakers))
+
+	for name, c := range config.Breakers {
+		configs[name] = cb.Config{
+			WindowType:			c.WindowType,
+			BufferSize:			c.BufferSize,
+			WindowDuration:		c.WindowDuration,
+			WindowBuckets:		c.WindowBuckets,
+			FailureRate:		c.FailureRate,
+			Timeout:			c.Timeout,
+			HalfOpenMaxCalls:	c.HalfOpenMaxCalls,
+			MinimumCalls:		c.MinimumCalls,
+			SlowCallDuration:	c.SlowCallDuration,
+			SlowCallRate:		c.SlowCallRate,
+		}
+	}
+
+	return configs
+}
+
+// Восстановление саг, прерванных падением gateway
+func (h *GatewayHandler) StartSagaRecovery(interval time.Duration) {
+	h.sagas.StartRecovery(interval)
 }
 
 func (h *GatewayHandler) SetupRoutes() *gin.Engine {
 	router := gin.New()
+	router.Use(tracing.Middleware(), logging.Middleware(), commonmetrics.Middleware())
 
 	router.GET("/manage/health", func (c *gin.Context) {
 		c.Status(http.StatusOK)
 	})
+	router.GET("/manage/metrics", commonmetrics.Handler())
+
+	retryQueue := router.Group("/manage/queue", h.adminAuth)
+	{
+		retryQueue.GET("/pending", h.GetPendingRetries)
+		retryQueue.DELETE("/pending/:id", h.DeletePendingRetry)
+
+		retryQueue.GET("/dead-letter", h.GetDeadLetters)
+		retryQueue.POST("/dead-letter/replay", h.ReplayAllDeadLetters)
+		retryQueue.POST("/dead-letter/:id/replay", h.ReplayDeadLetter)
+		retryQueue.DELETE("/dead-letter/:id", h.DeleteDeadLetter)
+	}
+
+	breakers := router.Group("/manage/circuit-breakers", h.adminAuth)
+	{
+		breakers.GET("", h.GetCircuitBreakers)
+		breakers.GET("/:name", h.GetCircuitBreaker)
+		breakers.POST("/:name/open", h.ForceOpenCircuitBreaker)
+		breakers.POST("/:name/close", h.ForceCloseCircuitBreaker)
+		breakers.POST("/:name/reset", h.ResetCircuitBreaker)
+	}
 
-	api := router.Group("/api/v1") 
+	api := router.Group("/api/v1", h.timeouts)
 	{
 		cars := api.Group("/cars") 
 		{
 			cars.GET("", h.GetCars)
+			cars.GET("/:carUid/calendar", h.GetCarCalendar)
 		}
 
 		rental := api.Group("/rental")
 		{
 			rental.GET("", h.GetUserRentals)
 			rental.GET(":rentalUid", h.GetRentalById)
+			rental.GET(":rentalUid/saga", h.GetRentalSaga)
 
-			rental.POST("", h.RentCar)
-			rental.POST(":rentalUid/finish", h.FinishCarRent)
+			rental.POST("", h.idempotency, h.RentCar)
+			rental.POST(":rentalUid/finish", h.idempotency, h.FinishCarRent)
 
-			rental.DELETE(":rentalUid", h.RevokeRent)
+			rental.DELETE(":rentalUid", h.idempotency, h.RevokeRent)
 		}
 	}
 
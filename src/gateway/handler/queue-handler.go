@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/models"
+	queue "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/queue"
+)
+
+func (h *GatewayHandler) GetPendingRetries(ctx *gin.Context) {
+	entries, err := queue.ListPending()
+	if err != nil {
+		requestLogger(ctx).Error("can't get pending retries", logging.Err(err))
+		ctx.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Message: "Retry queue unavailable"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entries)
+}
+
+func (h *GatewayHandler) DeletePendingRetry(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	if err := queue.DeletePending(id); err != nil {
+		respondQueueError(ctx, id, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *GatewayHandler) GetDeadLetters(ctx *gin.Context) {
+	entries, err := queue.ListDeadLetters()
+	if err != nil {
+		requestLogger(ctx).Error("can't get dead letters", logging.Err(err))
+		ctx.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Message: "Retry queue unavailable"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entries)
+}
+
+func (h *GatewayHandler) ReplayDeadLetter(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	if err := queue.ReplayDeadLetter(id); err != nil {
+		respondQueueError(ctx, id, err)
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
+
+func (h *GatewayHandler) ReplayAllDeadLetters(ctx *gin.Context) {
+	replayed, err := queue.ReplayAllDeadLetters()
+	if err != nil {
+		requestLogger(ctx).Error("can't replay dead letters", logging.Err(err))
+		ctx.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Message: "Retry queue unavailable"})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"replayed": replayed})
+}
+
+func (h *GatewayHandler) DeleteDeadLetter(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	if err := queue.DeleteDeadLetter(id); err != nil {
+		respondQueueError(ctx, id, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func respondQueueError(ctx *gin.Context, id string, err error) {
+	if errors.Is(err, queue.ErrorNotFound) {
+		ctx.JSON(http.StatusNotFound, models.ErrorResponse{Message: "Retry request with id = " + id + " is not found"})
+		return
+	}
+
+	requestLogger(ctx).Error("retry queue error", "retry_id", id, logging.Err(err))
+	ctx.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Message: "Retry queue unavailable"})
+}
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/tracing"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/clients"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/converters"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/models"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/saga"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/services"
+)
+
+const RentCarSaga = "rent-car"
+
+// Ошибка шага саги: Body - ответ сервиса, если он ответил не 200,
+// Err - ошибка вызова сервиса
+type sagaStepError struct {
+	Status  int
+	Body    []byte
+	Message string
+	Err     error
+}
+
+func (e *sagaStepError) Error() string {
+	if e.Body != nil {
+		return fmt.Sprintf("%s: status %d", e.Message, e.Status)
+	}
+	return e.Message
+}
+
+func (e *sagaStepError) Unwrap() error {
+	return e.Err
+}
+
+func (h *GatewayHandler) rentCarSagaDefinition() saga.Definition {
+	return saga.Definition{
+		Type: RentCarSaga,
+		Steps: []saga.Step{
+			{Name: "reserve-car", Action: h.reserveCarStep, Compensate: h.releaseCarStep},
+			{Name: "create-payment", Action: h.createPaymentStep, Compensate: h.cancelPaymentStep},
+			{Name: "create-rental", Action: h.createRentalStep, Compensate: h.cancelRentalStep},
+			{Name: "confirm-reservation", Action: h.confirmReservationStep},
+		},
+	}
+}
+
+// При восстановлении саги контекст запроса уже потерян, трасса и
+// request_id берутся из данных саги
+func sagaRequestContext(ctx context.Context, s *saga.Saga) context.Context {
+	if logging.RequestID(ctx) == "" {
+		ctx = logging.ContextWithRequestID(ctx, s.Data["requestId"])
+	}
+
+	if tracing.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+
+	if remote, ok := tracing.ParseTraceParent(s.Data["traceparent"]); ok {
+		return tracing.ContextWithRemote(ctx, remote)
+	}
+
+	return ctx
+}
+
+// Ошибка вызова сервиса в шаге саги: 4xx возвращается клиенту как есть,
+// недоступность сервиса - 503
+func stepError(err error, unavailable, message string) error {
+	if statusErr, ok := clients.ClientError(err); ok {
+		return &sagaStepError{Status: statusErr.Status, Body: statusErr.Body, Message: message, Err: err}
+	}
+
+	if errors.Is(err, clients.ErrInvalidResponse) {
+		return &sagaStepError{Status: http.StatusInternalServerError, Message: message, Err: err}
+	}
+
+	return &sagaStepError{Status: http.StatusServiceUnavailable, Message: unavailable, Err: err}
+}
+
+// Steps
+func (h *GatewayHandler) reserveCarStep(ctx context.Context, s *saga.Saga) error {
+	reservation, err := h.clients.Car.Reserve(sagaRequestContext(ctx, s), s.Data["carUid"], s.Data["dateFrom"], s.Data["dateTo"])
+	if err != nil {
+		return stepError(err, "Car Service unavailable", "Car reservation error")
+	}
+
+	s.Data["reservationUid"] = reservation.ReservationUID
+	// В списке автомобилей есть признак доступности
+	h.carsCache.Invalidate(ctx)
+
+	return nil
+}
+
+// Компенсация выполняется сразу, а при неудаче передаётся в очередь повторов
+func (h *GatewayHandler) releaseCarStep(ctx context.Context, s *saga.Saga) error {
+	reservationUid := s.Data["reservationUid"]
+	if reservationUid == "" {
+		return nil
+	}
+
+	ctx = sagaRequestContext(ctx, s)
+
+	if err := h.clients.Car.Release(ctx, s.Data["carUid"], reservationUid); err != nil {
+		return h.clients.Car.QueueRelease(ctx, s.Data["carUid"], reservationUid)
+	}
+	h.carsCache.Invalidate(ctx)
+
+	return nil
+}
+
+func (h *GatewayHandler) createPaymentStep(ctx context.Context, s *saga.Saga) error {
+	payCreateReq := models.PaymentCreateRequest{
+		PaymentUID: s.Data["paymentUid"],
+		DateFrom: s.Data["dateFrom"],
+		DateTo:   s.Data["dateTo"],
+	}
+
+	paymentResponse, err := h.clients.Payment.CreatePayment(sagaRequestContext(ctx, s), payCreateReq)
+	if err != nil {
+		return stepError(err, "Payment Service unavailable", "Payment creation error")
+	}
+
+	s.Data["paymentUid"] = paymentResponse.PaymentUID
+	s.Data["paymentStatus"] = paymentResponse.Status
+	s.Data["paymentPrice"] = strconv.Itoa(paymentResponse.Price)
+
+	return nil
+}
+
+// uid оплаты известен до вызова сервиса, поэтому шаг, прерванный в
+// STARTED, тоже отменяется. 404 - оплата так и не была создана
+func (h *GatewayHandler) cancelPaymentStep(ctx context.Context, s *saga.Saga) error {
+	paymentUid := s.Data["paymentUid"]
+	if paymentUid == "" {
+		return nil
+	}
+
+	ctx = sagaRequestContext(ctx, s)
+
+	if err := h.clients.Payment.SetStatus(ctx, paymentUid, services.PaymentCanceled); err != nil {
+		if errors.Is(err, clients.ErrNotFound) {
+			return nil
+		}
+		return h.clients.Payment.QueueSetStatus(ctx, paymentUid, services.PaymentCanceled)
+	}
+
+	return nil
+}
+
+func (h *GatewayHandler) createRentalStep(ctx context.Context, s *saga.Saga) error {
+	rentCreation := models.RentCreation{
+		RentalUID:  s.Data["rentalUid"],
+		DateFrom:   s.Data["dateFrom"],
+		DateTo:     s.Data["dateTo"],
+		CarUID:     s.Data["carUid"],
+		PaymentUID: s.Data["paymentUid"],
+		Username:   s.Data["username"],
+	}
+
+	rental, err := h.clients.Rental.CreateRental(sagaRequestContext(ctx, s), rentCreation)
+	if err != nil {
+		return stepError(err, "Rental Service unavailable", "Rental creation error")
+	}
+
+	s.Ref = rental.RentalUID
+	s.Data["rentalUid"] = rental.RentalUID
+	s.Data["rentalStatus"] = rental.Status
+	s.Data["rentalDateFrom"] = rental.DateFrom
+	s.Data["rentalDateTo"] = rental.DateTo
+
+	return nil
+}
+
+// Как и для оплаты, uid аренды генерируется до вызова сервиса
+func (h *GatewayHandler) cancelRentalStep(ctx context.Context, s *saga.Saga) error {
+	rentalUid := s.Data["rentalUid"]
+	if rentalUid == "" {
+		return nil
+	}
+
+	ctx = sagaRequestContext(ctx, s)
+	username := s.Data["username"]
+
+	if _, err := h.clients.Rental.SetStatus(ctx, username, rentalUid, services.RentalCanceled); err != nil {
+		if errors.Is(err, clients.ErrNotFound) {
+			return nil
+		}
+		return h.clients.Rental.QueueSetStatus(ctx, username, rentalUid, services.RentalCanceled)
+	}
+
+	return nil
+}
+
+// Удержание становится постоянным только вместе с арендой. Если оно уже
+// истекло, аренда отменяется компенсациями предыдущих шагов
+func (h *GatewayHandler) confirmReservationStep(ctx context.Context, s *saga.Saga) error {
+	err := h.clients.Car.Confirm(sagaRequestContext(ctx, s), s.Data["carUid"], s.Data["reservationUid"], s.Data["rentalUid"])
+	if err != nil {
+		return stepError(err, "Car Service unavailable", "Reservation confirmation error")
+	}
+
+	return nil
+}
+
+func rentResponseFromSaga(s *saga.Saga) models.CreateRentalResponse {
+	price, _ := strconv.Atoi(s.Data["paymentPrice"])
+
+	rental := models.RentalInfo{
+		RentalUID:  s.Data["rentalUid"],
+		PaymentUID: s.Data["paymentUid"],
+		CarUID:     s.Data["carUid"],
+		DateFrom:   s.Data["rentalDateFrom"],
+		DateTo:     s.Data["rentalDateTo"],
+		Status:     s.Data["rentalStatus"],
+	}
+
+	payment := models.PaymentCreationResponse{
+		PaymentUID: s.Data["paymentUid"],
+		Status:     s.Data["paymentStatus"],
+		Price:      price,
+	}
+
+	return converters.ConvertToCreateRentalResponse(rental, payment)
+}
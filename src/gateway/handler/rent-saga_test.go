@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/balancer"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/cache"
+	cb "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/circuitBreaker"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/clients"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/models"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/saga"
+)
+
+// Car, Rental и Payment Service в одном сервере. Атомарность брони
+// проверяется тестами репозитория Car Service на Postgres
+type fakeDownstream struct {
+	mu           sync.Mutex
+	reservations map[string]models.ReservationRequest
+	confirmed    map[string]string
+	payments     atomic.Int32
+	rentals      atomic.Int32
+	canceled     atomic.Int32
+	// Удержание истекает раньше, чем его подтверждают
+	holdExpired  bool
+	// Оплата сохраняется, но ответ не доходит до gateway
+	paymentLost  bool
+}
+
+func newFakeDownstream() *fakeDownstream {
+	return &fakeDownstream{
+		reservations: make(map[string]models.ReservationRequest),
+		confirmed:    make(map[string]string),
+	}
+}
+
+func (f *fakeDownstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/reservations"):
+		var req models.ReservationRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		carUid := strings.Split(r.URL.Path, "/")[2]
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if _, taken := f.reservations[carUid]; taken {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "car is already reserved for these dates"})
+			return
+		}
+		f.reservations[carUid] = req
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.ReservationResponse{ReservationUID: "res-1", CarUID: carUid, DateFrom: req.DateFrom, DateTo: req.DateTo})
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/confirm"):
+		var req models.ReservationConfirmRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		carUid := strings.Split(r.URL.Path, "/")[2]
+
+		if f.holdExpired {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "reservation hold is expired"})
+			return
+		}
+
+		f.mu.Lock()
+		f.confirmed[carUid] = req.RentalUID
+		f.mu.Unlock()
+	case r.Method == http.MethodDelete && strings.Contains(r.URL.Path, "/reservations/"):
+		carUid := strings.Split(r.URL.Path, "/")[2]
+
+		f.mu.Lock()
+		delete(f.reservations, carUid)
+		f.mu.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPatch:
+		f.canceled.Add(1)
+		json.NewEncoder(w).Encode(models.RentalInfo{Status: "CANCELED"})
+	case r.Method == http.MethodPost && r.URL.Path == "/payment":
+		f.payments.Add(1)
+		if f.paymentLost {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(models.PaymentCreationResponse{PaymentUID: "p-1", Status: "PAID", Price: 10500})
+	case r.Method == http.MethodPost && r.URL.Path == "/rental":
+		var req models.RentCreation
+		json.NewDecoder(r.Body).Decode(&req)
+		f.rentals.Add(1)
+		json.NewEncoder(w).Encode(models.RentalInfo{RentalUID: "r-1", CarUID: req.CarUID, PaymentUID: req.PaymentUID, DateFrom: req.DateFrom, DateTo: req.DateTo, Status: "IN_PROGRESS"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestRentHandler(t *testing.T, downstream http.Handler) *GatewayHandler {
+	server := httptest.NewServer(downstream)
+	t.Cleanup(server.Close)
+
+	targets := func(name string) *balancer.Balancer {
+		b := balancer.New(name, balancer.Config{Breaker: cb.Config{BufferSize: 10, FailureRate: 0.5, Timeout: time.Minute, MinimumCalls: 10}})
+		b.SetURLs([]string{server.URL})
+		return b
+	}
+
+	h := &GatewayHandler{
+		clients: clients.Clients{
+			Car:     clients.NewCarClient(targets("car"), clients.NewHTTPClient(), nil, nil),
+			Rental:  clients.NewRentalClient(targets("rental"), clients.NewHTTPClient(), nil, nil),
+			Payment: clients.NewPaymentClient(targets("payment"), clients.NewHTTPClient(), nil, nil),
+		},
+		sagas:     saga.NewOrchestrator(saga.NewMemoryStore(), time.Minute),
+		carsCache: cache.NewResponseCache(cache.NewMemoryStore(), "cache:cars", time.Minute, time.Hour),
+	}
+	h.sagas.Register(h.rentCarSagaDefinition())
+
+	return h
+}
+
+func newTestRentRouter(t *testing.T, downstream http.Handler) *gin.Engine {
+	h := newTestRentHandler(t, downstream)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/rental", h.RentCar)
+
+	return router
+}
+
+// Тест: отказ Car Service в брони (409) возвращается клиенту как 409,
+// оплата и аренда не создаются
+func TestRentCar_CarReserved_Conflict(t *testing.T) {
+	downstream := newFakeDownstream()
+	downstream.reservations["c-1"] = models.ReservationRequest{DateFrom: "2021-10-09", DateTo: "2021-10-12"}
+	router := newTestRentRouter(t, downstream)
+
+	body := `{"carUid": "c-1", "dateFrom": "2021-10-08", "dateTo": "2021-10-11"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/rental", strings.NewReader(body))
+	req.Header.Set("X-User-Name", "user")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "car is already reserved for these dates")
+	assert.Zero(t, downstream.payments.Load())
+	assert.Zero(t, downstream.rentals.Load())
+	assert.Empty(t, downstream.confirmed)
+}
+
+// Тест: бронь автомобиля сбрасывает свежесть кэша списка автомобилей
+func TestRentCar_InvalidatesCarsCache(t *testing.T) {
+	h := newTestRentHandler(t, newFakeDownstream())
+	ctx := context.Background()
+	h.carsCache.Save(ctx, "cars", h.carsCache.Version(ctx), http.StatusOK, "application/json", []byte(`{"items":[]}`))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/rental", h.RentCar)
+
+	body := `{"carUid": "c-1", "dateFrom": "2021-10-08", "dateTo": "2021-10-11"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/rental", strings.NewReader(body))
+	req.Header.Set("X-User-Name", "user")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	cached, fresh := h.carsCache.Lookup(ctx, "cars", h.carsCache.Version(ctx))
+	assert.NotNil(t, cached)
+	assert.False(t, fresh)
+}
+
+// Тест: если удержание истекло до подтверждения, аренда и оплата отменяются, бронь снимается
+func TestRentCar_HoldExpired_Compensates(t *testing.T) {
+	downstream := newFakeDownstream()
+	downstream.holdExpired = true
+	router := newTestRentRouter(t, downstream)
+
+	body := `{"carUid": "c-1", "dateFrom": "2021-10-08", "dateTo": "2021-10-11"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/rental", strings.NewReader(body))
+	req.Header.Set("X-User-Name", "user")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	assert.Empty(t, downstream.reservations)
+	assert.Empty(t, downstream.confirmed)
+	// Отменены аренда и оплата
+	assert.EqualValues(t, 2, downstream.canceled.Load())
+}
+
+// Тест: оплата, сохранённая сервисом при ошибочном ответе, отменяется
+// компенсацией упавшего шага, бронь снимается
+func TestRentCar_PaymentSavedButCallFailed_Compensates(t *testing.T) {
+	downstream := newFakeDownstream()
+	downstream.paymentLost = true
+	router := newTestRentRouter(t, downstream)
+
+	body := `{"carUid": "c-1", "dateFrom": "2021-10-08", "dateTo": "2021-10-11"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/rental", strings.NewReader(body))
+	req.Header.Set("X-User-Name", "user")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.EqualValues(t, 1, downstream.payments.Load())
+	assert.Zero(t, downstream.rentals.Load())
+	// Отменена оплата, аренда не создавалась
+	assert.EqualValues(t, 1, downstream.canceled.Load())
+	assert.Empty(t, downstream.reservations)
+}
+
+// Тест: оплата, созданная шагом, прерванным в STARTED, отменяется при восстановлении
+func TestRentSaga_Recover_CancelsStartedPayment(t *testing.T) {
+	downstream := newFakeDownstream()
+	h := newTestRentHandler(t, downstream)
+
+	crashed := &saga.Saga{
+		ID:     "crashed",
+		Type:   RentCarSaga,
+		Status: saga.StatusRunning,
+		Data: map[string]string{
+			"username":       "user",
+			"carUid":         "c-1",
+			"reservationUid": "res-1",
+			"paymentUid":     "8d3a2f64-1c1e-4b7a-9d55-3f1e2b6c9a10",
+			"rentalUid":      "0b6e1a52-7c2d-4f38-8e91-5a4c3d2b1e0f",
+		},
+		Steps: []saga.StepRecord{
+			{Name: "reserve-car", Status: saga.StepDone},
+			{Name: "create-payment", Status: saga.StepStarted},
+			{Name: "create-rental", Status: saga.StepPending},
+			{Name: "confirm-reservation", Status: saga.StepPending},
+		},
+		UpdatedAt: time.Now().Add(-time.Hour),
+	}
+	downstream.reservations["c-1"] = models.ReservationRequest{}
+	require.NoError(t, h.sagas.Store().Save(context.Background(), crashed))
+
+	require.NoError(t, h.sagas.Recover(context.Background()))
+
+	// Отменена только оплата: аренда не создавалась
+	assert.EqualValues(t, 1, downstream.canceled.Load())
+	assert.Empty(t, downstream.reservations)
+
+	recovered, err := h.sagas.Store().Get(context.Background(), "crashed")
+	require.NoError(t, err)
+	assert.Equal(t, saga.StatusCompensated, recovered.Status)
+}
@@ -0,0 +1,62 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	record    Record
+	expiresAt time.Time
+}
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (m *MemoryStore) Reserve(ctx context.Context, key string, record Record, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.entries[key]; ok && time.Now().Before(entry.expiresAt) {
+		return false, nil
+	}
+
+	m.entries[key] = memoryEntry{record: record, expiresAt: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, ErrorNotFound
+	}
+
+	record := entry.record
+	return &record, nil
+}
+
+func (m *MemoryStore) Save(ctx context.Context, key string, record Record, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[key] = memoryEntry{record: record, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
@@ -0,0 +1,194 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/models"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+
+	// Сколько держится блокировка ключа, если у запроса нет дедлайна
+	defaultInProgressTTL = time.Minute
+	// Время на сохранение ответа после выполнения запроса
+	saveTimeout = 2 * time.Second
+	// Ключ может удаляться между Reserve и Get после ответов 5xx
+	reserveAttempts = 3
+)
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+func storageKey(username, key string) string {
+	return "idempotency:" + username + ":" + key
+}
+
+func requestHash(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte(path))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Блокировка ключа держится, пока первый запрос может выполняться:
+// до дедлайна маршрута и ещё время на сохранение ответа
+func inProgressTTL(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return defaultInProgressTTL
+	}
+
+	return time.Until(deadline) + saveTimeout
+}
+
+// Первый ответ на запрос с Idempotency-Key сохраняется для пользователя и
+// повторяется для дубликатов вместе с заголовками headers. Ключ с другим
+// телом или путём даёт 409. Ответы 5xx не сохраняются, чтобы клиент мог
+// повторить запрос. Без хранилища дубликаты не распознать, поэтому при
+// его недоступности запрос не выполняется
+func Middleware(store Store, ttl time.Duration, headers ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(HeaderKey)
+		username := ctx.GetHeader("X-User-Name")
+
+		if key == "" || username == "" {
+			ctx.Next()
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrorResponse{Message: "Fail during reading of request body"})
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+		storeKey := storageKey(username, key)
+		hash := requestHash(ctx.Request.Method, ctx.Request.URL.Path, body)
+
+		reserved, existing, err := reserve(ctx.Request.Context(), store, storeKey, hash)
+		if err != nil {
+			logging.FromContext(ctx.Request.Context()).Warn("idempotency store unavailable", logging.Err(err))
+			ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, models.ErrorResponse{Message: "Idempotency store unavailable"})
+			return
+		}
+
+		if !reserved {
+			replay(ctx, existing, hash)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: ctx.Writer}
+		ctx.Writer = recorder
+
+		ctx.Next()
+
+		// Клиент мог отключиться, не дождавшись ответа: контекст запроса уже
+		// отменён, но ответ всё равно нужно сохранить для повтора
+		saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx.Request.Context()), saveTimeout)
+		defer cancel()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			store.Delete(saveCtx, storeKey)
+			return
+		}
+
+		record := Record{
+			State:       StateDone,
+			RequestHash: hash,
+			Status:      status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Headers:     savedHeaders(recorder.Header(), headers),
+			Body:        recorder.body.Bytes(),
+		}
+
+		if err := store.Save(saveCtx, storeKey, record, ttl); err != nil {
+			logging.FromContext(ctx.Request.Context()).Warn("idempotency response saving error", logging.Err(err))
+		}
+	}
+}
+
+// Занимает ключ или возвращает запись, которая его уже заняла. Если запись
+// удалили после ответа 5xx до того, как её прочитали, ключ занимается заново
+func reserve(ctx context.Context, store Store, storeKey, hash string) (bool, *Record, error) {
+	var err error
+	for range reserveAttempts {
+		var reserved bool
+		reserved, err = store.Reserve(ctx, storeKey, Record{State: StateInProgress, RequestHash: hash}, inProgressTTL(ctx))
+		if err != nil || reserved {
+			return reserved, nil, err
+		}
+
+		var record *Record
+		record, err = store.Get(ctx, storeKey)
+		if !errors.Is(err, ErrorNotFound) {
+			return false, record, err
+		}
+	}
+
+	return false, nil, err
+}
+
+func savedHeaders(header http.Header, names []string) map[string]string {
+	var saved map[string]string
+	for _, name := range names {
+		if value := header.Get(name); value != "" {
+			if saved == nil {
+				saved = make(map[string]string, len(names))
+			}
+			saved[name] = value
+		}
+	}
+	return saved
+}
+
+func replay(ctx *gin.Context, record *Record, hash string) {
+	if record.RequestHash != hash {
+		ctx.AbortWithStatusJSON(http.StatusConflict, models.ErrorResponse{Message: "Idempotency-Key is already used for another request"})
+		return
+	}
+
+	if record.State != StateDone {
+		ctx.AbortWithStatusJSON(http.StatusConflict, models.ErrorResponse{Message: "Request with this Idempotency-Key is in progress"})
+		return
+	}
+
+	for name, value := range record.Headers {
+		ctx.Header(name, value)
+	}
+	ctx.Header(HeaderReplayed, "true")
+
+	if len(record.Body) == 0 {
+		ctx.AbortWithStatus(record.Status)
+		return
+	}
+
+	ctx.Data(record.Status, record.ContentType, record.Body)
+	ctx.Abort()
+}
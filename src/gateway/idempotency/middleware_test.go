@@ -0,0 +1,232 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRouter(store Store, calls *int, status int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	router.POST("/rental", Middleware(store, time.Hour), func(ctx *gin.Context) {
+		*calls++
+		ctx.JSON(status, gin.H{"call": *calls})
+	})
+
+	return router
+}
+
+func doRequest(router *gin.Engine, user, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/rental", strings.NewReader(body))
+	req.Header.Set("X-User-Name", user)
+	if key != "" {
+		req.Header.Set(HeaderKey, key)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Тест: повторный запрос с тем же ключом получает сохранённый ответ
+func TestMiddleware_ReplaysDuplicate(t *testing.T) {
+	calls := 0
+	router := setupRouter(NewMemoryStore(), &calls, http.StatusOK)
+
+	first := doRequest(router, "user", "key-1", `{"carUid":"1"}`)
+	second := doRequest(router, "user", "key-1", `{"carUid":"1"}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "true", second.Header().Get(HeaderReplayed))
+}
+
+// Тест: ключ с другим телом запроса даёт 409
+func TestMiddleware_ConflictOnDifferentBody(t *testing.T) {
+	calls := 0
+	router := setupRouter(NewMemoryStore(), &calls, http.StatusOK)
+
+	doRequest(router, "user", "key-1", `{"carUid":"1"}`)
+	second := doRequest(router, "user", "key-1", `{"carUid":"2"}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusConflict, second.Code)
+}
+
+// Тест: ключи разных пользователей не пересекаются
+func TestMiddleware_KeysScopedByUser(t *testing.T) {
+	calls := 0
+	router := setupRouter(NewMemoryStore(), &calls, http.StatusOK)
+
+	doRequest(router, "alice", "key-1", `{}`)
+	doRequest(router, "bob", "key-1", `{}`)
+
+	assert.Equal(t, 2, calls)
+}
+
+// Тест: ответ 5xx не сохраняется и запрос можно повторить
+func TestMiddleware_ServerErrorNotStored(t *testing.T) {
+	calls := 0
+	router := setupRouter(NewMemoryStore(), &calls, http.StatusServiceUnavailable)
+
+	doRequest(router, "user", "key-1", `{}`)
+	doRequest(router, "user", "key-1", `{}`)
+
+	assert.Equal(t, 2, calls)
+}
+
+// Тест: без заголовка запросы выполняются каждый раз
+func TestMiddleware_NoKey(t *testing.T) {
+	calls := 0
+	router := setupRouter(NewMemoryStore(), &calls, http.StatusOK)
+
+	doRequest(router, "user", "", `{}`)
+	doRequest(router, "user", "", `{}`)
+
+	assert.Equal(t, 2, calls)
+}
+
+// Тест: клиент отключился до ответа, но ответ сохранён и повторяется дубликату
+func TestMiddleware_ClientDisconnected_ResponseStored(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	store := NewRedisStore(client)
+
+	calls := 0
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/rental", Middleware(store, time.Hour), func(ctx *gin.Context) {
+		calls++
+		if cancel, ok := ctx.Request.Context().Value(cancelKey{}).(context.CancelFunc); ok {
+			cancel()
+		}
+		ctx.JSON(http.StatusOK, gin.H{"call": calls})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = context.WithValue(ctx, cancelKey{}, cancel)
+
+	req := httptest.NewRequest(http.MethodPost, "/rental", strings.NewReader(`{}`)).WithContext(ctx)
+	req.Header.Set("X-User-Name", "user")
+	req.Header.Set(HeaderKey, "key-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	second := doRequest(router, "user", "key-1", `{}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "true", second.Header().Get(HeaderReplayed))
+}
+
+type cancelKey struct{}
+
+// Ключ занят, но запись не читается
+type unreadableStore struct {
+	*MemoryStore
+}
+
+func (unreadableStore) Get(ctx context.Context, key string) (*Record, error) {
+	return nil, errors.New("store unavailable")
+}
+
+// Тест: если запись нельзя прочитать, дубликат не выполняется повторно
+func TestMiddleware_ReplayStoreUnavailable(t *testing.T) {
+	calls := 0
+	router := setupRouter(unreadableStore{NewMemoryStore()}, &calls, http.StatusOK)
+
+	doRequest(router, "user", "key-1", `{}`)
+	second := doRequest(router, "user", "key-1", `{}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusServiceUnavailable, second.Code)
+}
+
+// Хранилище недоступно
+type unavailableStore struct {
+	*MemoryStore
+}
+
+func (unavailableStore) Reserve(ctx context.Context, key string, record Record, ttl time.Duration) (bool, error) {
+	return false, errors.New("store unavailable")
+}
+
+// Тест: без хранилища запрос не выполняется, иначе дубликат создал бы вторую аренду
+func TestMiddleware_ReserveStoreUnavailable(t *testing.T) {
+	calls := 0
+	router := setupRouter(unavailableStore{NewMemoryStore()}, &calls, http.StatusOK)
+
+	w := doRequest(router, "user", "key-1", `{}`)
+
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+// Ключ занят первым запросом, но его запись удаляется до чтения (ответ 5xx)
+type deletedRecordStore struct {
+	*MemoryStore
+	reserves int
+}
+
+func (s *deletedRecordStore) Reserve(ctx context.Context, key string, record Record, ttl time.Duration) (bool, error) {
+	s.reserves++
+	if s.reserves == 1 {
+		return false, nil
+	}
+	return s.MemoryStore.Reserve(ctx, key, record, ttl)
+}
+
+// Тест: если запись удалили между Reserve и Get, ключ занимается заново
+func TestMiddleware_RecordDeleted_ReservesAgain(t *testing.T) {
+	calls := 0
+	store := &deletedRecordStore{MemoryStore: NewMemoryStore()}
+	router := setupRouter(store, &calls, http.StatusOK)
+
+	w := doRequest(router, "user", "key-1", `{}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 2, store.reserves)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(HeaderReplayed))
+}
+
+// Тест: дубликат получает сохранённые заголовки ответа
+func TestMiddleware_ReplaysHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/rental", Middleware(NewMemoryStore(), time.Hour, "X-Saga-Uid", "X-Degraded"), func(ctx *gin.Context) {
+		ctx.Header("X-Saga-Uid", "saga-1")
+		ctx.Header("X-Request-ID", "req-1")
+		ctx.JSON(http.StatusOK, gin.H{})
+	})
+
+	doRequest(router, "user", "key-1", `{}`)
+	second := doRequest(router, "user", "key-1", `{}`)
+
+	assert.Equal(t, "true", second.Header().Get(HeaderReplayed))
+	assert.Equal(t, "saga-1", second.Header().Get("X-Saga-Uid"))
+	assert.Empty(t, second.Header().Get("X-Degraded"))
+	assert.Empty(t, second.Header().Get("X-Request-ID"))
+}
+
+// Тест: блокировка ключа держится до дедлайна запроса и времени на сохранение ответа
+func TestInProgressTTL_FromDeadline(t *testing.T) {
+	assert.Equal(t, defaultInProgressTTL, inProgressTTL(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ttl := inProgressTTL(ctx)
+	assert.LessOrEqual(t, ttl, 10*time.Second+saveTimeout)
+	assert.Greater(t, ttl, 9*time.Second+saveTimeout)
+}
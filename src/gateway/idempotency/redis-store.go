@@ -0,0 +1,57 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (r *RedisStore) Reserve(ctx context.Context, key string, record Record, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+
+	return r.client.SetNX(ctx, key, data, ttl).Result()
+}
+
+func (r *RedisStore) Get(ctx context.Context, key string) (*Record, error) {
+	data, err := r.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrorNotFound
+		}
+		return nil, err
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (r *RedisStore) Save(ctx context.Context, key string, record Record, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(ctx, key, data, ttl).Err()
+}
+
+func (r *RedisStore) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
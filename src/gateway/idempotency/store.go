@@ -0,0 +1,32 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	StateInProgress = "IN_PROGRESS"
+	StateDone       = "DONE"
+)
+
+var ErrorNotFound = errors.New("idempotency record not found")
+
+type Record struct {
+	State       string `json:"state"`
+	RequestHash string `json:"requestHash"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	// Заголовки ответа, которые повторяются вместе с телом
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    []byte            `json:"body,omitempty"`
+}
+
+type Store interface {
+	// Создаёт запись, только если ключ ещё не занят
+	Reserve(ctx context.Context, key string, record Record, ttl time.Duration) (bool, error)
+	Get(ctx context.Context, key string) (*Record, error)
+	Save(ctx context.Context, key string, record Record, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
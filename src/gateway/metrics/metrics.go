@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	commonmetrics "github.com/SwanPoi/bmstu_rsoi_lab2/src/common/metrics"
+)
+
+const namespace = "gateway"
+
+var (
+	downstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:	namespace,
+		Name:		"downstream_request_duration_seconds",
+		Help:		"Duration of requests to downstream services.",
+		Buckets:	prometheus.DefBuckets,
+	}, []string{"target", "method", "status"})
+
+	downstreamErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:	namespace,
+		Name:		"downstream_request_errors_total",
+		Help:		"Failed requests to downstream services: transport errors and 5xx responses.",
+	}, []string{"target", "method", "reason"})
+
+	circuitBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:	namespace,
+		Name:		"circuit_breaker_state",
+		Help:		"Circuit breaker state: 0 - closed, 1 - half-open, 2 - open.",
+	}, []string{"name"})
+)
+
+// Метрики HTTP и /manage/metrics - в common/metrics, здесь только метрики gateway
+func init() {
+	commonmetrics.Registry.MustRegister(
+		downstreamDuration,
+		downstreamErrors,
+		circuitBreakerState,
+	)
+}
+
+// Результат вызова нижестоящего сервиса. status = 0, если ответа не было
+func ObserveDownstream(target, method string, status int, err error, duration time.Duration) {
+	downstreamDuration.WithLabelValues(target, method, strconv.Itoa(status)).Observe(duration.Seconds())
+
+	switch {
+		case err != nil && status == 0:
+			downstreamErrors.WithLabelValues(target, method, "transport").Inc()
+		case status >= 500:
+			downstreamErrors.WithLabelValues(target, method, "server_error").Inc()
+		case err != nil:
+			downstreamErrors.WithLabelValues(target, method, "read").Inc()
+	}
+}
+
+// Вызов не выполнялся, так как circuit breaker открыт
+func ObserveRejected(target, method string) {
+	downstreamErrors.WithLabelValues(target, method, "circuit_open").Inc()
+}
+
+func SetCircuitBreakerState(name, state string) {
+	value := 0.0
+	switch state {
+		case "half-open":
+			value = 1
+		case "open":
+			value = 2
+	}
+
+	circuitBreakerState.WithLabelValues(name).Set(value)
+}
+
+// Вызов не выполнялся, так как bulkhead сервиса заполнен
+func ObserveBulkheadRejected(target, method string) {
+	downstreamErrors.WithLabelValues(target, method, "bulkhead_full").Inc()
+}
+
+// Занятость bulkhead считывается в момент сбора метрик
+func RegisterBulkhead(name string, inFlight, waiting func() int) {
+	labels := prometheus.Labels{"name": name}
+
+	commonmetrics.Registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:		namespace,
+			Name:			"bulkhead_in_flight",
+			Help:			"Calls to a downstream service in progress.",
+			ConstLabels:	labels,
+		}, func() float64 { return float64(inFlight()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:		namespace,
+			Name:			"bulkhead_waiting",
+			Help:			"Calls waiting for a free bulkhead slot.",
+			ConstLabels:	labels,
+		}, func() float64 { return float64(waiting()) }),
+	)
+}
+
+// Глубина очередей считывается в момент сбора метрик
+func RegisterQueueDepth(pending, deadLetter func() (int64, error)) {
+	commonmetrics.Registry.MustRegister(
+		depthGauge("retry_queue_depth", "Requests waiting in the retry queue.", pending),
+		depthGauge("retry_dead_letter_depth", "Requests in the retry dead letter queue.", deadLetter),
+	)
+}
+
+func depthGauge(name, help string, depth func() (int64, error)) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:	namespace,
+		Name:		name,
+		Help:		help,
+	}, func() float64 {
+		value, err := depth()
+		if err != nil {
+			return -1
+		}
+		return float64(value)
+	})
+}
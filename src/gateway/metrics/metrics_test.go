@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Тест: ошибки вызовов разделяются по причине
+func TestObserveDownstream_ErrorReasons(t *testing.T) {
+	ObserveDownstream("payment:8050", "POST", 0, errors.New("connection refused"), time.Millisecond)
+	ObserveDownstream("payment:8050", "POST", 503, nil, time.Millisecond)
+	ObserveDownstream("payment:8050", "POST", 200, nil, time.Millisecond)
+	ObserveRejected("payment", "POST")
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(downstreamErrors.WithLabelValues("payment:8050", "POST", "transport")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(downstreamErrors.WithLabelValues("payment:8050", "POST", "server_error")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(downstreamErrors.WithLabelValues("payment", "POST", "circuit_open")))
+}
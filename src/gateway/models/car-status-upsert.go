@@ -1,5 +0,0 @@
-package models
-
-type CarStatusUpsert struct {
-	Availability bool `json:"availability"`
-}
\ No newline at end of file
@@ -0,0 +1,10 @@
+package models
+
+// Части ответа, которые могут быть заполнены только идентификатором
+const (
+	DegradedCar		= "car"
+	DegradedPayment	= "payment"
+)
+
+// Причины деградации по частям ответа, например car=circuit_open
+type Degradation map[string]string
@@ -1,6 +1,7 @@
 package models
 
 type PaymentCreateRequest struct {
+	PaymentUID	string `json:"paymentUid,omitempty"`
 	DateFrom	string `json:"dateFrom"`
 	DateTo		string `json:"dateTo"`
 }
\ No newline at end of file
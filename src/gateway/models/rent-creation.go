@@ -1,6 +1,7 @@
 package models
 
 type RentCreation struct {
+	RentalUID	string 		`json:"rentalUid,omitempty"`
 	PaymentUID	string 		`json:"paymentUid"`
 	CarUID 		string 		`json:"carUid"`
 	DateFrom	string 		`json:"dateFrom"`
@@ -7,4 +7,6 @@ type RentalResponse struct {
     Status    string    		`json:"status"`
 	Car		  CarInfo 			`json:"car"`
 	Payment   PaymentInfo		`json:"payment"`
+	// Части ответа, заполненные fallback-значением
+	Degraded  []string			`json:"degraded,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type ReservationRequest struct {
+	DateFrom string `json:"dateFrom"`
+	DateTo   string `json:"dateTo"`
+}
+
+type ReservationResponse struct {
+	ReservationUID string     `json:"reservationUid"`
+	CarUID         string     `json:"carUid"`
+	DateFrom       string     `json:"dateFrom"`
+	DateTo         string     `json:"dateTo"`
+	RentalUID      string     `json:"rentalUid,omitempty"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+}
+
+type ReservationConfirmRequest struct {
+	RentalUID string `json:"rentalUid"`
+}
+
+// Календарь отдаётся наружу, поэтому чужие брони видны только как занятые даты
+type CarCalendar struct {
+	CarUID       string           `json:"carUid"`
+	From         string           `json:"from"`
+	To           string           `json:"to"`
+	Reservations []BookedInterval `json:"reservations"`
+}
+
+// ExpiresAt задан, пока бронь - неподтверждённое удержание
+type BookedInterval struct {
+	DateFrom  string     `json:"dateFrom"`
+	DateTo    string     `json:"dateTo"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
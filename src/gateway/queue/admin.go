@@ -0,0 +1,303 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrorNotFound = errors.New("retry request not found")
+
+const (
+	EntryReady      = "ready"
+	EntryDelayed    = "delayed"
+	EntryProcessing = "processing"
+	EntryWaiting    = "waiting"
+	EntryDead       = "dead"
+)
+
+type QueueEntry struct {
+	Queue   string       `json:"queue"`
+	DueAt   *time.Time   `json:"dueAt,omitempty"`
+	Request RetryRequest `json:"request"`
+	raw     string
+	listKey string
+}
+
+func entityListKeys() ([]string, error) {
+	var keys []string
+
+	iter := RedisClient.Scan(RedisCtx, 0, retryEntityPrefix+"*", 100).Iterator()
+	for iter.Next(RedisCtx) {
+		keys = append(keys, iter.Val())
+	}
+
+	return keys, iter.Err()
+}
+
+// Сообщения, ожидающие повтора. Состояние есть только у головы списка
+// ресурса, остальные сообщения ресурса ждут её (waiting)
+func ListPending() ([]QueueEntry, error) {
+	keys, err := entityListKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []QueueEntry
+	for _, key := range keys {
+		raws, err := RedisClient.LRange(RedisCtx, key, 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		token := strings.TrimPrefix(key, retryEntityPrefix)
+
+		for i, raw := range raws {
+			entry, ok := decodeEntry(EntryWaiting, raw)
+			if !ok {
+				continue
+			}
+			entry.listKey = key
+
+			if i == 0 {
+				if err := fillHeadState(&entry, token); err != nil {
+					return nil, err
+				}
+			}
+
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func fillHeadState(entry *QueueEntry, token string) error {
+	score, err := RedisClient.ZScore(RedisCtx, RetryDelayedKey, token).Result()
+	if err == nil {
+		due := time.UnixMilli(int64(score))
+		entry.Queue = EntryDelayed
+		entry.DueAt = &due
+		return nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	_, err = RedisClient.LPos(RedisCtx, RetryQueueKey, token, redis.LPosArgs{}).Result()
+	if err == nil {
+		entry.Queue = EntryReady
+		return nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	entry.Queue = EntryProcessing
+	return nil
+}
+
+// Число сообщений, ожидающих повтора, во всех списках ресурсов
+func PendingDepth() (int64, error) {
+	keys, err := entityListKeys()
+	if err != nil {
+		return 0, err
+	}
+
+	pipe := RedisClient.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.LLen(RedisCtx, key)
+	}
+	if _, err := pipe.Exec(RedisCtx); err != nil {
+		return 0, err
+	}
+
+	var depth int64
+	for _, cmd := range cmds {
+		depth += cmd.Val()
+	}
+
+	return depth, nil
+}
+
+func DeadLetterDepth() (int64, error) {
+	return RedisClient.LLen(RedisCtx, RetryDeadLetterKey).Result()
+}
+
+func ListDeadLetters() ([]QueueEntry, error) {
+	dead, err := RedisClient.LRange(RedisCtx, RetryDeadLetterKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]QueueEntry, 0, len(dead))
+	for _, raw := range dead {
+		if entry, ok := decodeEntry(EntryDead, raw); ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func decodeEntry(queue, raw string) (QueueEntry, bool) {
+	var req RetryRequest
+	if err := json.Unmarshal([]byte(raw), &req); err != nil {
+		return QueueEntry{}, false
+	}
+
+	return QueueEntry{Queue: queue, Request: req, raw: raw}, true
+}
+
+func findEntry(entries []QueueEntry, id string) (QueueEntry, bool) {
+	for _, entry := range entries {
+		if entry.Request.ID == id {
+			return entry, true
+		}
+	}
+	return QueueEntry{}, false
+}
+
+// Удаляет сообщение из списка ресурса. Если список опустел, а токен ресурса
+// только отложен, токен снимается; токен в очереди или у воркера снимет
+// сам воркер, и до этого новый токен ресурсу не выдаётся
+var deletePendingScript = redis.NewScript(`
+local removed = redis.call('LREM', KEYS[1], 1, ARGV[1])
+if removed > 0 and redis.call('LLEN', KEYS[1]) == 0 then
+	if redis.call('ZREM', KEYS[2], ARGV[2]) == 1 then
+		redis.call('SREM', KEYS[3], ARGV[2])
+	end
+end
+return removed
+`)
+
+func DeletePending(id string) error {
+	entries, err := ListPending()
+	if err != nil {
+		return err
+	}
+
+	entry, ok := findEntry(entries, id)
+	if !ok {
+		return ErrorNotFound
+	}
+
+	removed, err := deletePendingScript.Run(RedisCtx, RedisClient,
+		[]string{entry.listKey, RetryDelayedKey, RetryTokensKey},
+		entry.raw, strings.TrimPrefix(entry.listKey, retryEntityPrefix),
+	).Int()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+func DeleteDeadLetter(id string) error {
+	_, err := takeDeadLetter(id)
+	return err
+}
+
+// Возвращает сообщение из dead letter в очередь с обнулённым счётчиком попыток
+func ReplayDeadLetter(id string) error {
+	entries, err := ListDeadLetters()
+	if err != nil {
+		return err
+	}
+
+	entry, ok := findEntry(entries, id)
+	if !ok {
+		return ErrorNotFound
+	}
+
+	moved, err := replay(entry)
+	if err != nil {
+		return err
+	}
+	if !moved {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+func ReplayAllDeadLetters() (int, error) {
+	entries, err := ListDeadLetters()
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, entry := range entries {
+		moved, err := replay(entry)
+		if err != nil {
+			return replayed, err
+		}
+		if moved {
+			replayed++
+		}
+	}
+
+	return replayed, nil
+}
+
+func takeDeadLetter(id string) (RetryRequest, error) {
+	entries, err := ListDeadLetters()
+	if err != nil {
+		return RetryRequest{}, err
+	}
+
+	entry, ok := findEntry(entries, id)
+	if !ok {
+		return RetryRequest{}, ErrorNotFound
+	}
+
+	removed, err := RedisClient.LRem(RedisCtx, RetryDeadLetterKey, 1, entry.raw).Result()
+	if err != nil {
+		return RetryRequest{}, err
+	}
+	if removed == 0 {
+		return RetryRequest{}, ErrorNotFound
+	}
+
+	return entry.Request, nil
+}
+
+// Удаление из dead letter и постановка в очередь ресурса - один скрипт,
+// чтобы сообщение не потерялось, если между ними упадёт gateway или Redis.
+// Постановка повторяет enqueueScript
+var replayScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+local len = redis.call('RPUSH', KEYS[2], ARGV[2])
+if redis.call('SADD', KEYS[4], ARGV[3]) == 1 and len == 1 then
+	redis.call('ZADD', KEYS[3], ARGV[4], ARGV[3])
+end
+return 1
+`)
+
+// Сообщение, которое уже забрал другой запрос, не переносится
+func replay(entry QueueEntry) (bool, error) {
+	req := entry.Request
+	req.Attempts = 0
+
+	req, data, err := encodeRetry(req)
+	if err != nil {
+		return false, err
+	}
+
+	moved, err := replayScript.Run(RedisCtx, RedisClient,
+		[]string{RetryDeadLetterKey, entityListKey(req.Entity), RetryDelayedKey, RetryTokensKey},
+		entry.raw, data, req.Entity, time.Now().UnixMilli(),
+	).Int()
+
+	return moved == 1, err
+}
@@ -1,22 +1,63 @@
 package queue
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"io"
-	"log"
-	"net/http"
+	"log/slog"
+	"net/url"
+	"os"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	RetryQueueKey      = "retry_queue"
+	RetryDelayedKey    = "retry_delayed"
+	RetryDeadLetterKey = "retry_dead_letter"
+	RetryWorkersKey    = "retry_workers"
+	// Ресурсы, токен которых сейчас в очереди, отложен или обрабатывается
+	RetryTokensKey     = "retry_tokens"
+	retryEntityPrefix  = "retry_entity:"
+)
+
+// Храним только последние попытки, чтобы сообщение не разрасталось
+const maxRetryHistory = 20
+
+type RetryAttempt struct {
+	At     time.Time
+	Status int
+	Error  string
+}
+
 type RetryRequest struct {
-	Method  string
-	URL     string
-	Headers map[string]string
-	Body    []byte
+	ID          string
+	Entity      string
+	Method      string
+	// Сервис и путь запроса: экземпляр сервиса выбирается при отправке
+	Service     string `json:",omitempty"`
+	Path        string `json:",omitempty"`
+	// Полный адрес сообщений, поставленных до появления Service
+	URL         string `json:",omitempty"`
+	Headers     map[string]string
+	Body        []byte
+	// traceparent исходного запроса, повторы продолжают его трассу
+	TraceParent string `json:",omitempty"`
+	// X-Request-ID исходного запроса, по нему повтор находится в логах
+	RequestID   string `json:",omitempty"`
+	Attempts    int
+	LastStatus  int
+	LastError   string
+	EnqueuedAt  time.Time
+	History     []RetryAttempt
+}
+
+func (r RetryRequest) target() string {
+	if r.Service != "" {
+		return r.Path
+	}
+	return r.URL
 }
 
 var RedisCtx = context.Background()
@@ -31,90 +72,98 @@ func InitRedis(addr, password string) {
 
 	_, err := RedisClient.Ping(RedisCtx).Result()
 	if err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		slog.Error("failed to connect to Redis", "error", err)
+		os.Exit(1)
 	}
-	log.Println("Redis connected")
+	slog.Info("redis connected")
 }
 
-func EnqueueRetry(req RetryRequest) error {
-	data, err := json.Marshal(req)
-	if err != nil {
-		return err
-	}
+var retryConfig = RetryConfig{
+	MaxAttempts: 10,
+	BaseDelay:   time.Second,
+	MaxDelay:    5 * time.Minute,
+}
 
-	return RedisClient.RPush(RedisCtx, "retry_queue", data).Err()
+// Повторы упорядочены по ресурсу (Entity): сообщения ресурса лежат в его
+// списке retry_entity:<entity>, а в очередях retry_queue/retry_delayed
+// находится не больше одного токена ресурса. Воркер, взявший токен,
+// обрабатывает только голову списка, поэтому два запроса к одному
+// ресурсу не выполняются параллельно и не меняются местами
+
+// Переносит из отложенного множества в очередь токены, время которых наступило
+var promoteDueScript = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, item in ipairs(items) do
+	redis.call('ZREM', KEYS[1], item)
+	redis.call('RPUSH', KEYS[2], item)
+end
+return #items
+`)
+
+// Добавляет сообщение в список ресурса; токен ставится, только если
+// список был пуст и у ресурса нет выданного токена. Список может опустеть
+// после удаления сообщений из админки, пока токен ещё у воркера: тогда
+// сообщение обработает этот токен, иначе два воркера взяли бы один ресурс
+var enqueueScript = redis.NewScript(`
+local len = redis.call('RPUSH', KEYS[1], ARGV[1])
+if redis.call('SADD', KEYS[3], ARGV[2]) == 1 and len == 1 then
+	redis.call('ZADD', KEYS[2], ARGV[3], ARGV[2])
+end
+return len
+`)
+
+func entityListKey(entity string) string {
+	return retryEntityPrefix + entity
 }
 
-func DoRequest(method, url string, headers map[string]string, body []byte) (int, []byte, error) {
-    req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
-    if err != nil {
-        return 0, nil, err
-    }
+// Ресурс определяется путём запроса без query: /cars/<uid>, /rental/<uid>.
+// Хост не учитывается, иначе запросы к одному ресурсу через разные
+// экземпляры сервиса выполнялись бы параллельно
+func EntityOf(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
 
-    for k, v := range headers {
-        req.Header.Set(k, v)
-    }
+	return u.Path
+}
 
-    client := &http.Client{
-        Timeout: 5 * time.Second,
-    }
+// Первая попытка воркера откладывается на базовую задержку,
+// так как вызывающий код только что получил ошибку
+func EnqueueRetry(req RetryRequest) error {
+	return enqueueAt(req, time.Now().Add(retryConfig.Backoff(req.Attempts+1)))
+}
 
-    resp, err := client.Do(req)
-    if err != nil {
-        return 0, nil, err
-    }
-    defer resp.Body.Close()
+func enqueueAt(req RetryRequest, due time.Time) error {
+	req, data, err := encodeRetry(req)
+	if err != nil {
+		return err
+	}
 
-    respBody, err := io.ReadAll(resp.Body)
-    return resp.StatusCode, respBody, err
+	return enqueueScript.Run(RedisCtx, RedisClient,
+		[]string{entityListKey(req.Entity), RetryDelayedKey, RetryTokensKey},
+		data, req.Entity, due.UnixMilli(),
+	).Err()
 }
 
-
-func StartRetryWorker() {
-	if RedisClient == nil {
-		return
+func encodeRetry(req RetryRequest) (RetryRequest, []byte, error) {
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+	if req.Entity == "" {
+		req.Entity = EntityOf(req.target())
+	}
+	if req.EnqueuedAt.IsZero() {
+		req.EnqueuedAt = time.Now()
 	}
-	
-	go func() {
-		for {
-			res, err := RedisClient.BLPop(RedisCtx, 5*time.Second, "retry_queue").Result()
-			if err != nil {
-				if err == redis.Nil {
-					continue
-				}
-				log.Printf("Redis BLPop error: %v", err)
-				time.Sleep(1 * time.Second)
-				continue
-			}
-
-			var req RetryRequest
-			if err := json.Unmarshal([]byte(res[1]), &req); err != nil {
-				log.Printf("Failed to unmarshal request: %v", err)
-				continue
-			}
-
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-			reqHTTP, _ := http.NewRequestWithContext(ctx, req.Method, req.URL, bytes.NewReader(req.Body))
-			for k, v := range req.Headers {
-				reqHTTP.Header.Set(k, v)
-			}
-
-			client := &http.Client{Timeout: 10 * time.Second}
-			resp, err := client.Do(reqHTTP)
-			cancel()
-
-			if err != nil || (resp != nil && resp.StatusCode >= 400) {
-				log.Printf("Retry failed for %s, re-enqueueing", req.URL)
-				EnqueueRetry(req)
-			} else {
-				log.Printf("Retry succeeded for %s", req.URL)
-			}
-
-			if resp != nil {
-				resp.Body.Close()
-			}
-			time.Sleep(2 * time.Second)
-		}
-	}()
+
+	data, err := json.Marshal(req)
+	return req, data, err
 }
 
+func promoteDue(now time.Time) (int, error) {
+	return promoteDueScript.Run(RedisCtx, RedisClient,
+		[]string{RetryDelayedKey, RetryQueueKey},
+		now.UnixMilli(), 100,
+	).Int()
+}
@@ -0,0 +1,51 @@
+package queue
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+type RetryConfig struct {
+	Workers     int
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Экспоненциальная задержка с jitter: половина задержки фиксирована,
+// вторая половина случайна, чтобы повторы разных сообщений не совпадали
+func (c RetryConfig) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := c.BaseDelay
+	for i := 1; i < attempt && delay < c.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Ошибки сети, 5xx и перегрузка сервиса повторяются,
+// остальные 4xx считаются окончательными
+func IsRetryableStatus(status int) bool {
+	switch {
+	case status >= 500:
+		return true
+	case status == http.StatusRequestTimeout, status == http.StatusTooEarly, status == http.StatusTooManyRequests:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,40 @@
+package queue
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Тест: задержка растёт экспоненциально и ограничена сверху
+func TestRetryConfig_Backoff_Bounds(t *testing.T) {
+	cfg := RetryConfig{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	for i := 0; i < 100; i++ {
+		first := cfg.Backoff(1)
+		assert.GreaterOrEqual(t, first, 500*time.Millisecond)
+		assert.LessOrEqual(t, first, time.Second)
+
+		third := cfg.Backoff(3)
+		assert.GreaterOrEqual(t, third, 2*time.Second)
+		assert.LessOrEqual(t, third, 4*time.Second)
+
+		capped := cfg.Backoff(20)
+		assert.GreaterOrEqual(t, capped, 5*time.Second)
+		assert.LessOrEqual(t, capped, 10*time.Second)
+	}
+}
+
+// Тест: окончательные 4xx не повторяются
+func TestIsRetryableStatus(t *testing.T) {
+	assert.True(t, IsRetryableStatus(http.StatusInternalServerError))
+	assert.True(t, IsRetryableStatus(http.StatusServiceUnavailable))
+	assert.True(t, IsRetryableStatus(http.StatusTooManyRequests))
+	assert.True(t, IsRetryableStatus(http.StatusRequestTimeout))
+
+	assert.False(t, IsRetryableStatus(http.StatusNotFound))
+	assert.False(t, IsRetryableStatus(http.StatusBadRequest))
+	assert.False(t, IsRetryableStatus(http.StatusConflict))
+}
@@ -0,0 +1,432 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/deadline"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/tracing"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/balancer"
+)
+
+const (
+	heartbeatInterval = 5 * time.Second
+	heartbeatTTL      = 30 * time.Second
+	reaperInterval    = 15 * time.Second
+)
+
+// Если heartbeat воркера так и не вернулся, возвращает в начало очереди
+// все токены из его списка обработки и снимает воркера с учёта.
+// Для ожившего воркера возвращает -1
+var reapScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return -1
+end
+local moved = 0
+while redis.call('LMOVE', KEYS[2], KEYS[3], 'RIGHT', 'LEFT') do
+	moved = moved + 1
+end
+redis.call('SREM', KEYS[4], ARGV[1])
+return moved
+`)
+
+func processingKey(workerID string) string {
+	return "retry_processing:" + workerID
+}
+
+func heartbeatKey(workerID string) string {
+	return "retry_worker:" + workerID
+}
+
+// Завершает обработку головы списка ресурса: снимает её (при необходимости
+// кладёт в dead letter), подтверждает токен и возвращает его в очередь,
+// если у ресурса остались сообщения, иначе токен ресурса больше не выдан
+var completeScript = redis.NewScript(`
+if redis.call('LINDEX', KEYS[1], 0) == ARGV[2] then
+	redis.call('LPOP', KEYS[1])
+end
+if ARGV[3] ~= '' then
+	redis.call('RPUSH', KEYS[4], ARGV[3])
+end
+redis.call('LREM', KEYS[2], 1, ARGV[1])
+if redis.call('LLEN', KEYS[1]) > 0 then
+	redis.call('RPUSH', KEYS[3], ARGV[1])
+else
+	redis.call('SREM', KEYS[5], ARGV[1])
+end
+return 1
+`)
+
+// Оставляет голову в списке ресурса с обновлённым счётчиком попыток
+// и откладывает токен ресурса до следующей попытки
+var rescheduleScript = redis.NewScript(`
+if redis.call('LINDEX', KEYS[1], 0) == ARGV[2] then
+	redis.call('LSET', KEYS[1], 0, ARGV[3])
+end
+redis.call('ZADD', KEYS[3], ARGV[4], ARGV[1])
+redis.call('LREM', KEYS[2], 1, ARGV[1])
+return 1
+`)
+
+// Воркер забирает токен ресурса в свой список обработки и удаляет его
+// оттуда только после того, как результат вызова записан. Токены воркера,
+// переставшего отправлять heartbeat, возвращаются в очередь
+type Worker struct {
+	ID            string
+	processingKey string
+}
+
+func NewWorker() *Worker {
+	host, _ := os.Hostname()
+	id := fmt.Sprintf("%s-%s", host, uuid.New().String()[:8])
+
+	return &Worker{ID: id, processingKey: processingKey(id)}
+}
+
+func (w *Worker) Register() error {
+	return w.Heartbeat()
+}
+
+// Воркер каждый раз заново добавляется в набор: если reaper уже снял его
+// после сбоя Redis, без этого его следующее падение не будет замечено
+func (w *Worker) Heartbeat() error {
+	pipe := RedisClient.TxPipeline()
+	pipe.SAdd(RedisCtx, RetryWorkersKey, w.ID)
+	pipe.Set(RedisCtx, heartbeatKey(w.ID), time.Now().UnixMilli(), heartbeatTTL)
+	_, err := pipe.Exec(RedisCtx)
+	return err
+}
+
+// Атомарно переносит токен из очереди в список обработки воркера
+func (w *Worker) Fetch(timeout time.Duration) (string, error) {
+	return RedisClient.BLMove(RedisCtx, RetryQueueKey, w.processingKey, "LEFT", "RIGHT", timeout).Result()
+}
+
+func (w *Worker) Handle(token string) error {
+	// Сообщения, поставленные в очередь до упорядочивания по ресурсам
+	if strings.HasPrefix(token, "{") {
+		return w.migrateLegacy(token)
+	}
+
+	listKey := entityListKey(token)
+
+	// Список пуст: сообщения удалены, пока токен ждал. Снятие токена
+	// атомарно с проверкой списка, чтобы не потерять новое сообщение
+	raw, err := RedisClient.LIndex(RedisCtx, listKey, 0).Result()
+	if err == redis.Nil {
+		return w.complete(token, "", "")
+	}
+	if err != nil {
+		return err
+	}
+
+	var req RetryRequest
+	if err := json.Unmarshal([]byte(raw), &req); err != nil {
+		slog.Error("failed to unmarshal retry request", logging.Err(err))
+		return w.complete(token, raw, "")
+	}
+
+	logger := retryLogger(req)
+
+	status, err := sendRetry(req)
+	updated, decision := decideRetry(req, status, err)
+
+	updatedData, err := json.Marshal(updated)
+	if err != nil {
+		return err
+	}
+
+	switch decision {
+	case decisionRetry:
+		delay := retryConfig.Backoff(updated.Attempts + 1)
+		logger.Warn("retry failed", "attempt", updated.Attempts, "status", status, "next_in", delay.String(), logging.Err(err))
+
+		return rescheduleScript.Run(RedisCtx, RedisClient,
+			[]string{listKey, w.processingKey, RetryDelayedKey},
+			token, raw, updatedData, time.Now().Add(delay).UnixMilli(),
+		).Err()
+	case decisionDeadLetter:
+		logger.Error("retry moved to dead letter", "attempt", updated.Attempts, logging.KeyError, updated.LastError)
+		return w.complete(token, raw, string(updatedData))
+	default:
+		logger.Info("retry succeeded", "attempt", updated.Attempts)
+		return w.complete(token, raw, "")
+	}
+}
+
+func (w *Worker) complete(token, raw, deadLetter string) error {
+	return completeScript.Run(RedisCtx, RedisClient,
+		[]string{entityListKey(token), w.processingKey, RetryQueueKey, RetryDeadLetterKey, RetryTokensKey},
+		token, raw, deadLetter,
+	).Err()
+}
+
+func (w *Worker) migrateLegacy(raw string) error {
+	var req RetryRequest
+	if err := json.Unmarshal([]byte(raw), &req); err != nil {
+		slog.Error("failed to unmarshal retry request", logging.Err(err))
+	} else if err := enqueueAt(req, time.Now()); err != nil {
+		return err
+	}
+
+	return RedisClient.LRem(RedisCtx, w.processingKey, 1, raw).Err()
+}
+
+// Возвращает в очередь токены воркеров без heartbeat
+func ReapDeadWorkers() (int, error) {
+	workers, err := RedisClient.SMembers(RedisCtx, RetryWorkersKey).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	requeued := 0
+	for _, id := range workers {
+		// Проверка heartbeat и перенос токенов - один скрипт, чтобы не
+		// забрать токены у воркера, heartbeat которого только что вернулся
+		moved, err := reapScript.Run(RedisCtx, RedisClient,
+			[]string{heartbeatKey(id), processingKey(id), RetryQueueKey, RetryWorkersKey},
+			id,
+		).Int()
+		if err != nil {
+			return requeued, err
+		}
+		if moved < 0 {
+			continue
+		}
+
+		if moved > 0 {
+			slog.Warn("requeued retry requests of dead worker", "worker", id, "count", moved)
+		}
+		requeued += moved
+	}
+
+	return requeued, nil
+}
+
+// Пул воркеров: ресурсы обрабатываются параллельно,
+// запросы к одному ресурсу - строго по очереди
+func StartRetryWorker(cfg RetryConfig) {
+	if RedisClient == nil {
+		return
+	}
+
+	retryConfig = cfg
+
+	workers := make([]*Worker, max(cfg.Workers, 1))
+	for i := range workers {
+		workers[i] = NewWorker()
+		if err := workers[i].Register(); err != nil {
+			slog.Error("retry worker registration error", logging.Err(err))
+		}
+	}
+
+	go func() {
+		for {
+			time.Sleep(heartbeatInterval)
+			for _, worker := range workers {
+				if err := worker.Heartbeat(); err != nil {
+					slog.Warn("retry worker heartbeat error", logging.Err(err))
+				}
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			if _, err := ReapDeadWorkers(); err != nil {
+				slog.Warn("retry reaper error", logging.Err(err))
+			}
+			time.Sleep(reaperInterval)
+		}
+	}()
+
+	for _, worker := range workers {
+		go worker.run()
+	}
+}
+
+func (w *Worker) run() {
+	for {
+		if _, err := promoteDue(time.Now()); err != nil {
+			slog.Warn("retry promotion error", logging.Err(err))
+		}
+
+		token, err := w.Fetch(time.Second)
+		if err != nil {
+			if err == redis.Nil {
+				continue
+			}
+			slog.Warn("retry queue fetch error", logging.Err(err))
+			time.Sleep(1 * time.Second)
+			continue
+		}
+
+		if err := w.Handle(token); err != nil {
+			slog.Warn("retry acknowledgement error", logging.Err(err))
+		}
+	}
+}
+
+const (
+	decisionDone = iota
+	decisionRetry
+	decisionDeadLetter
+)
+
+func decideRetry(req RetryRequest, status int, err error) (RetryRequest, int) {
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+
+	req.Attempts++
+	req.LastStatus = status
+
+	switch {
+	case err == nil && status < 400:
+		return req, decisionDone
+	case err != nil:
+		req.LastError = err.Error()
+	default:
+		req.LastError = http.StatusText(status)
+	}
+
+	req.History = append(req.History, RetryAttempt{At: time.Now(), Status: status, Error: req.LastError})
+	if len(req.History) > maxRetryHistory {
+		req.History = req.History[len(req.History)-maxRetryHistory:]
+	}
+
+	if err == nil && !IsRetryableStatus(status) {
+		return req, decisionDeadLetter
+	}
+
+	if req.Attempts >= retryConfig.MaxAttempts {
+		return req, decisionDeadLetter
+	}
+
+	return req, decisionRetry
+}
+
+func retryLogger(req RetryRequest) *slog.Logger {
+	logger := slog.Default().With("retry_id", req.ID, "method", req.Method, "url", req.target())
+	if req.Service != "" {
+		logger = logger.With(logging.KeyDownstream, req.Service)
+	}
+	if req.RequestID != "" {
+		logger = logger.With(logging.KeyRequestID, req.RequestID)
+	}
+	if sc, ok := tracing.ParseTraceParent(req.TraceParent); ok {
+		logger = logger.With(logging.KeyTraceID, sc.TraceIDString())
+	}
+	return logger
+}
+
+var (
+	targetsMu sync.RWMutex
+	targets   = map[string]*balancer.Balancer{}
+)
+
+// Экземпляры сервиса для повторов. Экземпляр выбирается при каждой
+// отправке, поэтому повтор не привязан к экземпляру, который мог упасть
+func RegisterTargets(service string, b *balancer.Balancer) {
+	targetsMu.Lock()
+	defer targetsMu.Unlock()
+
+	targets[service] = b
+}
+
+// Адрес повтора и выбранный экземпляр, у старых сообщений - сохранённый адрес
+func retryTarget(req RetryRequest) (string, *balancer.Instance, error) {
+	if req.Service == "" {
+		return req.URL, nil, nil
+	}
+
+	targetsMu.RLock()
+	b, ok := targets[req.Service]
+	targetsMu.RUnlock()
+	if !ok {
+		return "", nil, fmt.Errorf("unknown retry service %q", req.Service)
+	}
+
+	instance, err := b.Pick()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return instance.URL + req.Path, instance, nil
+}
+
+func sendRetry(req RetryRequest) (int, error) {
+	target, instance, err := retryTarget(req)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	status, err := sendRetryTo(req, target)
+	if instance != nil {
+		instance.Record(err == nil && status < http.StatusInternalServerError, time.Since(start))
+	}
+
+	return status, err
+}
+
+func sendRetryTo(req RetryRequest, target string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if remote, ok := tracing.ParseTraceParent(req.TraceParent); ok {
+		ctx = tracing.ContextWithRemote(ctx, remote)
+	}
+
+	ctx, span := tracing.Start(ctx, "retry " + req.Method, tracing.KindClient)
+	defer span.End()
+
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", target)
+	span.SetAttribute("retry.attempt", strconv.Itoa(req.Attempts + 1))
+
+	reqHTTP, err := http.NewRequestWithContext(ctx, req.Method, target, bytes.NewReader(req.Body))
+	if err != nil {
+		span.SetError(err)
+		return 0, err
+	}
+
+	for k, v := range req.Headers {
+		reqHTTP.Header.Set(k, v)
+	}
+	tracing.Inject(ctx, reqHTTP.Header)
+	deadline.Inject(ctx, reqHTTP.Header)
+	if req.RequestID != "" {
+		reqHTTP.Header.Set(logging.HeaderRequestID, req.RequestID)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(reqHTTP)
+	if err != nil {
+		span.SetError(err)
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	span.SetAttribute("http.status_code", strconv.Itoa(resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetError(fmt.Errorf("service error: %d", resp.StatusCode))
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
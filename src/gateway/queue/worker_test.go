@@ -0,0 +1,434 @@
+package queue
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/tracing"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/balancer"
+	cb "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/circuitBreaker"
+)
+
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	mr := miniredis.RunT(t)
+	RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	retryConfig = RetryConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}
+
+	t.Cleanup(func() { RedisClient.Close() })
+	return mr
+}
+
+// Ставит сообщение в очередь ресурса и сразу делает токен готовым к обработке
+func pushReady(t *testing.T, req RetryRequest) {
+	require.NoError(t, enqueueAt(req, time.Now().Add(-time.Millisecond)))
+	_, err := promoteDue(time.Now())
+	require.NoError(t, err)
+}
+
+func statusServer(t *testing.T, status int) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// Тест: токен ресурса переносится в список обработки и удаляется после подтверждения
+func TestWorker_FetchAndAck(t *testing.T) {
+	setupRedis(t)
+	srv := statusServer(t, http.StatusOK)
+
+	worker := NewWorker()
+	pushReady(t, RetryRequest{ID: "1", Method: "PATCH", URL: srv.URL + "/cars/1"})
+
+	token, err := worker.Fetch(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, EntityOf(srv.URL+"/cars/1"), token)
+
+	processing, _ := RedisClient.LRange(RedisCtx, worker.processingKey, 0, -1).Result()
+	assert.Equal(t, []string{token}, processing)
+	queued, _ := RedisClient.LLen(RedisCtx, RetryQueueKey).Result()
+	assert.Zero(t, queued)
+
+	require.NoError(t, worker.Handle(token))
+
+	processing, _ = RedisClient.LRange(RedisCtx, worker.processingKey, 0, -1).Result()
+	assert.Empty(t, processing)
+	pending, _ := ListPending()
+	assert.Empty(t, pending)
+}
+
+// Тест: повтор продолжает трассу исходного запроса
+func TestWorker_Handle_PropagatesTraceParent(t *testing.T) {
+	setupRedis(t)
+
+	var received string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(tracing.HeaderTraceParent)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	original := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	pushReady(t, RetryRequest{ID: "1", Method: "PATCH", URL: srv.URL + "/cars/1", TraceParent: original})
+
+	worker := NewWorker()
+	token, err := worker.Fetch(time.Second)
+	require.NoError(t, err)
+	require.NoError(t, worker.Handle(token))
+
+	sc, ok := tracing.ParseTraceParent(received)
+	require.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceIDString())
+	assert.NotEqual(t, "00f067aa0ba902b7", sc.SpanIDString())
+}
+
+// Тест: ошибка 5xx откладывает повтор и увеличивает счётчик попыток
+func TestWorker_Handle_ServerErrorScheduled(t *testing.T) {
+	setupRedis(t)
+	srv := statusServer(t, http.StatusServiceUnavailable)
+
+	worker := NewWorker()
+	pushReady(t, RetryRequest{ID: "1", Method: "PATCH", URL: srv.URL + "/cars/1"})
+
+	token, err := worker.Fetch(time.Second)
+	require.NoError(t, err)
+	require.NoError(t, worker.Handle(token))
+
+	pending, err := ListPending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, EntryDelayed, pending[0].Queue)
+	assert.Equal(t, 1, pending[0].Request.Attempts)
+	assert.Len(t, pending[0].Request.History, 1)
+
+	processing, _ := RedisClient.LLen(RedisCtx, worker.processingKey).Result()
+	assert.Zero(t, processing)
+}
+
+// Тест: окончательная ошибка 4xx сразу уходит в dead letter
+func TestWorker_Handle_PermanentFailureDeadLettered(t *testing.T) {
+	setupRedis(t)
+	srv := statusServer(t, http.StatusNotFound)
+
+	worker := NewWorker()
+	pushReady(t, RetryRequest{ID: "1", Method: "PATCH", URL: srv.URL + "/rental/1"})
+
+	token, _ := worker.Fetch(time.Second)
+	require.NoError(t, worker.Handle(token))
+
+	dead, err := ListDeadLetters()
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, http.StatusNotFound, dead[0].Request.LastStatus)
+
+	pending, _ := ListPending()
+	assert.Empty(t, pending)
+}
+
+// Тест: сообщение из dead letter переносится в очередь ресурса один раз
+func TestReplayDeadLetter_MovesOnce(t *testing.T) {
+	setupRedis(t)
+	srv := statusServer(t, http.StatusNotFound)
+
+	worker := NewWorker()
+	pushReady(t, RetryRequest{ID: "1", Method: "PATCH", URL: srv.URL + "/rental/1"})
+	token, _ := worker.Fetch(time.Second)
+	require.NoError(t, worker.Handle(token))
+
+	require.NoError(t, ReplayDeadLetter("1"))
+	assert.ErrorIs(t, ReplayDeadLetter("1"), ErrorNotFound)
+
+	dead, _ := ListDeadLetters()
+	assert.Empty(t, dead)
+	pending, _ := ListPending()
+	require.Len(t, pending, 1)
+	assert.Zero(t, pending[0].Request.Attempts)
+
+	replayed, err := ReplayAllDeadLetters()
+	require.NoError(t, err)
+	assert.Zero(t, replayed)
+}
+
+// Тест: после исчерпания попыток сообщение уходит в dead letter
+func TestWorker_Handle_AttemptsExhausted(t *testing.T) {
+	setupRedis(t)
+	srv := statusServer(t, http.StatusInternalServerError)
+
+	worker := NewWorker()
+	pushReady(t, RetryRequest{ID: "1", Method: "PATCH", URL: srv.URL + "/cars/1", Attempts: 2})
+
+	token, _ := worker.Fetch(time.Second)
+	require.NoError(t, worker.Handle(token))
+
+	dead, _ := ListDeadLetters()
+	require.Len(t, dead, 1)
+	assert.Equal(t, 3, dead[0].Request.Attempts)
+}
+
+// Тест: сообщение, ожидающее повтора, не пропускает вперёд следующее сообщение того же ресурса
+func TestWorker_SameEntityKeepsOrder(t *testing.T) {
+	setupRedis(t)
+
+	var calls []string
+	failFirst := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, string(body))
+		if failFirst {
+			failFirst = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	rentalUrl := srv.URL + "/rental/1"
+	pushReady(t, RetryRequest{ID: "canceled", Method: "PATCH", URL: rentalUrl, Body: []byte("CANCELED")})
+	pushReady(t, RetryRequest{ID: "finished", Method: "PATCH", URL: rentalUrl, Body: []byte("FINISHED")})
+
+	worker := NewWorker()
+
+	token, err := worker.Fetch(time.Second)
+	require.NoError(t, err)
+	require.NoError(t, worker.Handle(token))
+
+	// Первый запрос отложен, второй не должен быть доступен воркерам
+	_, err = worker.Fetch(100 * time.Millisecond)
+	assert.ErrorIs(t, err, redis.Nil)
+
+	_, err = promoteDue(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		token, err := worker.Fetch(time.Second)
+		require.NoError(t, err)
+		require.NoError(t, worker.Handle(token))
+	}
+
+	assert.Equal(t, []string{"CANCELED", "CANCELED", "FINISHED"}, calls)
+}
+
+// Тест: сообщения разных ресурсов обрабатываются параллельно
+func TestWorker_DifferentEntitiesInParallel(t *testing.T) {
+	setupRedis(t)
+
+	pushReady(t, RetryRequest{ID: "1", Method: "PATCH", URL: "http://cars/api/v1/cars/1"})
+	pushReady(t, RetryRequest{ID: "2", Method: "PATCH", URL: "http://cars/api/v1/cars/2"})
+	pushReady(t, RetryRequest{ID: "3", Method: "PATCH", URL: "http://cars/api/v1/cars/1"})
+
+	first, second := NewWorker(), NewWorker()
+
+	tokenFirst, err := first.Fetch(time.Second)
+	require.NoError(t, err)
+	tokenSecond, err := second.Fetch(time.Second)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"/api/v1/cars/1", "/api/v1/cars/2"}, []string{tokenFirst, tokenSecond})
+
+	queued, _ := RedisClient.LLen(RedisCtx, RetryQueueKey).Result()
+	assert.Zero(t, queued)
+}
+
+// Тест: после удаления сообщений, пока токен у воркера, новое сообщение
+// ресурса не получает второй токен и не уходит другому воркеру
+func TestDeletePending_TokenOutstanding_NoSecondToken(t *testing.T) {
+	setupRedis(t)
+
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, string(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	rentalUrl := srv.URL + "/rental/1"
+	pushReady(t, RetryRequest{ID: "deleted", Method: "PATCH", URL: rentalUrl, Body: []byte("CANCELED")})
+
+	first, second := NewWorker(), NewWorker()
+
+	token, err := first.Fetch(time.Second)
+	require.NoError(t, err)
+
+	require.NoError(t, DeletePending("deleted"))
+	pushReady(t, RetryRequest{ID: "next", Method: "PATCH", URL: rentalUrl, Body: []byte("FINISHED")})
+
+	// Токен ресурса всё ещё у первого воркера
+	_, err = second.Fetch(100 * time.Millisecond)
+	assert.ErrorIs(t, err, redis.Nil)
+
+	// Первый воркер обрабатывает новое сообщение своим токеном
+	require.NoError(t, first.Handle(token))
+	assert.Equal(t, []string{"FINISHED"}, calls)
+
+	pending, _ := ListPending()
+	assert.Empty(t, pending)
+	outstanding, _ := RedisClient.SMembers(RedisCtx, RetryTokensKey).Result()
+	assert.Empty(t, outstanding)
+}
+
+// Тест: если удалено единственное отложенное сообщение, токен ресурса снимается
+func TestDeletePending_DelayedTokenRemoved(t *testing.T) {
+	setupRedis(t)
+
+	require.NoError(t, enqueueAt(RetryRequest{ID: "1", Method: "PATCH", URL: "http://cars/api/v1/cars/1"}, time.Now().Add(time.Hour)))
+	require.NoError(t, DeletePending("1"))
+
+	delayed, _ := RedisClient.ZCard(RedisCtx, RetryDelayedKey).Result()
+	assert.Zero(t, delayed)
+
+	// Следующее сообщение ресурса получает новый токен
+	pushReady(t, RetryRequest{ID: "2", Method: "PATCH", URL: "http://cars/api/v1/cars/1"})
+	queued, _ := RedisClient.LRange(RedisCtx, RetryQueueKey, 0, -1).Result()
+	assert.Equal(t, []string{"/api/v1/cars/1"}, queued)
+}
+
+// Тест: токены воркера без heartbeat возвращаются в очередь
+func TestReapDeadWorkers_RequeuesProcessing(t *testing.T) {
+	mr := setupRedis(t)
+
+	dead := NewWorker()
+	require.NoError(t, dead.Register())
+	pushReady(t, RetryRequest{ID: "1", Method: "PATCH", URL: "http://cars/api/v1/cars/1"})
+
+	token, err := dead.Fetch(time.Second)
+	require.NoError(t, err)
+
+	alive := NewWorker()
+	require.NoError(t, alive.Register())
+
+	// Воркер "умер": heartbeat истёк
+	mr.FastForward(heartbeatTTL + time.Second)
+	require.NoError(t, alive.Heartbeat())
+
+	requeued, err := ReapDeadWorkers()
+	require.NoError(t, err)
+	assert.Equal(t, 1, requeued)
+
+	queued, _ := RedisClient.LRange(RedisCtx, RetryQueueKey, 0, -1).Result()
+	assert.Equal(t, []string{token}, queued)
+
+	workers, _ := RedisClient.SMembers(RedisCtx, RetryWorkersKey).Result()
+	assert.Equal(t, []string{alive.ID}, workers)
+}
+
+// Тест: воркер, снятый reaper после сбоя Redis, возвращается в набор со следующим heartbeat
+func TestWorker_Heartbeat_RestoresReapedWorker(t *testing.T) {
+	mr := setupRedis(t)
+
+	worker := NewWorker()
+	require.NoError(t, worker.Register())
+
+	// Heartbeat не доходил дольше heartbeatTTL
+	mr.FastForward(heartbeatTTL + time.Second)
+	_, err := ReapDeadWorkers()
+	require.NoError(t, err)
+
+	workers, _ := RedisClient.SMembers(RedisCtx, RetryWorkersKey).Result()
+	assert.Empty(t, workers)
+
+	require.NoError(t, worker.Heartbeat())
+
+	workers, _ = RedisClient.SMembers(RedisCtx, RetryWorkersKey).Result()
+	assert.Equal(t, []string{worker.ID}, workers)
+
+	// Его токены снова под защитой reaper: heartbeat есть, ничего не забирается
+	pushReady(t, RetryRequest{ID: "1", Method: "PATCH", URL: "http://cars/api/v1/cars/1"})
+	_, err = worker.Fetch(time.Second)
+	require.NoError(t, err)
+
+	requeued, err := ReapDeadWorkers()
+	require.NoError(t, err)
+	assert.Zero(t, requeued)
+
+	processing, _ := RedisClient.LLen(RedisCtx, worker.processingKey).Result()
+	assert.EqualValues(t, 1, processing)
+}
+
+// Тест: отложенные токены переносятся в очередь, когда подходит время
+func TestPromoteDue(t *testing.T) {
+	setupRedis(t)
+
+	require.NoError(t, EnqueueRetry(RetryRequest{Method: "PATCH", URL: "http://cars/api/v1/cars/1"}))
+
+	moved, err := promoteDue(time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, moved)
+
+	moved, err = promoteDue(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, moved)
+
+	queued, _ := RedisClient.LLen(RedisCtx, RetryQueueKey).Result()
+	assert.Equal(t, int64(1), queued)
+}
+
+// Тест: сообщение старого формата переносится в очередь ресурса
+func TestWorker_MigratesLegacyMessage(t *testing.T) {
+	setupRedis(t)
+
+	data, _ := json.Marshal(RetryRequest{Method: "PATCH", URL: "http://cars/api/v1/cars/1"})
+	require.NoError(t, RedisClient.RPush(RedisCtx, RetryQueueKey, data).Err())
+
+	worker := NewWorker()
+	raw, err := worker.Fetch(time.Second)
+	require.NoError(t, err)
+	require.NoError(t, worker.Handle(raw))
+
+	pending, _ := ListPending()
+	require.Len(t, pending, 1)
+	assert.Equal(t, "/api/v1/cars/1", pending[0].Request.Entity)
+}
+
+// Тест: экземпляр для повтора выбирается при каждой отправке, повтор не привязан к упавшему
+func TestWorker_Handle_PicksInstanceOnSend(t *testing.T) {
+	setupRedis(t)
+
+	var received string
+	alive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.URL.RequestURI()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(alive.Close)
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	dead.Close()
+
+	b := balancer.New("car", balancer.Config{Breaker: cb.Config{BufferSize: 1, FailureRate: 0.5, Timeout: time.Minute, MinimumCalls: 1}})
+	b.SetURLs([]string{dead.URL, alive.URL})
+	RegisterTargets("car", b)
+
+	worker := NewWorker()
+	pushReady(t, RetryRequest{ID: "1", Method: "DELETE", Service: "car", Path: "/cars/1/reservations?rentalUid=r-1"})
+
+	// Первая попытка уходит на упавший экземпляр
+	token, err := worker.Fetch(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "/cars/1/reservations", token)
+	require.NoError(t, worker.Handle(token))
+
+	_, err = promoteDue(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	token, err = worker.Fetch(time.Second)
+	require.NoError(t, err)
+	require.NoError(t, worker.Handle(token))
+
+	assert.Equal(t, "/cars/1/reservations?rentalUid=r-1", received)
+	pending, _ := ListPending()
+	assert.Empty(t, pending)
+}
+
+// Тест: запросы к одному ресурсу через разные экземпляры попадают в одну очередь
+func TestEntityOf_IgnoresHost(t *testing.T) {
+	assert.Equal(t, EntityOf("http://10.0.0.1:8070/api/v1/cars/1"), EntityOf("http://10.0.0.2:8070/api/v1/cars/1"))
+	assert.Equal(t, "/cars/1", EntityOf("/cars/1?rentalUid=r-1"))
+}
@@ -0,0 +1,136 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Локальная замена Redis для запуска без внешнего хранилища и тестов
+type MemoryStore struct {
+	mu    sync.Mutex
+	sagas map[string][]byte
+	refs  map[string]string
+	locks map[string]memoryLock
+}
+
+type memoryLock struct {
+	token string
+	until time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sagas: make(map[string][]byte),
+		refs:  make(map[string]string),
+		locks: make(map[string]memoryLock),
+	}
+}
+
+func (m *MemoryStore) Save(ctx context.Context, s *Saga) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sagas[s.ID] = data
+	if s.Ref != "" {
+		m.refs[s.Ref] = s.ID
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, id string) (*Saga, error) {
+	m.mu.Lock()
+	data, ok := m.sagas[id]
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, ErrorNotFound
+	}
+
+	var s Saga
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (m *MemoryStore) GetByRef(ctx context.Context, ref string) (*Saga, error) {
+	m.mu.Lock()
+	id, ok := m.refs[ref]
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, ErrorNotFound
+	}
+
+	return m.Get(ctx, id)
+}
+
+func (m *MemoryStore) ListActive(ctx context.Context) ([]*Saga, error) {
+	m.mu.Lock()
+	ids := make([]string, 0, len(m.sagas))
+	for id := range m.sagas {
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+
+	var sagas []*Saga
+	for _, id := range ids {
+		s, err := m.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !s.IsFinished() {
+			sagas = append(sagas, s)
+		}
+	}
+
+	return sagas, nil
+}
+
+func (m *MemoryStore) TryLock(ctx context.Context, id string, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if lock, ok := m.locks[id]; ok && time.Now().Before(lock.until) {
+		return "", nil
+	}
+
+	token := uuid.New().String()
+	m.locks[id] = memoryLock{token: token, until: time.Now().Add(ttl)}
+	return token, nil
+}
+
+func (m *MemoryStore) ExtendLock(ctx context.Context, id, token string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lock, ok := m.locks[id]
+	if !ok || lock.token != token || !time.Now().Before(lock.until) {
+		return false, nil
+	}
+
+	lock.until = time.Now().Add(ttl)
+	m.locks[id] = lock
+	return true, nil
+}
+
+func (m *MemoryStore) Unlock(ctx context.Context, id, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if lock, ok := m.locks[id]; ok && lock.token == token {
+		delete(m.locks, id)
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+)
+
+type StepFunc func(ctx context.Context, s *Saga) error
+
+// Компенсация должна быть идемпотентной: она вызывается и для шага,
+// результат которого неизвестен (STARTED или FAILED по таймауту)
+type Step struct {
+	Name       string
+	Action     StepFunc
+	Compensate StepFunc
+}
+
+type Definition struct {
+	Type  string
+	Steps []Step
+}
+
+type Orchestrator struct {
+	store       Store
+	definitions map[string]Definition
+	staleAfter  time.Duration
+}
+
+func NewOrchestrator(store Store, staleAfter time.Duration) *Orchestrator {
+	return &Orchestrator{
+		store:       store,
+		definitions: make(map[string]Definition),
+		staleAfter:  staleAfter,
+	}
+}
+
+func (o *Orchestrator) Register(def Definition) {
+	o.definitions[def.Type] = def
+}
+
+func (o *Orchestrator) Store() Store {
+	return o.store
+}
+
+// Создаёт сагу, сохраняет её в журнал и выполняет шаги.
+// При ошибке шага выполняются компенсации, возвращается исходная ошибка шага
+func (o *Orchestrator) Start(ctx context.Context, sagaType string, data map[string]string) (*Saga, error) {
+	def, ok := o.definitions[sagaType]
+	if !ok {
+		return nil, fmt.Errorf("unknown saga type %s", sagaType)
+	}
+
+	now := time.Now()
+	s := &Saga{
+		ID:        uuid.New().String(),
+		Type:      sagaType,
+		Status:    StatusRunning,
+		Data:      data,
+		Steps:     make([]StepRecord, len(def.Steps)),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	for i, step := range def.Steps {
+		s.Steps[i] = StepRecord{Name: step.Name, Status: StepPending, UpdatedAt: now}
+	}
+
+	// Без блокировки сагу может параллельно подхватить восстановление
+	token, err := o.store.TryLock(ctx, s.ID, o.staleAfter)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, ErrorLocked
+	}
+	defer o.store.Unlock(context.Background(), s.ID, token)
+
+	if err := o.save(ctx, s); err != nil {
+		return nil, err
+	}
+
+	return s, o.run(ctx, def, s, token)
+}
+
+// Шаг выполняется, только если его начало записано в журнал: иначе после
+// сбоя восстановление не узнает о побочном эффекте шага
+func (o *Orchestrator) run(ctx context.Context, def Definition, s *Saga, token string) error {
+	for i, step := range def.Steps {
+		if s.Steps[i].Status == StepDone {
+			continue
+		}
+
+		if err := o.extendLock(ctx, s, token); err != nil {
+			if errors.Is(err, ErrorLocked) {
+				sagaLogger(ctx, s).Error("saga lock lost, leaving saga to recovery", "step", step.Name)
+				return err
+			}
+			return o.fail(def, s, token, err)
+		}
+
+		s.setStep(i, StepStarted, nil)
+		if err := o.save(ctx, s); err != nil {
+			return o.fail(def, s, token, err)
+		}
+
+		if err := step.Action(ctx, s); err != nil {
+			sagaLogger(ctx, s).Warn("saga step failed", "step", step.Name, logging.Err(err))
+			s.setStep(i, StepFailed, err)
+			return o.fail(def, s, token, err)
+		}
+
+		s.setStep(i, StepDone, nil)
+		if err := o.save(ctx, s); err != nil {
+			return o.fail(def, s, token, err)
+		}
+	}
+
+	// Если статус не записался, восстановление завершит сагу по выполненным шагам
+	s.Status = StatusCompleted
+	o.save(ctx, s)
+
+	return nil
+}
+
+func (o *Orchestrator) fail(def Definition, s *Saga, token string, err error) error {
+	s.Error = err.Error()
+	o.compensate(context.Background(), def, s, token)
+	return err
+}
+
+// Блокировка живёт staleAfter и продлевается перед каждым шагом, чтобы
+// восстановление не подхватило медленную, но ещё выполняющуюся сагу
+func (o *Orchestrator) extendLock(ctx context.Context, s *Saga, token string) error {
+	extended, err := o.store.ExtendLock(ctx, s.ID, token, o.staleAfter)
+	if err != nil {
+		return err
+	}
+	if !extended {
+		return ErrorLocked
+	}
+	return nil
+}
+
+// Компенсации выполняются в обратном порядке для выполненных, начатых и
+// упавших шагов: ошибка вызова (таймаут, 5xx, обрыв соединения) не значит,
+// что сервис не успел сохранить результат. Если компенсация не удалась, сага остаётся в COMPENSATING и будет
+// доведена до конца при восстановлении
+func (o *Orchestrator) compensate(ctx context.Context, def Definition, s *Saga, token string) {
+	s.Status = StatusCompensating
+	o.save(ctx, s)
+
+	for i := len(def.Steps) - 1; i >= 0; i-- {
+		status := s.Steps[i].Status
+		if status != StepDone && status != StepStarted && status != StepFailed {
+			continue
+		}
+
+		// Сагу уже компенсирует восстановление
+		if err := o.extendLock(ctx, s, token); errors.Is(err, ErrorLocked) {
+			sagaLogger(ctx, s).Error("saga lock lost during compensation")
+			return
+		}
+
+		step := def.Steps[i]
+		if step.Compensate != nil {
+			if err := step.Compensate(ctx, s); err != nil {
+				sagaLogger(ctx, s).Error("saga compensation failed", "step", step.Name, logging.Err(err))
+				s.Steps[i].Error = err.Error()
+				o.save(ctx, s)
+				return
+			}
+		}
+
+		s.setStep(i, StepCompensated, nil)
+		o.save(ctx, s)
+	}
+
+	s.Status = StatusCompensated
+	o.save(ctx, s)
+}
+
+// Компенсации продолжаются и без журнала: они идемпотентны, и восстановление
+// повторит их по последнему записанному состоянию
+func (o *Orchestrator) save(ctx context.Context, s *Saga) error {
+	s.UpdatedAt = time.Now()
+
+	if err := o.store.Save(ctx, s); err != nil {
+		sagaLogger(ctx, s).Error("saga journal write failed", logging.Err(err))
+		return fmt.Errorf("%w: %w", ErrorJournal, err)
+	}
+
+	return nil
+}
+
+// Доводит до конца незавершённые саги, которые давно не обновлялись:
+// полностью выполненные помечаются завершёнными, остальные компенсируются
+func (o *Orchestrator) Recover(ctx context.Context) error {
+	sagas, err := o.store.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sagas {
+		if time.Since(s.UpdatedAt) < o.staleAfter {
+			continue
+		}
+
+		o.recoverOne(ctx, s.ID)
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) recoverOne(ctx context.Context, id string) {
+	token, err := o.store.TryLock(ctx, id, o.staleAfter)
+	if err != nil || token == "" {
+		return
+	}
+	defer o.store.Unlock(ctx, id, token)
+
+	s, err := o.store.Get(ctx, id)
+	if err != nil || s.IsFinished() {
+		return
+	}
+
+	def, ok := o.definitions[s.Type]
+	if !ok {
+		sagaLogger(ctx, s).Error("saga has unknown type")
+		return
+	}
+
+	if s.Status == StatusRunning && allStepsDone(s) {
+		sagaLogger(ctx, s).Info("saga recovered as completed")
+		s.Status = StatusCompleted
+		o.save(ctx, s)
+		return
+	}
+
+	sagaLogger(ctx, s).Warn("saga recovered, compensating", "saga_status", s.Status)
+	if s.Error == "" {
+		s.Error = "interrupted"
+	}
+	o.compensate(ctx, def, s, token)
+}
+
+func sagaLogger(ctx context.Context, s *Saga) *slog.Logger {
+	return logging.FromContext(ctx).With("saga_id", s.ID, "saga_type", s.Type)
+}
+
+func allStepsDone(s *Saga) bool {
+	for _, step := range s.Steps {
+		if step.Status != StepDone {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *Orchestrator) StartRecovery(interval time.Duration) {
+	go func() {
+		for {
+			if err := o.Recover(context.Background()); err != nil {
+				slog.Error("saga recovery error", logging.Err(err))
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
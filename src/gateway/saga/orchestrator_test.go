@@ -0,0 +1,275 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stepCalls struct {
+	actions       []string
+	compensations []string
+}
+
+func testDefinition(calls *stepCalls, failAt string) Definition {
+	step := func(name string) Step {
+		return Step{
+			Name: name,
+			Action: func(ctx context.Context, s *Saga) error {
+				calls.actions = append(calls.actions, name)
+				if name == failAt {
+					return errors.New(name + " failed")
+				}
+				s.Data[name] = "done"
+				return nil
+			},
+			Compensate: func(ctx context.Context, s *Saga) error {
+				calls.compensations = append(calls.compensations, name)
+				return nil
+			},
+		}
+	}
+
+	return Definition{Type: "test", Steps: []Step{step("first"), step("second"), step("third")}}
+}
+
+// Тест: все шаги выполнены, сага завершена
+func TestOrchestrator_Start_Success(t *testing.T) {
+	calls := &stepCalls{}
+	store := NewMemoryStore()
+	o := NewOrchestrator(store, time.Minute)
+	o.Register(testDefinition(calls, ""))
+
+	s, err := o.Start(context.Background(), "test", map[string]string{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCompleted, s.Status)
+	assert.Equal(t, []string{"first", "second", "third"}, calls.actions)
+	assert.Empty(t, calls.compensations)
+
+	saved, err := store.Get(context.Background(), s.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCompleted, saved.Status)
+	assert.Equal(t, "done", saved.Data["third"])
+}
+
+// Тест: ошибка шага компенсирует упавший и выполненные шаги в обратном порядке
+func TestOrchestrator_Start_StepFailure(t *testing.T) {
+	calls := &stepCalls{}
+	store := NewMemoryStore()
+	o := NewOrchestrator(store, time.Minute)
+	o.Register(testDefinition(calls, "third"))
+
+	s, err := o.Start(context.Background(), "test", map[string]string{})
+
+	assert.EqualError(t, err, "third failed")
+	assert.Equal(t, StatusCompensated, s.Status)
+	assert.Equal(t, []string{"third", "second", "first"}, calls.compensations)
+	assert.Equal(t, StepCompensated, s.Steps[2].Status)
+	assert.Equal(t, "third failed", s.Steps[2].Error)
+	assert.Equal(t, StepCompensated, s.Steps[0].Status)
+}
+
+// Тест: прерванная сага компенсируется при восстановлении, включая начатый шаг
+func TestOrchestrator_Recover_CompensatesInterrupted(t *testing.T) {
+	calls := &stepCalls{}
+	store := NewMemoryStore()
+	o := NewOrchestrator(store, time.Minute)
+	o.Register(testDefinition(calls, ""))
+
+	stale := time.Now().Add(-time.Hour)
+	s := &Saga{
+		ID:     "interrupted",
+		Type:   "test",
+		Status: StatusRunning,
+		Data:   map[string]string{},
+		Steps: []StepRecord{
+			{Name: "first", Status: StepDone},
+			{Name: "second", Status: StepStarted},
+			{Name: "third", Status: StepPending},
+		},
+		UpdatedAt: stale,
+	}
+	store.Save(context.Background(), s)
+
+	err := o.Recover(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"second", "first"}, calls.compensations)
+
+	recovered, _ := store.Get(context.Background(), "interrupted")
+	assert.Equal(t, StatusCompensated, recovered.Status)
+
+	active, _ := store.ListActive(context.Background())
+	assert.Empty(t, active)
+}
+
+// Тест: свежие саги не трогаются восстановлением
+func TestOrchestrator_Recover_SkipsFresh(t *testing.T) {
+	calls := &stepCalls{}
+	store := NewMemoryStore()
+	o := NewOrchestrator(store, time.Minute)
+	o.Register(testDefinition(calls, ""))
+
+	s := &Saga{
+		ID:        "fresh",
+		Type:      "test",
+		Status:    StatusRunning,
+		Data:      map[string]string{},
+		Steps:     []StepRecord{{Name: "first", Status: StepStarted}},
+		UpdatedAt: time.Now(),
+	}
+	store.Save(context.Background(), s)
+
+	o.Recover(context.Background())
+
+	assert.Empty(t, calls.compensations)
+	saved, _ := store.Get(context.Background(), "fresh")
+	assert.Equal(t, StatusRunning, saved.Status)
+}
+
+// Тест: сага со всеми выполненными шагами помечается завершённой
+func TestOrchestrator_Recover_CompletesFinishedSteps(t *testing.T) {
+	calls := &stepCalls{}
+	store := NewMemoryStore()
+	o := NewOrchestrator(store, time.Minute)
+	o.Register(testDefinition(calls, ""))
+
+	s := &Saga{
+		ID:     "done",
+		Type:   "test",
+		Status: StatusRunning,
+		Data:   map[string]string{},
+		Steps: []StepRecord{
+			{Name: "first", Status: StepDone},
+			{Name: "second", Status: StepDone},
+			{Name: "third", Status: StepDone},
+		},
+		UpdatedAt: time.Now().Add(-time.Hour),
+	}
+	store.Save(context.Background(), s)
+
+	o.Recover(context.Background())
+
+	assert.Empty(t, calls.compensations)
+	saved, _ := store.Get(context.Background(), "done")
+	assert.Equal(t, StatusCompleted, saved.Status)
+}
+
+// Хранилище, в котором блокировку уже держит кто-то другой
+type lockedStore struct {
+	*MemoryStore
+}
+
+func (lockedStore) TryLock(ctx context.Context, id string, ttl time.Duration) (string, error) {
+	return "", nil
+}
+
+// Тест: без блокировки сага не запускается и не попадает в журнал
+func TestOrchestrator_Start_NotLocked(t *testing.T) {
+	calls := &stepCalls{}
+	store := lockedStore{NewMemoryStore()}
+	o := NewOrchestrator(store, time.Minute)
+	o.Register(testDefinition(calls, ""))
+
+	s, err := o.Start(context.Background(), "test", map[string]string{})
+
+	assert.ErrorIs(t, err, ErrorLocked)
+	assert.Nil(t, s)
+	assert.Empty(t, calls.actions)
+
+	active, _ := store.ListActive(context.Background())
+	assert.Empty(t, active)
+}
+
+// Хранилище, в котором не удаётся одна запись журнала
+type failingSaveStore struct {
+	*MemoryStore
+	saves  int
+	failAt int
+}
+
+func (f *failingSaveStore) Save(ctx context.Context, s *Saga) error {
+	f.saves++
+	if f.saves == f.failAt {
+		return errors.New("journal unavailable")
+	}
+	return f.MemoryStore.Save(ctx, s)
+}
+
+// Тест: если завершение шага не записалось в журнал, сага компенсируется
+func TestOrchestrator_Start_JournalWriteFailure(t *testing.T) {
+	calls := &stepCalls{}
+	// 1 - создание саги, 2 - начало первого шага, 3 - его завершение
+	store := &failingSaveStore{MemoryStore: NewMemoryStore(), failAt: 3}
+	o := NewOrchestrator(store, time.Minute)
+	o.Register(testDefinition(calls, ""))
+
+	s, err := o.Start(context.Background(), "test", map[string]string{})
+
+	assert.ErrorIs(t, err, ErrorJournal)
+	assert.Equal(t, StatusCompensated, s.Status)
+	assert.Equal(t, []string{"first"}, calls.actions)
+	assert.Equal(t, []string{"first"}, calls.compensations)
+}
+
+// Хранилище, в котором блокировку перехватывают после первого продления
+type expiringLockStore struct {
+	*MemoryStore
+	extends int
+}
+
+func (e *expiringLockStore) ExtendLock(ctx context.Context, id, token string, ttl time.Duration) (bool, error) {
+	e.extends++
+	if e.extends > 1 {
+		return false, nil
+	}
+	return e.MemoryStore.ExtendLock(ctx, id, token, ttl)
+}
+
+// Тест: потеряв блокировку, сага останавливается и оставляет себя восстановлению
+func TestOrchestrator_Start_LockLost(t *testing.T) {
+	calls := &stepCalls{}
+	store := &expiringLockStore{MemoryStore: NewMemoryStore()}
+	o := NewOrchestrator(store, time.Minute)
+	o.Register(testDefinition(calls, ""))
+
+	s, err := o.Start(context.Background(), "test", map[string]string{})
+
+	assert.ErrorIs(t, err, ErrorLocked)
+	assert.Equal(t, []string{"first"}, calls.actions)
+	assert.Empty(t, calls.compensations)
+
+	saved, _ := store.Get(context.Background(), s.ID)
+	assert.Equal(t, StatusRunning, saved.Status)
+	assert.Equal(t, StepDone, saved.Steps[0].Status)
+}
+
+// Тест: блокировка принадлежит владельцу токена и продлевается только им
+func TestMemoryStore_LockToken(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	token, err := store.TryLock(ctx, "id", time.Minute)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, token)
+
+	other, _ := store.TryLock(ctx, "id", time.Minute)
+	assert.Empty(t, other)
+
+	extended, _ := store.ExtendLock(ctx, "id", "foreign", time.Minute)
+	assert.False(t, extended)
+	extended, _ = store.ExtendLock(ctx, "id", token, time.Minute)
+	assert.True(t, extended)
+
+	store.Unlock(ctx, "id", "foreign")
+	other, _ = store.TryLock(ctx, "id", time.Minute)
+	assert.Empty(t, other)
+
+	store.Unlock(ctx, "id", token)
+	other, _ = store.TryLock(ctx, "id", time.Minute)
+	assert.NotEmpty(t, other)
+}
@@ -0,0 +1,152 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	activeSagasKey = "saga:active"
+	// Сколько хранить завершённые саги
+	finishedSagaTTL = 7 * 24 * time.Hour
+)
+
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func sagaKey(id string) string {
+	return "saga:" + id
+}
+
+func refKey(ref string) string {
+	return "saga:ref:" + ref
+}
+
+func lockKey(id string) string {
+	return "saga:lock:" + id
+}
+
+func (r *RedisStore) Save(ctx context.Context, s *Saga) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	var ttl time.Duration
+	if s.IsFinished() {
+		ttl = finishedSagaTTL
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, sagaKey(s.ID), data, ttl)
+
+	if s.Ref != "" {
+		pipe.Set(ctx, refKey(s.Ref), s.ID, ttl)
+	}
+
+	if s.IsFinished() {
+		pipe.SRem(ctx, activeSagasKey, s.ID)
+	} else {
+		pipe.SAdd(ctx, activeSagasKey, s.ID)
+	}
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *RedisStore) Get(ctx context.Context, id string) (*Saga, error) {
+	data, err := r.client.Get(ctx, sagaKey(id)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrorNotFound
+		}
+		return nil, err
+	}
+
+	var s Saga
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (r *RedisStore) GetByRef(ctx context.Context, ref string) (*Saga, error) {
+	id, err := r.client.Get(ctx, refKey(ref)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrorNotFound
+		}
+		return nil, err
+	}
+
+	return r.Get(ctx, id)
+}
+
+func (r *RedisStore) ListActive(ctx context.Context) ([]*Saga, error) {
+	ids, err := r.client.SMembers(ctx, activeSagasKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sagas := make([]*Saga, 0, len(ids))
+	for _, id := range ids {
+		s, err := r.Get(ctx, id)
+		if errors.Is(err, ErrorNotFound) {
+			r.client.SRem(ctx, activeSagasKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sagas = append(sagas, s)
+	}
+
+	return sagas, nil
+}
+
+// Блокировку продлевает и снимает только её владелец: после истечения TTL
+// её мог взять процесс восстановления
+var extendLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (r *RedisStore) TryLock(ctx context.Context, id string, ttl time.Duration) (string, error) {
+	token := uuid.New().String()
+
+	locked, err := r.client.SetNX(ctx, lockKey(id), token, ttl).Result()
+	if err != nil || !locked {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (r *RedisStore) ExtendLock(ctx context.Context, id, token string, ttl time.Duration) (bool, error) {
+	extended, err := extendLockScript.Run(ctx, r.client, []string{lockKey(id)}, token, ttl.Milliseconds()).Int()
+	return extended == 1, err
+}
+
+func (r *RedisStore) Unlock(ctx context.Context, id, token string) error {
+	return unlockScript.Run(ctx, r.client, []string{lockKey(id)}, token).Err()
+}
@@ -0,0 +1,52 @@
+package saga
+
+import (
+	"time"
+)
+
+const (
+	StatusRunning      = "RUNNING"
+	StatusCompleted    = "COMPLETED"
+	StatusCompensating = "COMPENSATING"
+	StatusCompensated  = "COMPENSATED"
+)
+
+const (
+	StepPending     = "PENDING"
+	StepStarted     = "STARTED"
+	StepDone        = "DONE"
+	StepFailed      = "FAILED"
+	StepCompensated = "COMPENSATED"
+)
+
+type StepRecord struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type Saga struct {
+	ID        string            `json:"sagaUid"`
+	Type      string            `json:"type"`
+	Ref       string            `json:"ref,omitempty"`
+	Status    string            `json:"status"`
+	Data      map[string]string `json:"data"`
+	Steps     []StepRecord      `json:"steps"`
+	Error     string            `json:"error,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+func (s *Saga) IsFinished() bool {
+	return s.Status == StatusCompleted || s.Status == StatusCompensated
+}
+
+func (s *Saga) setStep(i int, status string, err error) {
+	s.Steps[i].Status = status
+	s.Steps[i].UpdatedAt = time.Now()
+
+	if err != nil {
+		s.Steps[i].Error = err.Error()
+	}
+}
@@ -0,0 +1,26 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrorNotFound = errors.New("saga not found")
+	ErrorLocked   = errors.New("saga is locked")
+	ErrorJournal  = errors.New("saga journal write failed")
+)
+
+// Журнал саг: каждое изменение шага сохраняется до и после вызова сервиса
+type Store interface {
+	Save(ctx context.Context, s *Saga) error
+	Get(ctx context.Context, id string) (*Saga, error)
+	GetByRef(ctx context.Context, ref string) (*Saga, error)
+	ListActive(ctx context.Context) ([]*Saga, error)
+	// Возвращает токен владельца блокировки, пустой - блокировку держит другой
+	TryLock(ctx context.Context, id string, ttl time.Duration) (string, error)
+	// Продлевает блокировку, если она ещё принадлежит владельцу токена
+	ExtendLock(ctx context.Context, id, token string, ttl time.Duration) (bool, error)
+	Unlock(ctx context.Context, id, token string) error
+}
@@ -1,9 +1,257 @@
 package services
 
+import (
+	"context"
+	"errors"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/clients"
+	cfg "github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/config"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/converters"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/models"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	RentalInProgress	= "IN_PROGRESS"
+	RentalFinished		= "FINISHED"
+	RentalCanceled		= "CANCELED"
+	PaymentCanceled		= "CANCELED"
+)
+
+var (
+	ErrRentalNotActive	= errors.New("rental is not active")
+)
+
 type GatewayService struct {
+	car		clients.CarClient
+	rental	clients.RentalClient
+	payment	clients.PaymentClient
+}
 
+func NewGatewayService(clients clients.Clients) *GatewayService {
+	return &GatewayService{
+		car: clients.Car,
+		rental: clients.Rental,
+		payment: clients.Payment,
+	}
 }
 
-func NewGatewayService() *GatewayService {
-	return &GatewayService{}
-}
\ No newline at end of file
+// Аренды пользователя. Без Car и Payment Service автомобили и оплаты
+// заполняются только идентификаторами и отмечаются в Degraded
+func (s *GatewayService) GetUserRentals(ctx context.Context, username string) ([]models.RentalResponse, models.Degradation, error) {
+	rentals, err := s.rental.GetRentals(ctx, username)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	carUIDs := make([]string, len(rentals))
+	paymentUIDs := make([]string, len(rentals))
+
+	for i, rental := range rentals {
+		carUIDs[i] = rental.CarUID
+		paymentUIDs[i] = rental.PaymentUID
+	}
+
+	var cars []models.ShortCarResponse
+	var payments []models.PaymentInfo
+	var carErr, paymentErr error
+
+	err = fanOut(ctx,
+		func(ctx context.Context) error {
+			if cars, carErr = s.car.GetCars(ctx, carUIDs); carErr != nil {
+				logging.FromContext(ctx).Warn("can't get cars, using fallback", logging.KeyDownstream, cfg.DownstreamCar, logging.Err(carErr))
+			}
+			return nil
+		},
+		func(ctx context.Context) error {
+			if payments, paymentErr = s.payment.GetPayments(ctx, paymentUIDs); paymentErr != nil {
+				logging.FromContext(ctx).Warn("can't get payments, using fallback", logging.KeyDownstream, cfg.DownstreamPayment, logging.Err(paymentErr))
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	carMap := make(map[string]models.CarInfo)
+	for _, car := range cars {
+		carMap[car.CarUID] = converters.ConvertToCarInfo(car)
+	}
+
+	paymentMap := make(map[string]models.PaymentInfo)
+	for _, payment := range payments {
+		paymentMap[payment.PaymentUID] = payment
+	}
+
+	response := make([]models.RentalResponse, len(rentals))
+	degradation := models.Degradation{}
+
+	for i, rental := range rentals {
+		var degraded []string
+
+		// Если сервис ответил, но не вернул запись, она не найдена
+		car, ok := carMap[rental.CarUID]
+		if !ok {
+			car = clients.CarFallback(rental.CarUID)
+			degraded = append(degraded, models.DegradedCar)
+			degradation[models.DegradedCar] = missingReason(carErr)
+		}
+
+		payment, ok := paymentMap[rental.PaymentUID]
+		if !ok {
+			payment = clients.PaymentFallback(rental.PaymentUID)
+			degraded = append(degraded, models.DegradedPayment)
+			degradation[models.DegradedPayment] = missingReason(paymentErr)
+		}
+
+		response[i] = converters.ConvertToRentalResponse(rental, car, payment)
+		response[i].Degraded = degraded
+	}
+
+	return response, degradation, nil
+}
+
+func missingReason(err error) string {
+	if err == nil {
+		return "not_found"
+	}
+	return clients.Reason(err)
+}
+
+// Аренда пользователя. Ответ 4xx от Car или Payment Service возвращается
+// как ошибка, при недоступности сервиса используется fallback
+func (s *GatewayService) GetUserRental(ctx context.Context, username, rentalUid string) (*models.RentalResponse, models.Degradation, error) {
+	rental, err := s.rental.GetRental(ctx, username, rentalUid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	car := clients.CarFallback(rental.CarUID)
+	payment := clients.PaymentFallback(rental.PaymentUID)
+	var carErr, paymentErr error
+
+	// Ответ 4xx отменяет второй вызов и возвращается клиенту
+	err = fanOut(ctx,
+		func(ctx context.Context) error {
+			carResponse, err := s.car.GetCar(ctx, rental.CarUID)
+			if _, ok := clients.ClientError(err); ok {
+				return err
+			}
+			if err != nil {
+				logging.FromContext(ctx).Warn("can't get car, using fallback", logging.KeyDownstream, cfg.DownstreamCar, logging.KeyCarUID, rental.CarUID, logging.Err(err))
+				carErr = err
+				return nil
+			}
+			car = converters.ConvertToCarInfo(*carResponse)
+			return nil
+		},
+		func(ctx context.Context) error {
+			paymentResponse, err := s.payment.GetPayment(ctx, rental.PaymentUID)
+			if _, ok := clients.ClientError(err); ok {
+				return err
+			}
+			if err != nil {
+				logging.FromContext(ctx).Warn("can't get payment, using fallback", logging.KeyDownstream, cfg.DownstreamPayment, logging.KeyPaymentUID, rental.PaymentUID, logging.Err(err))
+				paymentErr = err
+				return nil
+			}
+			payment = *paymentResponse
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response := converters.ConvertToRentalResponse(*rental, car, payment)
+	degradation := models.Degradation{}
+
+	if carErr != nil {
+		response.Degraded = append(response.Degraded, models.DegradedCar)
+		degradation[models.DegradedCar] = clients.Reason(carErr)
+	}
+	if paymentErr != nil {
+		response.Degraded = append(response.Degraded, models.DegradedPayment)
+		degradation[models.DegradedPayment] = clients.Reason(paymentErr)
+	}
+
+	return &response, degradation, nil
+}
+
+// Параллельные вызовы сервисов. Ошибка одного вызова отменяет остальные,
+// а если клиент отключился, результат не собирается
+func fanOut(ctx context.Context, calls ...func(ctx context.Context) error) error {
+	group, groupCtx := errgroup.WithContext(ctx)
+
+	for _, call := range calls {
+		group.Go(func() error {
+			return call(groupCtx)
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// Завершение аренды: автомобиль освобождается, аренда получает статус FINISHED.
+// Неудачные обновления откладываются в очередь повторов
+func (s *GatewayService) FinishRental(ctx context.Context, username, rentalUid string) error {
+	rental, err := s.activeRental(ctx, username, rentalUid)
+	if err != nil {
+		return err
+	}
+
+	s.releaseCar(ctx, rental)
+	s.setRentalStatus(ctx, username, rentalUid, RentalFinished)
+
+	return nil
+}
+
+// Отмена аренды: дополнительно отменяется оплата
+func (s *GatewayService) CancelRental(ctx context.Context, username, rentalUid string) error {
+	rental, err := s.activeRental(ctx, username, rentalUid)
+	if err != nil {
+		return err
+	}
+
+	s.releaseCar(ctx, rental)
+	s.setRentalStatus(ctx, username, rentalUid, RentalCanceled)
+
+	if err := s.payment.SetStatus(ctx, rental.PaymentUID, PaymentCanceled); err != nil {
+		s.payment.QueueSetStatus(ctx, rental.PaymentUID, PaymentCanceled)
+	}
+
+	return nil
+}
+
+func (s *GatewayService) activeRental(ctx context.Context, username, rentalUid string) (*models.RentalInfo, error) {
+	rental, err := s.rental.GetRental(ctx, username, rentalUid)
+	if err != nil {
+		return nil, err
+	}
+
+	if rental.Status != RentalInProgress {
+		logging.FromContext(ctx).Warn("rental is not active", "rental_status", rental.Status)
+		return nil, ErrRentalNotActive
+	}
+
+	return rental, nil
+}
+
+// Снимает бронь автомобиля, подтверждённую арендой
+func (s *GatewayService) releaseCar(ctx context.Context, rental *models.RentalInfo) {
+	if err := s.car.ReleaseRental(ctx, rental.CarUID, rental.RentalUID); err != nil {
+		s.car.QueueReleaseRental(ctx, rental.CarUID, rental.RentalUID)
+	}
+}
+
+func (s *GatewayService) setRentalStatus(ctx context.Context, username, rentalUid, status string) {
+	if _, err := s.rental.SetStatus(ctx, username, rentalUid, status); err != nil {
+		s.rental.QueueSetStatus(ctx, username, rentalUid, status)
+	}
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/clients"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/models"
+)
+
+var errUnavailable = &clients.CallError{Service: "test", Err: errors.New("connection refused")}
+
+type MockCarClient struct {
+	mock.Mock
+}
+
+func (m *MockCarClient) ListCars(_ context.Context, query url.Values) (*models.PaginationResponse, error) {
+	args := m.Called(query)
+	if page := args.Get(0); page != nil {
+		return page.(*models.PaginationResponse), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCarClient) GetCar(_ context.Context, uid string) (*models.ShortCarResponse, error) {
+	args := m.Called(uid)
+	if car := args.Get(0); car != nil {
+		return car.(*models.ShortCarResponse), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCarClient) GetCars(_ context.Context, uids []string) ([]models.ShortCarResponse, error) {
+	args := m.Called(uids)
+	if cars := args.Get(0); cars != nil {
+		return cars.([]models.ShortCarResponse), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCarClient) GetCalendar(_ context.Context, uid string, query url.Values) (*models.CarCalendar, error) {
+	args := m.Called(uid, query)
+	if calendar := args.Get(0); calendar != nil {
+		return calendar.(*models.CarCalendar), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCarClient) Reserve(_ context.Context, uid, dateFrom, dateTo string) (*models.ReservationResponse, error) {
+	args := m.Called(uid, dateFrom, dateTo)
+	if reservation := args.Get(0); reservation != nil {
+		return reservation.(*models.ReservationResponse), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCarClient) Confirm(_ context.Context, uid, reservationUid, rentalUid string) error {
+	return m.Called(uid, reservationUid, rentalUid).Error(0)
+}
+
+func (m *MockCarClient) Release(_ context.Context, uid, reservationUid string) error {
+	return m.Called(uid, reservationUid).Error(0)
+}
+
+func (m *MockCarClient) QueueRelease(_ context.Context, uid, reservationUid string) error {
+	return m.Called(uid, reservationUid).Error(0)
+}
+
+func (m *MockCarClient) ReleaseRental(_ context.Context, uid, rentalUid string) error {
+	return m.Called(uid, rentalUid).Error(0)
+}
+
+func (m *MockCarClient) QueueReleaseRental(_ context.Context, uid, rentalUid string) error {
+	return m.Called(uid, rentalUid).Error(0)
+}
+
+type MockRentalClient struct {
+	mock.Mock
+}
+
+func (m *MockRentalClient) GetRentals(_ context.Context, username string) ([]models.RentalInfo, error) {
+	args := m.Called(username)
+	if rentals := args.Get(0); rentals != nil {
+		return rentals.([]models.RentalInfo), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRentalClient) GetRental(_ context.Context, username, uid string) (*models.RentalInfo, error) {
+	args := m.Called(username, uid)
+	if rental := args.Get(0); rental != nil {
+		return rental.(*models.RentalInfo), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRentalClient) CreateRental(_ context.Context, rental models.RentCreation) (*models.RentalInfo, error) {
+	args := m.Called(rental)
+	if created := args.Get(0); created != nil {
+		return created.(*models.RentalInfo), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRentalClient) SetStatus(_ context.Context, username, uid, status string) (*models.RentalInfo, error) {
+	args := m.Called(username, uid, status)
+	if rental := args.Get(0); rental != nil {
+		return rental.(*models.RentalInfo), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRentalClient) QueueSetStatus(_ context.Context, username, uid, status string) error {
+	return m.Called(username, uid, status).Error(0)
+}
+
+type MockPaymentClient struct {
+	mock.Mock
+}
+
+func (m *MockPaymentClient) GetPayment(_ context.Context, uid string) (*models.PaymentInfo, error) {
+	args := m.Called(uid)
+	if payment := args.Get(0); payment != nil {
+		return payment.(*models.PaymentInfo), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentClient) GetPayments(_ context.Context, uids []string) ([]models.PaymentInfo, error) {
+	args := m.Called(uids)
+	if payments := args.Get(0); payments != nil {
+		return payments.([]models.PaymentInfo), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentClient) CreatePayment(_ context.Context, payment models.PaymentCreateRequest) (*models.PaymentCreationResponse, error) {
+	args := m.Called(payment)
+	if created := args.Get(0); created != nil {
+		return created.(*models.PaymentCreationResponse), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentClient) SetStatus(_ context.Context, uid, status string) error {
+	return m.Called(uid, status).Error(0)
+}
+
+func (m *MockPaymentClient) QueueSetStatus(_ context.Context, uid, status string) error {
+	return m.Called(uid, status).Error(0)
+}
+
+func newTestService() (*GatewayService, *MockCarClient, *MockRentalClient, *MockPaymentClient) {
+	car, rental, payment := new(MockCarClient), new(MockRentalClient), new(MockPaymentClient)
+	return NewGatewayService(clients.Clients{Car: car, Rental: rental, Payment: payment}), car, rental, payment
+}
+
+// Тест: аренды дополняются данными автомобилей и оплат
+func TestGatewayService_GetUserRentals(t *testing.T) {
+	service, car, rental, payment := newTestService()
+
+	rental.On("GetRentals", "user").Return([]models.RentalInfo{{RentalUID: "r-1", CarUID: "c-1", PaymentUID: "p-1"}}, nil)
+	car.On("GetCars", []string{"c-1"}).Return([]models.ShortCarResponse{{CarUID: "c-1", Brand: "Mercedes"}}, nil)
+	payment.On("GetPayments", []string{"p-1"}).Return([]models.PaymentInfo{{PaymentUID: "p-1", Status: "PAID", Price: 100}}, nil)
+
+	rentals, degradation, err := service.GetUserRentals(context.Background(), "user")
+
+	require.NoError(t, err)
+	require.Len(t, rentals, 1)
+	assert.Equal(t, "Mercedes", rentals[0].Car.Brand)
+	assert.Equal(t, 100, rentals[0].Payment.Price)
+	assert.Empty(t, rentals[0].Degraded)
+	assert.Empty(t, degradation)
+}
+
+// Тест: без Car и Payment Service используются fallback-значения, отмеченные в degraded
+func TestGatewayService_GetUserRentals_Fallback(t *testing.T) {
+	service, car, rental, payment := newTestService()
+
+	circuitOpen := &clients.CallError{Service: "payment", Err: clients.ErrCircuitOpen}
+	rental.On("GetRentals", "user").Return([]models.RentalInfo{{RentalUID: "r-1", CarUID: "c-1", PaymentUID: "p-1"}}, nil)
+	car.On("GetCars", []string{"c-1"}).Return(nil, errUnavailable)
+	payment.On("GetPayments", []string{"p-1"}).Return(nil, circuitOpen)
+
+	rentals, degradation, err := service.GetUserRentals(context.Background(), "user")
+
+	require.NoError(t, err)
+	require.Len(t, rentals, 1)
+	assert.Equal(t, clients.CarFallback("c-1"), rentals[0].Car)
+	assert.Equal(t, clients.PaymentFallback("p-1"), rentals[0].Payment)
+	assert.Equal(t, []string{models.DegradedCar, models.DegradedPayment}, rentals[0].Degraded)
+	assert.Equal(t, models.Degradation{models.DegradedCar: "unavailable", models.DegradedPayment: "circuit_open"}, degradation)
+}
+
+// Тест: запись, которую сервис не вернул, отмечается как не найденная
+func TestGatewayService_GetUserRentals_MissingCar(t *testing.T) {
+	service, car, rental, payment := newTestService()
+
+	rental.On("GetRentals", "user").Return([]models.RentalInfo{
+		{RentalUID: "r-1", CarUID: "c-1", PaymentUID: "p-1"},
+		{RentalUID: "r-2", CarUID: "c-2", PaymentUID: "p-2"},
+	}, nil)
+	car.On("GetCars", []string{"c-1", "c-2"}).Return([]models.ShortCarResponse{{CarUID: "c-1"}}, nil)
+	payment.On("GetPayments", []string{"p-1", "p-2"}).Return([]models.PaymentInfo{{PaymentUID: "p-1"}, {PaymentUID: "p-2"}}, nil)
+
+	rentals, degradation, err := service.GetUserRentals(context.Background(), "user")
+
+	require.NoError(t, err)
+	assert.Empty(t, rentals[0].Degraded)
+	assert.Equal(t, []string{models.DegradedCar}, rentals[1].Degraded)
+	assert.Equal(t, models.Degradation{models.DegradedCar: "not_found"}, degradation)
+}
+
+// Тест: автомобили и оплаты запрашиваются параллельно
+func TestGatewayService_GetUserRentals_Concurrent(t *testing.T) {
+	service, car, rental, payment := newTestService()
+
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	both := make(chan struct{})
+	go func() {
+		arrived.Wait()
+		close(both)
+	}()
+
+	waitBoth := func(mock.Arguments) {
+		arrived.Done()
+		select {
+			case <-both:
+			case <-time.After(time.Second):
+				t.Error("enrichment calls are not concurrent")
+		}
+	}
+
+	rental.On("GetRentals", "user").Return([]models.RentalInfo{{RentalUID: "r-1", CarUID: "c-1", PaymentUID: "p-1"}}, nil)
+	car.On("GetCars", []string{"c-1"}).Run(waitBoth).Return([]models.ShortCarResponse{{CarUID: "c-1"}}, nil)
+	payment.On("GetPayments", []string{"p-1"}).Run(waitBoth).Return([]models.PaymentInfo{{PaymentUID: "p-1"}}, nil)
+
+	_, _, err := service.GetUserRentals(context.Background(), "user")
+
+	require.NoError(t, err)
+}
+
+// Тест: если клиент отключился, fallback не собирается
+func TestGatewayService_GetUserRentals_Canceled(t *testing.T) {
+	service, car, rental, payment := newTestService()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rental.On("GetRentals", "user").Return([]models.RentalInfo{{RentalUID: "r-1", CarUID: "c-1", PaymentUID: "p-1"}}, nil)
+	car.On("GetCars", []string{"c-1"}).Return(nil, errUnavailable)
+	payment.On("GetPayments", []string{"p-1"}).Return(nil, errUnavailable)
+
+	_, _, err := service.GetUserRentals(ctx, "user")
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// Тест: ответ 4xx от Car Service не заменяется fallback
+func TestGatewayService_GetUserRental_CarNotFound(t *testing.T) {
+	service, car, rental, payment := newTestService()
+
+	notFound := &clients.StatusError{Service: "car", Status: http.StatusNotFound, Body: []byte(`{}`)}
+	rental.On("GetRental", "user", "r-1").Return(&models.RentalInfo{RentalUID: "r-1", CarUID: "c-1", PaymentUID: "p-1"}, nil)
+	car.On("GetCar", "c-1").Return(nil, notFound)
+	payment.On("GetPayment", "p-1").Return(&models.PaymentInfo{PaymentUID: "p-1"}, nil).Maybe()
+
+	_, _, err := service.GetUserRental(context.Background(), "user", "r-1")
+
+	assert.ErrorIs(t, err, clients.ErrNotFound)
+}
+
+// Тест: неудачные обновления при отмене аренды откладываются в очередь
+func TestGatewayService_CancelRental_QueuesFailedUpdates(t *testing.T) {
+	service, car, rental, payment := newTestService()
+
+	rental.On("GetRental", "user", "r-1").Return(&models.RentalInfo{RentalUID: "r-1", CarUID: "c-1", PaymentUID: "p-1", DateFrom: "2021-10-08", DateTo: "2021-10-11", Status: RentalInProgress}, nil)
+	car.On("ReleaseRental", "c-1", "r-1").Return(errUnavailable)
+	car.On("QueueReleaseRental", "c-1", "r-1").Return(nil)
+	rental.On("SetStatus", "user", "r-1", RentalCanceled).Return(&models.RentalInfo{}, nil)
+	payment.On("SetStatus", "p-1", PaymentCanceled).Return(errUnavailable)
+	payment.On("QueueSetStatus", "p-1", PaymentCanceled).Return(nil)
+
+	err := service.CancelRental(context.Background(), "user", "r-1")
+
+	require.NoError(t, err)
+	car.AssertExpectations(t)
+	payment.AssertExpectations(t)
+	rental.AssertNotCalled(t, "QueueSetStatus", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Тест: завершённую аренду нельзя завершить повторно
+func TestGatewayService_FinishRental_NotActive(t *testing.T) {
+	service, car, rental, _ := newTestService()
+
+	rental.On("GetRental", "user", "r-1").Return(&models.RentalInfo{RentalUID: "r-1", Status: RentalFinished}, nil)
+
+	err := service.FinishRental(context.Background(), "user", "r-1")
+
+	assert.ErrorIs(t, err, ErrRentalNotActive)
+	car.AssertNotCalled(t, "ReleaseRental", mock.Anything, mock.Anything)
+}
+
+// Тест: завершение аренды снимает бронь, подтверждённую этой арендой
+func TestGatewayService_FinishRental_ReleasesReservation(t *testing.T) {
+	service, car, rental, _ := newTestService()
+
+	rental.On("GetRental", "user", "r-1").Return(&models.RentalInfo{RentalUID: "r-1", CarUID: "c-1", DateFrom: "2021-10-08", DateTo: "2021-10-11", Status: RentalInProgress}, nil)
+	car.On("ReleaseRental", "c-1", "r-1").Return(nil)
+	rental.On("SetStatus", "user", "r-1", RentalFinished).Return(&models.RentalInfo{}, nil)
+
+	err := service.FinishRental(context.Background(), "user", "r-1")
+
+	require.NoError(t, err)
+	car.AssertExpectations(t)
+	car.AssertNotCalled(t, "QueueReleaseRental", mock.Anything, mock.Anything)
+}
@@ -1,19 +1,25 @@
 package services
 
 import (
+	"context"
 
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/clients"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/gateway/models"
 )
 
 type IGatewayService interface {
-
+	GetUserRentals(ctx context.Context, username string) ([]models.RentalResponse, models.Degradation, error)
+	GetUserRental(ctx context.Context, username, rentalUid string) (*models.RentalResponse, models.Degradation, error)
+	FinishRental(ctx context.Context, username, rentalUid string) error
+	CancelRental(ctx context.Context, username, rentalUid string) error
 }
 
 type Services struct {
 	IGatewayService
 }
 
-func NewServices() *Services {
+func NewServices(clients clients.Clients) *Services {
 	return &Services{
-		IGatewayService: NewGatewayService(),
+		IGatewayService: NewGatewayService(clients),
 	}
-}
\ No newline at end of file
+}
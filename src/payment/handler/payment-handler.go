@@ -21,7 +21,7 @@ func (h *PaymentHandler) GetPaymentByUid(ctx *gin.Context) {
 		return
 	}
 
-	payment, err := h.services.GetPaymentByUid(paymentUid)
+	payment, err := h.services.GetPaymentByUid(ctx.Request.Context(), paymentUid)
 
 	if err != nil {
 		if errors.Is(err, models.ErrorNotFound) {
@@ -47,7 +47,7 @@ func (h *PaymentHandler) GetPaymensBatch(ctx *gin.Context) {
 		return
 	}
 
-	payments, err := h.services.GetPaymentsByUids(req.UIDs)
+	payments, err := h.services.GetPaymentsByUids(ctx.Request.Context(), req.UIDs)
 
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: err.Error()})
@@ -72,7 +72,7 @@ func (h *PaymentHandler) UpdatePayment(ctx *gin.Context) {
 		return
 	}
 
-	payment, err := h.services.UpdatePayment(req, paymentUid)
+	payment, err := h.services.UpdatePayment(ctx.Request.Context(), req, paymentUid)
 
 	if err != nil {
 		if err == models.ErrorNotFound {
@@ -96,6 +96,13 @@ func (h *PaymentHandler) CreatePayment(ctx *gin.Context) {
 		return
 	}
 
+	if req.PaymentUID != "" {
+		if _, err := uuid.Parse(req.PaymentUID); err != nil {
+			ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "PaymentUid must be valid"})
+			return
+		}
+	}
+
 	dateFrom, err := time.Parse("2006-01-02", req.DateFrom)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Error with parsing time from date-from"})
@@ -108,7 +115,8 @@ func (h *PaymentHandler) CreatePayment(ctx *gin.Context) {
 		return
 	}
 
-	payment, err := h.services.CreatePayment(models.PaymentCreate{
+	payment, err := h.services.CreatePayment(ctx.Request.Context(), models.PaymentCreate{
+		PaymentUID: req.PaymentUID,
 		DateFrom: dateFrom,
 		DateTo:   dateTo,
 	})
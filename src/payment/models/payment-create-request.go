@@ -1,6 +1,8 @@
 package models
 
 type PaymentCreateRequest struct {
+	// Необязательный: по нему повтор создания возвращает уже созданную оплату
+	PaymentUID	string `json:"paymentUid,omitempty"`
 	DateFrom	string `json:"dateFrom"`
 	DateTo		string `json:"dateTo"`
 }
\ No newline at end of file
@@ -3,6 +3,7 @@ package models
 import "time"
 
 type PaymentCreate struct {
+	PaymentUID	string	`json:"paymentUid"`
 	DateFrom	time.Time `json:"dateFrom"`
 	DateTo		time.Time `json:"dateTo"`
 }
\ No newline at end of file
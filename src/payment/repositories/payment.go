@@ -1,10 +1,12 @@
 package repositories
 
 import (
+	"context"
 	"errors"
 
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/payment/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PaymentPostgres struct {
@@ -15,10 +17,10 @@ func NewPaymentPostgres(db *gorm.DB) *PaymentPostgres {
 	return &PaymentPostgres{DB: db}
 }
 
-func (r *PaymentPostgres) GetPaymentByUid(uid string) (*models.PaymentResponse, error) {
+func (r *PaymentPostgres) GetPaymentByUid(ctx context.Context, uid string) (*models.PaymentResponse, error) {
 	var payment models.PaymentResponse
 
-	if err := r.DB.Select("payment_uid", "status", "price").Where("payment_uid = ?", uid).First(&payment).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Select("payment_uid", "status", "price").Where("payment_uid = ?", uid).First(&payment).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, models.ErrorNotFound
 		}
@@ -29,18 +31,18 @@ func (r *PaymentPostgres) GetPaymentByUid(uid string) (*models.PaymentResponse,
 	return &payment, nil
 }
 
-func (r *PaymentPostgres) GetPaymentsByUids(uids []string) ([]models.PaymentResponse, error) {
+func (r *PaymentPostgres) GetPaymentsByUids(ctx context.Context, uids []string) ([]models.PaymentResponse, error) {
 	var payments []models.PaymentResponse
 
-	if err := r.DB.Select("payment_uid", "status", "price").Where("payment_uid IN ?", uids).Find(&payments).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Select("payment_uid", "status", "price").Where("payment_uid IN ?", uids).Find(&payments).Error; err != nil {
 		return nil, err
 	}
 
 	return payments, nil
 }
 
-func (r *PaymentPostgres) UpdatePayment(payment models.PaymentUpsert, uid string) (*models.PaymentResponse, error) {
-	result := r.DB.Model(&models.Payment{}).
+func (r *PaymentPostgres) UpdatePayment(ctx context.Context, payment models.PaymentUpsert, uid string) (*models.PaymentResponse, error) {
+	result := r.DB.WithContext(ctx).Model(&models.Payment{}).
 				Where("payment_uid = ?", uid).
 				Update("status", payment.Status)
 	
@@ -54,7 +56,7 @@ func (r *PaymentPostgres) UpdatePayment(payment models.PaymentUpsert, uid string
 
 	var updatedPayment models.PaymentResponse
 
-	if err := r.DB.Select("payment_uid", "status", "price").Where("payment_uid = ?", uid).First(&updatedPayment).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Select("payment_uid", "status", "price").Where("payment_uid = ?", uid).First(&updatedPayment).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, models.ErrorNotFound
 		}
@@ -65,6 +67,18 @@ func (r *PaymentPostgres) UpdatePayment(payment models.PaymentUpsert, uid string
 	return &updatedPayment, nil
 }
 
-func (r *PaymentPostgres) CreatePayment(payment models.Payment) (error) {
-	return r.DB.Create(&payment).Error
+func (r *PaymentPostgres) CreatePayment(ctx context.Context, payment models.Payment) (error) {
+	result := r.DB.WithContext(ctx).
+				Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "payment_uid"}}, DoNothing: true}).
+				Create(&payment)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return models.ErrorAlreadyExists
+	}
+
+	return nil
 }
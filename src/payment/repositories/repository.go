@@ -1,16 +1,18 @@
 package repositories
 
 import (
+	"context"
+
 	"gorm.io/gorm"
 
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/payment/models"
 )
 
 type IPaymentRepo interface {
-	GetPaymentByUid(string) (*models.PaymentResponse, error)
-	GetPaymentsByUids(uids []string) ([]models.PaymentResponse, error)
-	UpdatePayment(models.PaymentUpsert, string) (*models.PaymentResponse, error)
-	CreatePayment(payment models.Payment) (error)
+	GetPaymentByUid(context.Context, string) (*models.PaymentResponse, error)
+	GetPaymentsByUids(ctx context.Context, uids []string) ([]models.PaymentResponse, error)
+	UpdatePayment(context.Context, models.PaymentUpsert, string) (*models.PaymentResponse, error)
+	CreatePayment(ctx context.Context, payment models.Payment) (error)
 }
 
 type Repository struct {
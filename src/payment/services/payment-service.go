@@ -1,6 +1,8 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"math"
 	"time"
 
@@ -17,15 +19,15 @@ func NewPaymentService(repo repo.IPaymentRepo) *PaymentService {
 	return &PaymentService{repo: repo}
 }
 
-func (s *PaymentService) GetPaymentByUid(uid string) (*models.PaymentResponse, error) {
-	return s.repo.GetPaymentByUid(uid)
+func (s *PaymentService) GetPaymentByUid(ctx context.Context, uid string) (*models.PaymentResponse, error) {
+	return s.repo.GetPaymentByUid(ctx, uid)
 }
 
-func (s *PaymentService) GetPaymentsByUids(uids []string) ([]models.PaymentResponse, error) {
-	return s.repo.GetPaymentsByUids(uids)
+func (s *PaymentService) GetPaymentsByUids(ctx context.Context, uids []string) ([]models.PaymentResponse, error) {
+	return s.repo.GetPaymentsByUids(ctx, uids)
 }
 
-func (s *PaymentService) UpdatePayment(payment models.PaymentUpsert, uid string) (*models.PaymentResponse, error) {
+func (s *PaymentService) UpdatePayment(ctx context.Context, payment models.PaymentUpsert, uid string) (*models.PaymentResponse, error) {
 	validStatuses := map[string]bool{
         "PAID": true,
         "CANCELED":    true,
@@ -35,28 +37,40 @@ func (s *PaymentService) UpdatePayment(payment models.PaymentUpsert, uid string)
         return nil, models.InvalidStatus
     }
 
-	return s.repo.UpdatePayment(payment, uid)
+	return s.repo.UpdatePayment(ctx, payment, uid)
 }
 
-func (s *PaymentService) CreatePayment(paymentInsert models.PaymentCreate) (*models.PaymentResponse, error) {
+func (s *PaymentService) CreatePayment(ctx context.Context, paymentInsert models.PaymentCreate) (*models.PaymentResponse, error) {
 	duration := paymentInsert.DateTo.Sub(paymentInsert.DateFrom)
 	days := int(math.Round(duration.Round(time.Hour).Hours() / 24))
 
+	paymentUid := paymentInsert.PaymentUID
+	if paymentUid == "" {
+		paymentUid = uuid.New().String()
+	}
+
 	payment := models.Payment{
-		PaymentUID: uuid.New().String(),
+		PaymentUID: paymentUid,
 		Status: "PAID",
 		Price: models.DayCost * days,
 	}
 
-	if err := s.repo.CreatePayment(payment); err == nil {
-		response := models.PaymentResponse{
-			PaymentUID: payment.PaymentUID,
-			Status: payment.Status,
-			Price: payment.Price,
-		}
+	err := s.repo.CreatePayment(ctx, payment)
+
+	// Повтор с тем же uid: оплата уже создана, возвращается как есть
+	if errors.Is(err, models.ErrorAlreadyExists) {
+		return s.repo.GetPaymentByUid(ctx, paymentUid)
+	}
 
-		return &response, nil
-	} else {
+	if err != nil {
 		return nil, err
 	}
+
+	response := models.PaymentResponse{
+		PaymentUID: payment.PaymentUID,
+		Status: payment.Status,
+		Price: payment.Price,
+	}
+
+	return &response, nil
 }
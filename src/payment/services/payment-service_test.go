@@ -1,20 +1,21 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
 
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/payment/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-	"github.com/SwanPoi/bmstu_rsoi_lab2/src/payment/models"
 )
 
 type MockPaymentRepository struct {
 	mock.Mock
 }
 
-func (m *MockPaymentRepository) GetPaymentByUid(uid string) (*models.PaymentResponse, error) {
+func (m *MockPaymentRepository) GetPaymentByUid(_ context.Context, uid string) (*models.PaymentResponse, error) {
 	args := m.Called(uid)
 	if payment := args.Get(0); payment != nil {
 		return payment.(*models.PaymentResponse), args.Error(1)
@@ -22,12 +23,12 @@ func (m *MockPaymentRepository) GetPaymentByUid(uid string) (*models.PaymentResp
 	return nil, args.Error(1)
 }
 
-func (m *MockPaymentRepository) GetPaymentsByUids(uids []string) ([]models.PaymentResponse, error) {
+func (m *MockPaymentRepository) GetPaymentsByUids(_ context.Context, uids []string) ([]models.PaymentResponse, error) {
 	args := m.Called(uids)
 	return args.Get(0).([]models.PaymentResponse), args.Error(1)
 }
 
-func (m *MockPaymentRepository) UpdatePayment(payment models.PaymentUpsert, uid string) (*models.PaymentResponse, error) {
+func (m *MockPaymentRepository) UpdatePayment(_ context.Context, payment models.PaymentUpsert, uid string) (*models.PaymentResponse, error) {
 	args := m.Called(payment, uid)
 	if response := args.Get(0); response != nil {
 		return response.(*models.PaymentResponse), args.Error(1)
@@ -35,7 +36,7 @@ func (m *MockPaymentRepository) UpdatePayment(payment models.PaymentUpsert, uid
 	return nil, args.Error(1)
 }
 
-func (m *MockPaymentRepository) CreatePayment(payment models.Payment) error {
+func (m *MockPaymentRepository) CreatePayment(_ context.Context, payment models.Payment) error {
 	args := m.Called(payment)
 	return args.Error(0)
 }
@@ -54,7 +55,7 @@ func TestPaymentService_GetPaymentByUid_Success(t *testing.T) {
 
 	mockRepo.On("GetPaymentByUid", uid).Return(expectedPayment, nil)
 
-	payment, err := service.GetPaymentByUid(uid)
+	payment, err := service.GetPaymentByUid(context.Background(), uid)
 
 	assert.Nil(t, err)
 	assert.Equal(t, expectedPayment, payment)
@@ -71,7 +72,7 @@ func TestPaymentService_GetPaymentByUid_Error(t *testing.T) {
 
 	mockRepo.On("GetPaymentByUid", uid).Return((*models.PaymentResponse)(nil), expectedError)
 
-	_, err := service.GetPaymentByUid(uid)
+	_, err := service.GetPaymentByUid(context.Background(), uid)
 
 	assert.True(t, errors.Is(err, expectedError))
 	mockRepo.AssertExpectations(t)
@@ -90,7 +91,7 @@ func TestPaymentService_GetPaymentsByUids_Success(t *testing.T) {
 
 	mockRepo.On("GetPaymentsByUids", uids).Return(expectedPayments, nil)
 
-	payments, err := service.GetPaymentsByUids(uids)
+	payments, err := service.GetPaymentsByUids(context.Background(), uids)
 
 	assert.Nil(t, err)
 	assert.Equal(t, expectedPayments, payments)
@@ -107,7 +108,7 @@ func TestPaymentService_GetPaymentsByUids_Error(t *testing.T) {
 
 	mockRepo.On("GetPaymentsByUids", uids).Return([]models.PaymentResponse{}, expectedError)
 
-	_, err := service.GetPaymentsByUids(uids)
+	_, err := service.GetPaymentsByUids(context.Background(), uids)
 
 	assert.True(t, errors.Is(err, expectedError))
 	mockRepo.AssertExpectations(t)
@@ -123,7 +124,7 @@ func TestPaymentService_UpdatePayment_InvalidStatus(t *testing.T) {
 	}
 	uid := "test-uid"
 
-	_, err := service.UpdatePayment(paymentUpsert, uid)
+	_, err := service.UpdatePayment(context.Background(), paymentUpsert, uid)
 
 	assert.True(t, errors.Is(err, models.InvalidStatus))
 	mockRepo.AssertExpectations(t)
@@ -146,7 +147,7 @@ func TestPaymentService_UpdatePayment_Success(t *testing.T) {
 
 	mockRepo.On("UpdatePayment", paymentUpsert, uid).Return(expectedResponse, nil)
 
-	response, err := service.UpdatePayment(paymentUpsert, uid)
+	response, err := service.UpdatePayment(context.Background(), paymentUpsert, uid)
 
 	assert.Nil(t, err)
 	assert.Equal(t, expectedResponse, response)
@@ -181,7 +182,7 @@ func TestPaymentService_CreatePayment_Success_TwoDays(t *testing.T) {
 			payment.PaymentUID != ""
 	})).Return(nil)
 
-	response, err := service.CreatePayment(paymentCreate)
+	response, err := service.CreatePayment(context.Background(), paymentCreate)
 
 	assert.Nil(t, err)
 	assert.Equal(t, "PAID", response.Status)
@@ -210,8 +211,36 @@ func TestPaymentService_CreatePayment_RepoError(t *testing.T) {
 	expectedError := errors.New("database error")
 	mockRepo.On("CreatePayment", mock.Anything).Return(expectedError)
 
-	_, err := service.CreatePayment(paymentCreate)
+	_, err := service.CreatePayment(context.Background(), paymentCreate)
 
 	assert.True(t, errors.Is(err, expectedError))
 	mockRepo.AssertExpectations(t)
-}
\ No newline at end of file
+}
+
+// Тест: повторное создание с тем же uid возвращает уже созданную оплату
+func TestPaymentService_CreatePayment_SameUidReturnsExisting(t *testing.T) {
+	mockRepo := new(MockPaymentRepository)
+	service := NewPaymentService(mockRepo)
+
+	uid := "7f1c2e4a-5b6d-4e8f-9a0b-1c2d3e4f5a6b"
+	dateFrom := time.Now().Truncate(24 * time.Hour)
+
+	paymentCreate := models.PaymentCreate{
+		PaymentUID: uid,
+		DateFrom:   dateFrom,
+		DateTo:     dateFrom.Add(24 * time.Hour),
+	}
+
+	existing := &models.PaymentResponse{PaymentUID: uid, Status: "CANCELED", Price: 3500}
+
+	mockRepo.On("CreatePayment", mock.MatchedBy(func(payment models.Payment) bool {
+		return payment.PaymentUID == uid
+	})).Return(models.ErrorAlreadyExists)
+	mockRepo.On("GetPaymentByUid", uid).Return(existing, nil)
+
+	response, err := service.CreatePayment(context.Background(), paymentCreate)
+
+	assert.Nil(t, err)
+	assert.Equal(t, existing, response)
+	mockRepo.AssertExpectations(t)
+}
@@ -1,15 +1,17 @@
 package services
 
 import (
+	"context"
+
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/payment/models"
 	repo "github.com/SwanPoi/bmstu_rsoi_lab2/src/payment/repositories"
 )
 
 type IPaymentService interface {
-	GetPaymentByUid(string) (*models.PaymentResponse, error)
-	GetPaymentsByUids(uids []string) ([]models.PaymentResponse, error)
-	UpdatePayment(models.PaymentUpsert, string) (*models.PaymentResponse, error)
-	CreatePayment(payment models.PaymentCreate) (*models.PaymentResponse, error)
+	GetPaymentByUid(context.Context, string) (*models.PaymentResponse, error)
+	GetPaymentsByUids(ctx context.Context, uids []string) ([]models.PaymentResponse, error)
+	UpdatePayment(context.Context, models.PaymentUpsert, string) (*models.PaymentResponse, error)
+	CreatePayment(ctx context.Context, payment models.PaymentCreate) (*models.PaymentResponse, error)
 }
 
 type Services struct {
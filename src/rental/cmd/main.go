@@ -1,11 +1,15 @@
 package main
 
 import (
-	"log"
+	"log/slog"
+	"os"
 
+	logging "github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+	metrics "github.com/SwanPoi/bmstu_rsoi_lab2/src/common/metrics"
+	tracing "github.com/SwanPoi/bmstu_rsoi_lab2/src/common/tracing"
+	config "github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/config"
 	handler "github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/handler"
 	models "github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/models"
-	config "github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/config"
 	repo "github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/repositories"
 	server "github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/server"
 	services "github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/services"
@@ -14,6 +18,10 @@ import (
 func main() {
 	cfg := config.Load()
 
+	logging.Init("rental", cfg.LogLevel)
+	tracing.Init("rental", cfg.TraceExporter, cfg.OTLPEndpoint)
+	metrics.Init("rental")
+
 	connString := repo.GetConnectionString(&repo.DatabaseConfig{
 		Host: cfg.DBHost,
 		Port: cfg.DBPort,
@@ -25,11 +33,11 @@ func main() {
 	db, err := repo.InitDb(connString)
 
 	if err != nil {
-		log.Fatal("Fail during db connection", err)
-		return
+		slog.Error("fail during db connection", "error", err)
+		os.Exit(1)
 	}
 
-	log.Print("Successfully connect to database")
+	slog.Info("successfully connected to database")
 	db.AutoMigrate(&models.Rental{})
 
 	repos := repo.NewRepository(db)
@@ -39,7 +47,7 @@ func main() {
 	srv := new(server.CommonServer)
 
 	if err := srv.Run(cfg.Addr(), handler.SetupRoutes()); err != nil {
-		log.Fatal("Fail during rental server start", err)
-		return
+		slog.Error("fail during rental server start", "error", err)
+		os.Exit(1)
 	}
 }
\ No newline at end of file
@@ -13,6 +13,11 @@ type Config struct {
 	DBUser			string
 	DBPassword		string
 	DBName			string
+	// none, stdout (OTLP JSON построчно) или otlp (OTLP/HTTP коллектор)
+	TraceExporter	string
+	OTLPEndpoint	string
+	// debug, info, warn или error
+	LogLevel		string
 }
 
 func Load() Config {
@@ -24,6 +29,9 @@ func Load() Config {
 		DBPassword:		getenv("DB_PASSWORD", "postgres"),
 		DBUser: 		getenv("DB_USER", "postgres"),
 		DBName: 		getenv("DB_NAME", "rentals"),
+		TraceExporter:	getenv("TRACE_EXPORTER", "none"),
+		OTLPEndpoint:	getenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318"),
+		LogLevel:		getenv("LOG_LEVEL", "info"),
 	}
 }
 
@@ -5,6 +5,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/deadline"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/metrics"
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/tracing"
 	services "github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/services"
 )
 
@@ -18,10 +22,12 @@ func NewHandler(services *services.Services) *RentalHandler {
 
 func (h *RentalHandler) SetupRoutes() *gin.Engine {
 	router := gin.New()
+	router.Use(tracing.Middleware(), logging.Middleware(), metrics.Middleware(), deadline.Middleware(deadline.Budgets{}))
 
 	router.GET("/manage/health", func (c *gin.Context) {
 		c.Status(http.StatusOK)
 	})
+	router.GET("/manage/metrics", metrics.Handler())
 
 	api := router.Group("/api/v1") 
 	{
@@ -2,10 +2,10 @@ package handler
 
 import (
 	"errors"
-	"log"
 	"net/http"
 	"time"
 
+	"github.com/SwanPoi/bmstu_rsoi_lab2/src/common/logging"
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -14,15 +14,15 @@ import (
 func (h *RentalHandler) GetUserRentals(ctx *gin.Context) {
 	username := ctx.GetHeader("X-User-Name")
 	if username == "" {
-		log.Println("Need X-User-Name for rentals")
+		logging.FromContext(ctx.Request.Context()).Warn("X-User-Name header is required")
 		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "X-User-Name header is required"})
 		return
 	}
 
-	rentals, err := h.services.GetUserRentals(username)
+	rentals, err := h.services.GetUserRentals(ctx.Request.Context(), username)
 
 	if err != nil {
-		log.Println("Can't get rental from table, ", err.Error())
+		logging.FromContext(ctx.Request.Context()).Error("can't get rentals", logging.Err(err))
 		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: err.Error()})
 		return
 	}
@@ -36,7 +36,7 @@ func (h *RentalHandler) GetUserRentals(ctx *gin.Context) {
 func (h *RentalHandler) GetUserRentalByUid(ctx *gin.Context) {
 	username := ctx.GetHeader("X-User-Name")
 	if username == "" {
-		log.Println("Need X-User-Name for rental")
+		logging.FromContext(ctx.Request.Context()).Warn("X-User-Name header is required")
 		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "X-User-Name header is required"})
 		return
 	}
@@ -44,15 +44,15 @@ func (h *RentalHandler) GetUserRentalByUid(ctx *gin.Context) {
 	rentalUid := ctx.Param("uid")
 
 	if _, err := uuid.Parse(rentalUid); err != nil {
-		log.Println("Need uid for rental")
+		logging.FromContext(ctx.Request.Context()).Warn("rental uid must be valid", logging.KeyRentalUID, rentalUid)
 		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "RentalUid must be valid"})
 		return
 	}
 
-	rental, err := h.services.GetUserRentalByUid(rentalUid, username)
+	rental, err := h.services.GetUserRentalByUid(ctx.Request.Context(), rentalUid, username)
 
 	if err != nil {
-		log.Println("Can't get rental by id = " + rentalUid, ", ", err.Error())
+		logging.FromContext(ctx.Request.Context()).Warn("can't get rental", logging.KeyRentalUID, rentalUid, logging.Err(err))
 		if errors.Is(err, models.ErrorNotFound) {
 			message := "Rental with rental_uid = " + rentalUid + " is not found"
 			ctx.JSON(http.StatusNotFound, models.ErrorResponse{Message: message})
@@ -72,7 +72,7 @@ func (h *RentalHandler) CreateRental(ctx *gin.Context) {
 	var req models.RentCreation
 
 	if err := ctx.BindJSON(&req); err != nil {
-		log.Println("Bad body for rental creation, ", err.Error())
+		logging.FromContext(ctx.Request.Context()).Warn("bad body for rental creation", logging.Err(err))
 		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Bad Rental Creation body"})
 		return
 	}
@@ -98,15 +98,27 @@ func (h *RentalHandler) CreateRental(ctx *gin.Context) {
 		validationErr.Errors["payment_uid"] = "Payment Uid must be valid"
 	}
 
+	if req.RentalUID != "" {
+		if _, err := uuid.Parse(req.RentalUID); err != nil {
+			validationErr.Errors["rental_uid"] = "Rental Uid must be valid"
+		}
+	}
+
 	if len(validationErr.Errors) != 0 {
 		ctx.JSON(http.StatusBadRequest, validationErr)
 		return
 	}
 
-	rental, err := h.services.CreateRental(req)
+	rental, err := h.services.CreateRental(ctx.Request.Context(), req)
 
 	if err != nil {
-		log.Println("Can't create rental, ", err.Error())
+		if errors.Is(err, models.Forbidden) {
+			message := "Rental with rental_uid = " + req.RentalUID + " already exists"
+			ctx.JSON(http.StatusConflict, models.ErrorResponse{Message: message})
+			return
+		}
+
+		logging.FromContext(ctx.Request.Context()).Error("can't create rental", logging.KeyCarUID, req.CarUID, logging.KeyPaymentUID, req.PaymentUID, logging.Err(err))
 		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: err.Error()})
 		return
 	}
@@ -117,7 +129,7 @@ func (h *RentalHandler) CreateRental(ctx *gin.Context) {
 func (h *RentalHandler) UpdateRental(ctx *gin.Context) {
 	username := ctx.GetHeader("X-User-Name")
 	if username == "" {
-		log.Println("Need X-User-Name for rental")
+		logging.FromContext(ctx.Request.Context()).Warn("X-User-Name header is required")
 		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "X-User-Name header is required"})
 		return
 	}
@@ -125,7 +137,7 @@ func (h *RentalHandler) UpdateRental(ctx *gin.Context) {
 	rentalUid := ctx.Param("uid")
 
 	if _, err := uuid.Parse(rentalUid); err != nil {
-		log.Println("Need uid for rental")
+		logging.FromContext(ctx.Request.Context()).Warn("rental uid must be valid", logging.KeyRentalUID, rentalUid)
 		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "RentalUid must be valid"})
 		return
 	}
@@ -133,14 +145,14 @@ func (h *RentalHandler) UpdateRental(ctx *gin.Context) {
 	var req models.RentalUpsert
 
 	if err := ctx.BindJSON(&req); err != nil {
-		log.Println("Bad body for rental updating, ", err.Error())
+		logging.FromContext(ctx.Request.Context()).Warn("bad body for rental updating", logging.KeyRentalUID, rentalUid, logging.Err(err))
 		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Bad Rental Upsert body"})
 		return
 	}
 
-	rental, err := h.services.UpdateRental(req, rentalUid, username); 
+	rental, err := h.services.UpdateRental(ctx.Request.Context(), req, rentalUid, username); 
 	if err != nil {
-		log.Println("Can't update rental with uid = " + rentalUid +", ", err.Error())
+		logging.FromContext(ctx.Request.Context()).Warn("can't update rental", logging.KeyRentalUID, rentalUid, logging.Err(err))
 		if err == models.InvalidStatus {
 			ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
 		} else if err == models.ErrorNotFound {
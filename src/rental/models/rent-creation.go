@@ -1,6 +1,8 @@
 package models
 
 type RentCreation struct {
+	// Необязательный: по нему повтор создания возвращает уже созданную аренду
+	RentalUID	string 		`json:"rentalUid,omitempty"`
 	PaymentUID	string 		`json:"paymentUid"`
 	CarUID 		string 		`json:"carUid"`
 	DateFrom	string 		`json:"dateFrom"`
@@ -1,11 +1,13 @@
 package repositories
 
 import (
+	"context"
 	"errors"
 
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/models"
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type RentalPostgres struct {
@@ -16,10 +18,10 @@ func NewRentalPostgres(db *gorm.DB) *RentalPostgres {
 	return &RentalPostgres{DB: db}
 }
 
-func (r *RentalPostgres) GetRentalByUid(uid string) (*models.Rental, error) {
+func (r *RentalPostgres) GetRentalByUid(ctx context.Context, uid string) (*models.Rental, error) {
 	var rental models.Rental
 
-	if err := r.DB.Where("rental_uid = ?", uid).First(&rental).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Where("rental_uid = ?", uid).First(&rental).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, models.ErrorNotFound
 		}
@@ -30,10 +32,10 @@ func (r *RentalPostgres) GetRentalByUid(uid string) (*models.Rental, error) {
 	return &rental, nil
 }
 
-func (r *RentalPostgres) GetUserRentals(username string) ([]models.RentalResponse, error) {
+func (r *RentalPostgres) GetUserRentals(ctx context.Context, username string) ([]models.RentalResponse, error) {
 	var rentals []models.Rental
 
-	if err := r.DB.Omit("id", "username").Where("username = ?", username).Find(&rentals).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Omit("id", "username").Where("username = ?", username).Find(&rentals).Error; err != nil {
 		return nil, err
 	}
 
@@ -46,12 +48,24 @@ func (r *RentalPostgres) GetUserRentals(username string) ([]models.RentalRespons
 	return responses, nil
 }
 
-func (r *RentalPostgres) CreateRental(rental models.Rental) (error) {
-	return r.DB.Create(&rental).Error
+func (r *RentalPostgres) CreateRental(ctx context.Context, rental models.Rental) (error) {
+	result := r.DB.WithContext(ctx).
+				Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "rental_uid"}}, DoNothing: true}).
+				Create(&rental)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return models.ErrorAlreadyExists
+	}
+
+	return nil
 }
 
-func (r *RentalPostgres) UpdateRental(rentalUpsert models.RentalUpsert, uid string, username string) (*models.RentalResponse, error) {
-	result := r.DB.Model(&models.Rental{}).
+func (r *RentalPostgres) UpdateRental(ctx context.Context, rentalUpsert models.RentalUpsert, uid string, username string) (*models.RentalResponse, error) {
+	result := r.DB.WithContext(ctx).Model(&models.Rental{}).
 					Where("rental_uid = ? AND username = ?", uid, username).
 					Update("status", rentalUpsert.Status)
 	
@@ -65,7 +79,7 @@ func (r *RentalPostgres) UpdateRental(rentalUpsert models.RentalUpsert, uid stri
 
 	var rental models.Rental
 
-	if err := r.DB.Omit("id", "username").Where("rental_uid = ? AND username = ?", uid, username).Find(&rental).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Omit("id", "username").Where("rental_uid = ? AND username = ?", uid, username).Find(&rental).Error; err != nil {
 		return nil, err
 	}
 
@@ -1,15 +1,17 @@
 package repositories
 
 import (
+	"context"
+
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/models"
 	"gorm.io/gorm"
 )
 
 type IRentalRepo interface {
-	GetRentalByUid(uid string) (*models.Rental, error)
-	GetUserRentals(username string) ([]models.RentalResponse, error)
-	CreateRental(models.Rental) (error)
-	UpdateRental(rental models.RentalUpsert, uid string, username string) (*models.RentalResponse, error)
+	GetRentalByUid(ctx context.Context, uid string) (*models.Rental, error)
+	GetUserRentals(ctx context.Context, username string) ([]models.RentalResponse, error)
+	CreateRental(context.Context, models.Rental) (error)
+	UpdateRental(ctx context.Context, rental models.RentalUpsert, uid string, username string) (*models.RentalResponse, error)
 }
 
 type Repository struct {
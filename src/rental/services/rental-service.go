@@ -1,6 +1,8 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/models"
@@ -17,8 +19,8 @@ func NewRentalService(repo repo.IRentalRepo) *RentalService {
 	return &RentalService{repo: repo}
 }
 
-func (s *RentalService) GetUserRentalByUid(uid string, username string) (*models.RentalResponse, error) {
-	rental, err := s.repo.GetRentalByUid(uid)
+func (s *RentalService) GetUserRentalByUid(ctx context.Context, uid string, username string) (*models.RentalResponse, error) {
+	rental, err := s.repo.GetRentalByUid(ctx, uid)
 
 	if err != nil {
 		return nil, err
@@ -33,11 +35,11 @@ func (s *RentalService) GetUserRentalByUid(uid string, username string) (*models
 	return &rentalResponse, nil
 }
 
-func (s *RentalService) GetUserRentals(username string) ([]models.RentalResponse, error) {
-	return s.repo.GetUserRentals(username)
+func (s *RentalService) GetUserRentals(ctx context.Context, username string) ([]models.RentalResponse, error) {
+	return s.repo.GetUserRentals(ctx, username)
 }
 
-func (s *RentalService) CreateRental(rentalReq models.RentCreation) (*models.RentalResponse, error) {
+func (s *RentalService) CreateRental(ctx context.Context, rentalReq models.RentCreation) (*models.RentalResponse, error) {
 	dateFrom, err := time.Parse("2006-01-02", rentalReq.DateFrom)
     if err != nil {
         return nil, err
@@ -48,8 +50,13 @@ func (s *RentalService) CreateRental(rentalReq models.RentCreation) (*models.Ren
         return nil, err
     }
 
+	rentalUid := rentalReq.RentalUID
+	if rentalUid == "" {
+		rentalUid = uuid.New().String()
+	}
+
 	rental := models.Rental{
-		RentalUID: uuid.New().String(),
+		RentalUID: rentalUid,
 		Username: rentalReq.Username,
 		CarUID: rentalReq.CarUID,
 		PaymentUID: rentalReq.PaymentUID,
@@ -58,15 +65,32 @@ func (s *RentalService) CreateRental(rentalReq models.RentCreation) (*models.Ren
 		DateTo: dateTo,
 	}
 
-	if err := s.repo.CreateRental(rental); err == nil {
-		response := utils.ConvertToRentalResponse(rental)
+	err = s.repo.CreateRental(ctx, rental)
+
+	// Повтор с тем же uid: аренда уже создана, возвращается как есть
+	if errors.Is(err, models.ErrorAlreadyExists) {
+		existing, err := s.repo.GetRentalByUid(ctx, rentalUid)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing.Username != rentalReq.Username {
+			return nil, models.Forbidden
+		}
+
+		response := utils.ConvertToRentalResponse(*existing)
 		return &response, nil
-	} else {
+	}
+
+	if err != nil {
 		return nil, err
 	}
+
+	response := utils.ConvertToRentalResponse(rental)
+	return &response, nil
 }
 
-func (s *RentalService) UpdateRental(rental models.RentalUpsert, uid string, username string) (*models.RentalResponse, error) {
+func (s *RentalService) UpdateRental(ctx context.Context, rental models.RentalUpsert, uid string, username string) (*models.RentalResponse, error) {
 	validStatuses := map[string]bool{
         "IN_PROGRESS": true,
         "FINISHED":    true,
@@ -77,5 +101,5 @@ func (s *RentalService) UpdateRental(rental models.RentalUpsert, uid string, use
         return nil, models.InvalidStatus
     }
 
-	return s.repo.UpdateRental(rental, uid, username)
+	return s.repo.UpdateRental(ctx, rental, uid, username)
 }
\ No newline at end of file
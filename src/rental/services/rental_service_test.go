@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
@@ -16,7 +17,7 @@ type MockRentalRepository struct {
 	mock.Mock
 }
 
-func (m *MockRentalRepository) GetRentalByUid(uid string) (*models.Rental, error) {
+func (m *MockRentalRepository) GetRentalByUid(_ context.Context, uid string) (*models.Rental, error) {
 	args := m.Called(uid)
 	if rental := args.Get(0); rental != nil {
 		return rental.(*models.Rental), args.Error(1)
@@ -24,17 +25,17 @@ func (m *MockRentalRepository) GetRentalByUid(uid string) (*models.Rental, error
 	return nil, args.Error(1)
 }
 
-func (m *MockRentalRepository) GetUserRentals(username string) ([]models.RentalResponse, error) {
+func (m *MockRentalRepository) GetUserRentals(_ context.Context, username string) ([]models.RentalResponse, error) {
 	args := m.Called(username)
 	return args.Get(0).([]models.RentalResponse), args.Error(1)
 }
 
-func (m *MockRentalRepository) CreateRental(rental models.Rental) error {
+func (m *MockRentalRepository) CreateRental(_ context.Context, rental models.Rental) error {
 	args := m.Called(rental)
 	return args.Error(0)
 }
 
-func (m *MockRentalRepository) UpdateRental(rental models.RentalUpsert, uid string, username string) (*models.RentalResponse, error) {
+func (m *MockRentalRepository) UpdateRental(_ context.Context, rental models.RentalUpsert, uid string, username string) (*models.RentalResponse, error) {
 	args := m.Called(rental, uid, username)
 	if response := args.Get(0); response != nil {
 		return response.(*models.RentalResponse), args.Error(1)
@@ -53,7 +54,7 @@ func TestRentalService_GetUserRentalByUid_NotFound(t *testing.T) {
 
 	mockRepo.On("GetRentalByUid", uid).Return((*models.Rental)(nil), expectedError)
 
-	_, err := service.GetUserRentalByUid(uid, username)
+	_, err := service.GetUserRentalByUid(context.Background(), uid, username)
 
 	assert.True(t, errors.Is(err, expectedError))
 	mockRepo.AssertExpectations(t)
@@ -73,7 +74,7 @@ func TestRentalService_GetUserRentalByUid_Forbidden(t *testing.T) {
 
 	mockRepo.On("GetRentalByUid", uid).Return(rental, nil)
 
-	_, err := service.GetUserRentalByUid(uid, username)
+	_, err := service.GetUserRentalByUid(context.Background(), uid, username)
 
 	assert.True(t, errors.Is(err, models.Forbidden))
 	mockRepo.AssertExpectations(t)
@@ -96,7 +97,7 @@ func TestRentalService_GetUserRentalByUid_Success(t *testing.T) {
 
 	mockRepo.On("GetRentalByUid", uid).Return(rental, nil)
 
-	response, err := service.GetUserRentalByUid(uid, username)
+	response, err := service.GetUserRentalByUid(context.Background(), uid, username)
 
 	assert.Nil(t, err)
 	assert.Equal(t, expectedResponse, *response)
@@ -116,7 +117,7 @@ func TestRentalService_GetUserRentals_Success(t *testing.T) {
 
 	mockRepo.On("GetUserRentals", username).Return(expectedRentals, nil)
 
-	rentals, err := service.GetUserRentals(username)
+	rentals, err := service.GetUserRentals(context.Background(), username)
 
 	assert.Nil(t, err)
 	assert.Equal(t, expectedRentals, rentals)
@@ -133,7 +134,7 @@ func TestRentalService_GetUserRentals_Error(t *testing.T) {
 
 	mockRepo.On("GetUserRentals", username).Return([]models.RentalResponse{}, expectedError)
 
-	_, err := service.GetUserRentals(username)
+	_, err := service.GetUserRentals(context.Background(), username)
 
 	assert.True(t, errors.Is(err, expectedError))
 	mockRepo.AssertExpectations(t)
@@ -175,7 +176,7 @@ func TestRentalService_CreateRental_Success(t *testing.T) {
 			rental.DateTo.Equal(expectedRental.DateTo)
 	})).Return(nil)
 
-	response, err := service.CreateRental(rentalReq)
+	response, err := service.CreateRental(context.Background(), rentalReq)
 
 	assert.Nil(t, err)
 	assert.Equal(t, expectedResponse.CarUID, response.CarUID)
@@ -194,8 +195,44 @@ func TestRentalService_CreateRental_InvalidDate(t *testing.T) {
 		DateTo:   "2023-12-05",
 	}
 
-	_, err := service.CreateRental(rentalReq)
+	_, err := service.CreateRental(context.Background(), rentalReq)
 
 	assert.NotNil(t, err)
 	mockRepo.AssertExpectations(t)
 }
+
+// Тест: повторное создание с тем же uid возвращает уже созданную аренду
+func TestRentalService_CreateRental_SameUidReturnsExisting(t *testing.T) {
+	mockRepo := new(MockRentalRepository)
+	service := NewRentalService(mockRepo)
+
+	rentalUid := uuid.New().String()
+	rentalReq := models.RentCreation{
+		RentalUID:  rentalUid,
+		Username:   "john_doe",
+		CarUID:     "car-uid",
+		PaymentUID: "payment-uid",
+		DateFrom:   "2023-12-01",
+		DateTo:     "2023-12-05",
+	}
+
+	existing := &models.Rental{
+		RentalUID:  rentalUid,
+		Username:   "john_doe",
+		CarUID:     "car-uid",
+		PaymentUID: "payment-uid",
+		Status:     "CANCELED",
+	}
+
+	mockRepo.On("CreateRental", mock.MatchedBy(func(rental models.Rental) bool {
+		return rental.RentalUID == rentalUid
+	})).Return(models.ErrorAlreadyExists)
+	mockRepo.On("GetRentalByUid", rentalUid).Return(existing, nil)
+
+	response, err := service.CreateRental(context.Background(), rentalReq)
+
+	assert.Nil(t, err)
+	assert.Equal(t, rentalUid, response.RentalUID)
+	assert.Equal(t, "CANCELED", response.Status)
+	mockRepo.AssertExpectations(t)
+}
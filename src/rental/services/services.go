@@ -1,15 +1,17 @@
 package services
 
 import (
+	"context"
+
 	"github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/models"
 	repo "github.com/SwanPoi/bmstu_rsoi_lab2/src/rental/repositories"
 )
 
 type IRentalService interface {
-	GetUserRentalByUid(uid string, username string) (*models.RentalResponse, error)
-	GetUserRentals(username string) ([]models.RentalResponse, error)
-	CreateRental(models.RentCreation) (*models.RentalResponse, error)
-	UpdateRental(rental models.RentalUpsert, uid string, username string) (*models.RentalResponse, error)
+	GetUserRentalByUid(ctx context.Context, uid string, username string) (*models.RentalResponse, error)
+	GetUserRentals(ctx context.Context, username string) ([]models.RentalResponse, error)
+	CreateRental(context.Context, models.RentCreation) (*models.RentalResponse, error)
+	UpdateRental(ctx context.Context, rental models.RentalUpsert, uid string, username string) (*models.RentalResponse, error)
 }
 
 type Services struct {